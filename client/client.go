@@ -0,0 +1,198 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+)
+
+//APIError - error response of the service; Code and RequestID are only set by problem+json responses
+type APIError struct {
+	StatusCode int
+	Code       models.ErrorCode
+	Message    string
+	RequestID  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("balance-service responded with [%v]: %s", e.StatusCode, e.Message)
+}
+
+//InsufficientFundsError - 403 response to a debit exceeding what the account can spend
+type InsufficientFundsError struct {
+	APIError
+}
+
+//ValidationError - 400 response to a request which is not valid; Fields lists failed field validations if any
+type ValidationError struct {
+	APIError
+	Fields []models.FieldError
+}
+
+//TransactionsOptions are query params of GetTransactions; zero values are not sent, zero Page returns
+//the whole history
+type TransactionsOptions struct {
+	Sort  string
+	Order string
+	Page  int
+}
+
+//Client calls the HTTP API of balance-service at BaseURL, e.g. http://localhost:8081
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+//New creates a client of the service at baseURL
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTP: http.DefaultClient}
+}
+
+//GetBalance returns account with id=id
+func (c *Client) GetBalance(id int) (*models.Account, error) {
+	account := &models.Account{}
+	if err := c.do(http.MethodGet, fmt.Sprintf("/%v", id), nil, nil, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+//GetTransactions returns transaction history of account with id=id
+func (c *Client) GetTransactions(id int, options *TransactionsOptions) ([]models.Transaction, error) {
+	query := url.Values{}
+	if options != nil {
+		if options.Sort != "" {
+			query.Set("sort", options.Sort)
+		}
+		if options.Order != "" {
+			query.Set("order", options.Order)
+		}
+		if options.Page != 0 {
+			query.Set("page", strconv.Itoa(options.Page))
+		}
+	}
+	path := fmt.Sprintf("/transactions/%v", id)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	transactions := make([]models.Transaction, 0)
+	if err := c.do(http.MethodGet, path, nil, nil, &transactions); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+//ChangeBalance changes balance of an account; IdempotencyKey of request is sent in the Idempotency-Key header
+func (c *Client) ChangeBalance(request *models.ChangeBalanceRequest) (*models.Transaction, error) {
+	transaction := &models.Transaction{}
+	if err := c.do(http.MethodPost, "/change-balance", request, idempotencyKey(request.IdempotencyKey), transaction); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+//Transfer transfers money between accounts and returns the transaction of the payer; IdempotencyKey of request
+//is sent in the Idempotency-Key header
+func (c *Client) Transfer(request *models.TransferRequest) (*models.Transaction, error) {
+	transaction := &models.Transaction{}
+	if err := c.do(http.MethodPost, "/transfer", request, idempotencyKey(request.IdempotencyKey), transaction); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+//GetStatement writes statement of account with id=id for period [from, to] in format (csv, ofx or camt053) to w
+func (c *Client) GetStatement(id int, from time.Time, to time.Time, format string, w io.Writer) error {
+	query := url.Values{}
+	query.Set("from", from.Format(time.RFC3339Nano))
+	query.Set("to", to.Format(time.RFC3339Nano))
+	query.Set("format", format)
+	resp, err := c.HTTP.Get(fmt.Sprintf("%s/%v/statement?%s", c.BaseURL, id, query.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return responseError(resp, data)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+//do sends request with body encoded as JSON and decodes the response into response
+func (c *Client) do(method string, path string, body interface{}, header http.Header, response interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	for name := range header {
+		req.Header.Set(name, header.Get(name))
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp, data)
+	}
+	if err = json.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("balance-service response not valid: %v", err)
+	}
+	return nil
+}
+
+//responseError returns the typed error of error response resp with body; a body which is not problem+json,
+//e.g. one of a proxy, becomes the message
+func responseError(resp *http.Response, body []byte) error {
+	apiErr := APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	problem := &models.Problem{}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), models.ProblemContentType) && json.Unmarshal(body, problem) == nil {
+		apiErr.Code = problem.Code
+		apiErr.Message = problem.Detail
+		apiErr.RequestID = problem.RequestID
+	}
+	switch {
+	case apiErr.Code == models.ErrorInsufficientFundsCode:
+		return &InsufficientFundsError{APIError: apiErr}
+	case resp.StatusCode == http.StatusBadRequest:
+		return &ValidationError{APIError: apiErr, Fields: problem.Errors}
+	}
+	return &apiErr
+}
+
+func idempotencyKey(key string) http.Header {
+	if key == "" {
+		return nil
+	}
+	return http.Header{models.IdempotencyKeyHeader: []string{key}}
+}
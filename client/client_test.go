@@ -0,0 +1,78 @@
+package client
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/events"
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/server"
+	"github.com/dalconoid/balance-service/storage/memory"
+	"github.com/magiconair/properties/assert"
+)
+
+//serve starts the service with a fresh in-memory store and returns a client of it
+func serve(t *testing.T) *Client {
+	store := memory.New(2)
+	s := server.New()
+	s.ConfigureRouter(store, events.NewBroker(store, time.Second, 1))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return New(ts.URL)
+}
+
+func TestClient(t *testing.T) {
+	c := serve(t)
+
+	transaction, err := c.ChangeBalance(&models.ChangeBalanceRequest{ID: 1, Delta: 10000, IdempotencyKey: "deposit-1"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, transaction.Remaining, models.Money(10000))
+	//the same key does not change the balance again
+	c.ChangeBalance(&models.ChangeBalanceRequest{ID: 1, Delta: 10000, IdempotencyKey: "deposit-1"})
+
+	transaction, err = c.Transfer(&models.TransferRequest{ID1: 1, ID2: 2, Delta: 2500})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, transaction.Delta, models.Money(-2500))
+	c.Transfer(&models.TransferRequest{ID1: 1, ID2: 2, Delta: 500})
+
+	account, err := c.GetBalance(1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, account.Balance, models.Money(7000))
+
+	transactions, err := c.GetTransactions(1, &TransactionsOptions{Sort: models.SortBySumString, Order: models.OrderAscendingString})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(transactions), 3)
+	assert.Equal(t, transactions[0].Delta, models.Money(-2500))
+	transactions, _ = c.GetTransactions(1, &TransactionsOptions{Page: 2})
+	assert.Equal(t, len(transactions), 1)
+}
+
+func TestClientErrors(t *testing.T) {
+	c := serve(t)
+
+	_, err := c.Transfer(&models.TransferRequest{ID1: 1, ID2: 2, Delta: 2500})
+	var fundsErr *InsufficientFundsError
+	assert.Equal(t, errors.As(err, &fundsErr), true)
+	assert.Equal(t, fundsErr.StatusCode, 403)
+	assert.Equal(t, fundsErr.RequestID != "", true)
+
+	_, err = c.Transfer(&models.TransferRequest{ID1: 1, ID2: 1, Delta: -5})
+	var validationErr *ValidationError
+	assert.Equal(t, errors.As(err, &validationErr), true)
+	assert.Equal(t, len(validationErr.Fields), 2)
+	assert.Equal(t, validationErr.Code, models.ErrorValidationCode)
+	assert.Equal(t, validationErr.Fields[0], models.FieldError{Field: "ID2", Rule: "nefield=ID1", Message: "must differ from [ID1]"})
+
+	_, err = c.GetTransactions(1, &TransactionsOptions{Sort: "by-color"})
+	assert.Equal(t, errors.As(err, &validationErr), true)
+
+	_, err = c.ChangeBalance(&models.ChangeBalanceRequest{ID: 1, Delta: 100, IdempotencyKey: "key"})
+	assert.Equal(t, err, nil)
+	_, err = c.ChangeBalance(&models.ChangeBalanceRequest{ID: 1, Delta: 200, IdempotencyKey: "key"})
+	var apiErr *APIError
+	assert.Equal(t, errors.As(err, &apiErr), true)
+	assert.Equal(t, apiErr.StatusCode, 409)
+	assert.Equal(t, apiErr.Code, models.ErrorIdempotencyConflictCode)
+}
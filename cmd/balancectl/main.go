@@ -0,0 +1,380 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dalconoid/balance-service/client"
+	"github.com/dalconoid/balance-service/models"
+	"github.com/spf13/viper"
+)
+
+const (
+	//exit codes
+	exitOK                = 0
+	exitError             = 1
+	exitUsage             = 2
+	exitValidation        = 3
+	exitInsufficientFunds = 4
+
+	//output formats
+	outputTable = "table"
+	outputJSON  = "json"
+
+	defaultEndpoint = "http://localhost:8081"
+)
+
+const usage = `Usage: balancectl [-config path] [-endpoint url] [-output table|json] command [args]
+
+Commands:
+  balance <id>                                     show balance of an account
+  history <id> [-sort by-time|by-sum] [-order asc|desc] [-page n]
+                                                   list transactions of an account
+  adjust <id> <delta> [-key idempotency-key]       deposit positive delta or withdraw negative delta
+  transfer <from> <to> <amount> [-fee amount] [-key idempotency-key]
+                                                   transfer money between accounts
+  statement <id> -from time [-to time] [-format csv|ofx|camt053] [-out file]
+                                                   export statement of an account
+
+The endpoint is taken from -endpoint, BALANCECTL_ENDPOINT or ENDPOINT of the config file, default ` + defaultEndpoint + `.
+Amounts have at most 2 decimal places, times are RFC 3339 like 2006-01-02T15:04:05Z.
+Exit codes: 1 - error, 2 - usage, 3 - request not valid, 4 - insufficient funds.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+//run runs balancectl with args and returns the exit code
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("balancectl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	configPath := flags.String("config", "", "path to config file with ENDPOINT")
+	endpoint := flags.String("endpoint", "", "URL of balance-service")
+	output := flags.String("output", outputTable, "output format: table or json")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *output != outputTable && *output != outputJSON {
+		fmt.Fprintf(stderr, "balancectl: -output not valid: valid options are [%s], [%s]\n", outputTable, outputJSON)
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	if *endpoint == "" {
+		var err error
+		if *endpoint, err = loadEndpoint(*configPath); err != nil {
+			fmt.Fprintf(stderr, "balancectl: %v\n", err)
+			return exitError
+		}
+	}
+
+	cmd := &command{client: client.New(*endpoint), output: *output, stdout: stdout}
+	var err error
+	name, cmdArgs := flags.Arg(0), flags.Args()[1:]
+	switch name {
+	case "balance":
+		err = cmd.balance(cmdArgs)
+	case "history":
+		err = cmd.history(cmdArgs)
+	case "adjust":
+		err = cmd.adjust(cmdArgs)
+	case "transfer":
+		err = cmd.transfer(cmdArgs)
+	case "statement":
+		err = cmd.statement(cmdArgs)
+	default:
+		err = usageError(fmt.Sprintf("unknown command [%s]", name))
+	}
+	return report(err, stderr)
+}
+
+//loadEndpoint returns the endpoint from environment or config file at path
+func loadEndpoint(path string) (string, error) {
+	v := viper.New()
+	v.SetEnvPrefix("BALANCECTL")
+	v.AutomaticEnv()
+	v.SetDefault("ENDPOINT", defaultEndpoint)
+	if path != "" {
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return "", fmt.Errorf("config: %v", err)
+		}
+	}
+	return v.GetString("ENDPOINT"), nil
+}
+
+//report writes err to stderr and returns the exit code of err
+func report(err error, stderr io.Writer) int {
+	if err == nil {
+		return exitOK
+	}
+	var usageErr usageError
+	var validationErr *client.ValidationError
+	var fundsErr *client.InsufficientFundsError
+	var apiErr *client.APIError
+	switch {
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(stderr, "balancectl: %s\nRun balancectl -h for usage\n", usageErr)
+		return exitUsage
+	case errors.As(err, &validationErr):
+		if len(validationErr.Fields) == 0 {
+			fmt.Fprintf(stderr, "Request not valid: %s\n", validationErr.Message)
+			return exitValidation
+		}
+		fmt.Fprintln(stderr, "Request not valid:")
+		for _, field := range validationErr.Fields {
+			fmt.Fprintf(stderr, "  %s %s\n", field.Field, field.Message)
+		}
+		return exitValidation
+	case errors.As(err, &fundsErr):
+		fmt.Fprintf(stderr, "Insufficient funds: %s\n", fundsErr.Message)
+		return exitInsufficientFunds
+	case errors.As(err, &apiErr):
+		fmt.Fprintf(stderr, "Request failed with status [%v]: %s\n", apiErr.StatusCode, apiErr.Message)
+		return exitError
+	}
+	fmt.Fprintf(stderr, "balancectl: %v\n", err)
+	return exitError
+}
+
+//usageError - command line not valid
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+//command runs commands with client and writes their results to stdout in format output
+type command struct {
+	client *client.Client
+	output string
+	stdout io.Writer
+}
+
+func (c *command) balance(args []string) error {
+	flags := newFlagSet("balance")
+	values, err := parseArgs(flags, args, "id")
+	if err != nil {
+		return err
+	}
+	id, err := parseID("balance", "id", values[0])
+	if err != nil {
+		return err
+	}
+	account, err := c.client.GetBalance(id)
+	if err != nil {
+		return err
+	}
+	return c.print(account, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tBALANCE\tHELD\tCREDIT LIMIT\tAVAILABLE\tSTATUS")
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%s\n", account.ID, account.Balance, account.Held, account.CreditLimit,
+			account.Available, account.Status)
+	})
+}
+
+func (c *command) history(args []string) error {
+	flags := newFlagSet("history")
+	options := &client.TransactionsOptions{}
+	flags.StringVar(&options.Sort, "sort", "", "by-time or by-sum")
+	flags.StringVar(&options.Order, "order", "", "asc or desc")
+	flags.IntVar(&options.Page, "page", 0, "page number, the whole history if not set")
+	values, err := parseArgs(flags, args, "id")
+	if err != nil {
+		return err
+	}
+	id, err := parseID("history", "id", values[0])
+	if err != nil {
+		return err
+	}
+	transactions, err := c.client.GetTransactions(id, options)
+	if err != nil {
+		return err
+	}
+	return c.printTransactions(transactions)
+}
+
+func (c *command) adjust(args []string) error {
+	flags := newFlagSet("adjust")
+	key := flags.String("key", "", "idempotency key")
+	values, err := parseArgs(flags, args, "id", "delta")
+	if err != nil {
+		return err
+	}
+	request := &models.ChangeBalanceRequest{IdempotencyKey: *key}
+	if request.ID, err = parseID("adjust", "id", values[0]); err != nil {
+		return err
+	}
+	if request.Delta, err = parseAmount("adjust", "delta", values[1]); err != nil {
+		return err
+	}
+	transaction, err := c.client.ChangeBalance(request)
+	if err != nil {
+		return err
+	}
+	return c.printTransactions([]models.Transaction{*transaction})
+}
+
+func (c *command) transfer(args []string) error {
+	flags := newFlagSet("transfer")
+	key := flags.String("key", "", "idempotency key")
+	fee := flags.String("fee", "0", "fee charged from the payer")
+	values, err := parseArgs(flags, args, "from", "to", "amount")
+	if err != nil {
+		return err
+	}
+	request := &models.TransferRequest{IdempotencyKey: *key}
+	if request.ID1, err = parseID("transfer", "from", values[0]); err != nil {
+		return err
+	}
+	if request.ID2, err = parseID("transfer", "to", values[1]); err != nil {
+		return err
+	}
+	if request.Delta, err = parseAmount("transfer", "amount", values[2]); err != nil {
+		return err
+	}
+	if request.Fee, err = models.ParseMoney(*fee); err != nil {
+		return usageError(fmt.Sprintf("transfer: -fee not valid: %v", err))
+	}
+	transaction, err := c.client.Transfer(request)
+	if err != nil {
+		return err
+	}
+	return c.printTransactions([]models.Transaction{*transaction})
+}
+
+func (c *command) statement(args []string) error {
+	flags := newFlagSet("statement")
+	strFrom := flags.String("from", "", "start of the period")
+	strTo := flags.String("to", "", "end of the period, now if not set")
+	format := flags.String("format", "csv", "csv, ofx or camt053")
+	out := flags.String("out", "", "file to write the statement to, standard output if not set")
+	values, err := parseArgs(flags, args, "id")
+	if err != nil {
+		return err
+	}
+	id, err := parseID("statement", "id", values[0])
+	if err != nil {
+		return err
+	}
+	if *strFrom == "" {
+		return usageError("statement: -from is required")
+	}
+	from, err := time.Parse(time.RFC3339Nano, *strFrom)
+	if err != nil {
+		return usageError(fmt.Sprintf("statement: -from not valid: %v", err))
+	}
+	to := time.Now()
+	if *strTo != "" {
+		if to, err = time.Parse(time.RFC3339Nano, *strTo); err != nil {
+			return usageError(fmt.Sprintf("statement: -to not valid: %v", err))
+		}
+	}
+
+	get := func(w io.Writer) error { return c.client.GetStatement(id, from, to, *format, w) }
+	if *out == "" {
+		return get(c.stdout)
+	}
+	return writeFile(*out, get)
+}
+
+//writeFile writes file path with write; path is replaced only if write succeeds
+func writeFile(path string, write func(w io.Writer) error) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err = write(file); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err = file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+//print writes v as JSON or as a table written by table
+func (c *command) print(v interface{}, table func(w *tabwriter.Writer)) error {
+	if c.output == outputJSON {
+		encoder := json.NewEncoder(c.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+func (c *command) printTransactions(transactions []models.Transaction) error {
+	return c.print(transactions, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tACCOUNT\tKIND\tCREATED AT\tDELTA\tREMAINING\tMESSAGE")
+		for _, t := range transactions {
+			fmt.Fprintf(w, "%v\t%v\t%s\t%s\t%v\t%v\t%s\n", t.ID, t.AccountID, t.Kind, t.CreatedAt.Format(time.RFC3339),
+				t.Delta, t.Remaining, t.Message)
+		}
+	})
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+//parseArgs parses flags and positional args with names; flags may follow the positional args, which may be
+//negative numbers
+func parseArgs(flags *flag.FlagSet, args []string, names ...string) ([]string, error) {
+	positional := make([]string, 0, len(names))
+	for len(args) > 0 {
+		if _, err := strconv.ParseFloat(args[0], 64); err == nil {
+			positional = append(positional, args[0])
+			args = args[1:]
+			continue
+		}
+		if err := flags.Parse(args); err != nil {
+			return nil, usageError(fmt.Sprintf("%s: %v", flags.Name(), err))
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(positional) != len(names) {
+		return nil, usageError(fmt.Sprintf("%s: expected arguments <%s>", flags.Name(), strings.Join(names, "> <")))
+	}
+	return positional, nil
+}
+
+//parseID parses positional arg name of command
+func parseID(command string, name string, value string) (int, error) {
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, usageError(fmt.Sprintf("%s: <%s> not valid: must be an integer", command, name))
+	}
+	return id, nil
+}
+
+//parseAmount parses positional arg name of command as money
+func parseAmount(command string, name string, value string) (models.Money, error) {
+	amount, err := models.ParseMoney(value)
+	if err != nil {
+		return 0, usageError(fmt.Sprintf("%s: <%s> not valid: %v", command, name, err))
+	}
+	return amount, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/events"
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/server"
+	"github.com/dalconoid/balance-service/storage/memory"
+	"github.com/magiconair/properties/assert"
+)
+
+//serve starts the service with a fresh in-memory store and returns its URL
+func serve(t *testing.T) string {
+	store := memory.New(10)
+	s := server.New()
+	s.ConfigureRouter(store, events.NewBroker(store, time.Second, 1))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+//balancectl runs balancectl with args and returns its exit code, stdout and stderr
+func balancectl(args ...string) (int, string, string) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(args, stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCommands(t *testing.T) {
+	endpoint := serve(t)
+
+	code, _, _ := balancectl("-endpoint", endpoint, "adjust", "1", "100.50", "-key", "deposit-1")
+	assert.Equal(t, code, exitOK)
+	code, out, _ := balancectl("-endpoint", endpoint, "adjust", "1", "-0.50")
+	assert.Equal(t, code, exitOK)
+	assert.Matches(t, out, `withdrawal\s+\S+\s+-0.50\s+100.00`)
+	code, _, _ = balancectl("-endpoint", endpoint, "transfer", "-fee", "1", "1", "2", "40")
+	assert.Equal(t, code, exitOK)
+
+	code, out, _ = balancectl("-endpoint", endpoint, "balance", "1")
+	assert.Equal(t, code, exitOK)
+	assert.Matches(t, out, `(?s)^ID\s+BALANCE.*\n1\s+59.00\s+0.00\s+0.00\s+59.00\s+active\n$`)
+
+	code, out, _ = balancectl("-endpoint", endpoint, "-output", "json", "history", "1", "-sort", "by-sum", "-order", "desc")
+	assert.Equal(t, code, exitOK)
+	transactions := make([]models.Transaction, 0)
+	assert.Equal(t, json.Unmarshal([]byte(out), &transactions), nil)
+	assert.Equal(t, len(transactions), 4)
+	assert.Equal(t, transactions[0].Delta, models.Money(10050))
+
+	file := filepath.Join(t.TempDir(), "statement.csv")
+	code, _, _ = balancectl("-endpoint", endpoint, "statement", "1", "-from", "2020-01-01T00:00:00Z", "-out", file)
+	assert.Equal(t, code, exitOK)
+	data, _ := ioutil.ReadFile(file)
+	assert.Equal(t, strings.Count(string(data), "\n") > 4, true)
+
+	//a failed request leaves the file as it was
+	code, _, _ = balancectl("-endpoint", endpoint, "statement", "1", "-from", "2020-01-01T00:00:00Z", "-format", "xls", "-out", file)
+	assert.Equal(t, code == exitOK, false)
+	failed, _ := ioutil.ReadFile(file)
+	assert.Equal(t, string(failed), string(data))
+	_, err := ioutil.ReadFile(file + ".tmp")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestErrors(t *testing.T) {
+	endpoint := serve(t)
+
+	code, _, errOut := balancectl("-endpoint", endpoint, "transfer", "1", "2", "40")
+	assert.Equal(t, code, exitInsufficientFunds)
+	assert.Matches(t, errOut, `^Insufficient funds: `)
+
+	code, _, errOut = balancectl("-endpoint", endpoint, "transfer", "1", "1", "40")
+	assert.Equal(t, code, exitValidation)
+	assert.Equal(t, errOut, "Request not valid:\n  ID2 must differ from [ID1]\n")
+
+	code, _, errOut = balancectl("-endpoint", endpoint, "adjust", "1")
+	assert.Equal(t, code, exitUsage)
+	assert.Matches(t, errOut, `expected arguments <id> <delta>`)
+
+	code, _, _ = balancectl("-endpoint", endpoint, "rename", "1")
+	assert.Equal(t, code, exitUsage)
+}
+
+func TestLoadEndpoint(t *testing.T) {
+	endpoint, err := loadEndpoint("")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, endpoint, "http://localhost:8081")
+
+	config := filepath.Join(t.TempDir(), "balancectl.yaml")
+	ioutil.WriteFile(config, []byte("ENDPOINT: http://balance:8081\n"), 0644)
+	endpoint, _ = loadEndpoint(config)
+	assert.Equal(t, endpoint, "http://balance:8081")
+
+	t.Setenv("BALANCECTL_ENDPOINT", "http://env:8081")
+	endpoint, _ = loadEndpoint(config)
+	assert.Equal(t, endpoint, "http://env:8081")
+}
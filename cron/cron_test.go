@@ -0,0 +1,39 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2021, 1, 31, 10, 30, 0, 0, time.UTC)
+	for _, test := range []struct {
+		spec string
+		next time.Time
+	}{
+		{"@monthly", time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9 1 * *", time.Date(2021, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 1, 31, 10, 45, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2021, 2, 1, 10, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 1-5", time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, 2, 7, 0, 0, 0, 0, time.UTC)},
+		//either day field matches when both are restricted
+		{"0 0 15 * 1", time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 12 *", time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		e, err := Parse(test.spec)
+		assert.Equal(t, err, nil, test.spec)
+		assert.Equal(t, e.Next(from), test.next, test.spec)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := Parse(spec)
+		assert.Equal(t, err != nil, true, spec)
+	}
+}
@@ -0,0 +1,193 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+//pollBatch is the number of outbox events read at once
+const pollBatch = 500
+
+var (
+	//ErrOverflow closes a subscription whose consumer does not keep up with its events
+	ErrOverflow = errors.New("events: subscriber is too slow, resume from the last received transaction")
+	//ErrClosed closes subscriptions when the broker is closed
+	ErrClosed = errors.New("events: server is shutting down")
+)
+
+//Broker pushes transactions of accounts to subscribers as they are committed. It tails the outbox, so it sees
+//every balance change written to the database; the store returns only events no other event can be committed before
+type Broker struct {
+	Store storage.Store
+	//Interval is how often the outbox is polled
+	Interval time.Duration
+	//BufferSize is how many transactions a subscriber may lag behind before it is dropped
+	BufferSize int
+	//Heartbeat is how often an idle stream gets a comment, so that proxies do not close it
+	Heartbeat time.Duration
+
+	mu     sync.Mutex
+	subs   map[int]map[*Subscription]struct{}
+	cursor models.OutboxPosition
+	closed bool
+	done   chan struct{}
+}
+
+//Subscription receives transactions of an account until it is closed
+type Subscription struct {
+	AccountID int
+	c         chan models.Transaction
+	err       error
+}
+
+//Events returns the channel of transactions; it is closed when the subscription is closed, see Err
+func (s *Subscription) Events() <-chan models.Transaction {
+	return s.c
+}
+
+//Err returns why the subscription was closed: ErrOverflow, ErrClosed or nil if it was unsubscribed
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+//NewBroker creates a broker of store
+func NewBroker(store storage.Store, interval time.Duration, bufferSize int) *Broker {
+	return &Broker{
+		Store:      store,
+		Interval:   interval,
+		BufferSize: bufferSize,
+		Heartbeat:  15 * time.Second,
+		subs:       make(map[int]map[*Subscription]struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+//Start starts pushing transactions committed from now on until the broker is closed
+func (b *Broker) Start(ctx context.Context) *models.CustomErr {
+	cursor, cErr := b.Store.LastOutboxPosition(ctx)
+	if cErr != nil {
+		return cErr
+	}
+	b.mu.Lock()
+	b.cursor = cursor
+	b.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(b.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-b.done:
+				return
+			case <-ticker.C:
+				if cErr := b.poll(context.Background()); cErr != nil {
+					log.Error(cErr.Err.Error())
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+//Subscribe subscribes to transactions of account with id=id
+func (b *Broker) Subscribe(id int) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+	sub := &Subscription{AccountID: id, c: make(chan models.Transaction, b.BufferSize)}
+	if b.subs[id] == nil {
+		b.subs[id] = make(map[*Subscription]struct{})
+	}
+	b.subs[id][sub] = struct{}{}
+	return sub, nil
+}
+
+//Unsubscribe closes sub unless it is already closed
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.drop(sub, nil)
+}
+
+//Close stops the broker and closes all subscriptions with ErrClosed
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	close(b.done)
+	for _, subs := range b.subs {
+		for sub := range subs {
+			b.drop(sub, ErrClosed)
+		}
+	}
+}
+
+//poll pushes transactions of outbox events committed since the last poll
+func (b *Broker) poll(ctx context.Context) *models.CustomErr {
+	b.mu.Lock()
+	cursor := b.cursor
+	b.mu.Unlock()
+
+	outbox, cErr := b.Store.GetOutboxEvents(ctx, cursor, pollBatch)
+	if cErr != nil {
+		return cErr
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range outbox {
+		event := &outbox[i]
+		b.cursor = event.Position()
+		b.publish(event)
+	}
+	return nil
+}
+
+//publish pushes the transaction of event to subscribers of its account; a subscriber with a full buffer is
+//dropped rather than waited for. Must be called with b.mu held
+func (b *Broker) publish(event *models.OutboxEvent) {
+	if event.Type != models.EventTypeBalanceChanged || len(b.subs[event.AccountID]) == 0 {
+		return
+	}
+	transaction := models.Transaction{}
+	if err := json.Unmarshal([]byte(event.Payload), &transaction); err != nil {
+		log.Errorf("events: outbox event [%v] not valid: %v", event.ID, err)
+		return
+	}
+	for sub := range b.subs[event.AccountID] {
+		select {
+		case sub.c <- transaction:
+		default:
+			b.drop(sub, ErrOverflow)
+		}
+	}
+}
+
+//drop closes sub with err. Must be called with b.mu held
+func (b *Broker) drop(sub *Subscription, err error) {
+	subs := b.subs[sub.AccountID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subs, sub.AccountID)
+	}
+	sub.err = err
+	close(sub.c)
+}
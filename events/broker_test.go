@@ -0,0 +1,101 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage/memory"
+	mockdb "github.com/dalconoid/balance-service/storage/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestBrokerPoll(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New(10)
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 5000})
+	b := NewBroker(s, time.Second, 10)
+	assert.Equal(t, b.Start(ctx) == nil, true)
+	defer b.Close()
+
+	sub, err := b.Subscribe(2)
+	assert.Equal(t, err, nil)
+	s.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 2000})
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 100})
+	assert.Equal(t, b.poll(ctx) == nil, true)
+
+	//transactions committed before Start and of other accounts are not pushed
+	assert.Equal(t, len(sub.Events()), 1)
+	transaction := <-sub.Events()
+	assert.Equal(t, transaction.AccountID, 2)
+	assert.Equal(t, transaction.Remaining, models.Money(2000))
+
+	b.Unsubscribe(sub)
+	_, ok := <-sub.Events()
+	assert.Equal(t, ok, false)
+	assert.Equal(t, sub.Err(), nil)
+}
+
+func TestBrokerPollSkippedEvents(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	start := models.OutboxPosition{TxID: 10, EventID: 3}
+	mockDb.EXPECT().LastOutboxPosition(gomock.Any()).Return(start, nil).Times(1)
+
+	//the event of a rolled back transaction never shows up, the next one is pushed without waiting
+	event := models.OutboxEvent{ID: 5, TxID: 12, AccountID: 1, Type: models.EventTypeBalanceChanged,
+		Payload: `{"account_id":1,"remaining":100}`}
+	mockDb.EXPECT().GetOutboxEvents(gomock.Any(), start, pollBatch).Return([]models.OutboxEvent{event}, nil).Times(1)
+	mockDb.EXPECT().GetOutboxEvents(gomock.Any(), event.Position(), pollBatch).Return(nil, nil).Times(1)
+
+	b := NewBroker(mockDb, time.Hour, 10)
+	assert.Equal(t, b.Start(ctx) == nil, true)
+	defer b.Close()
+	sub, _ := b.Subscribe(1)
+	assert.Equal(t, b.poll(ctx) == nil, true)
+	assert.Equal(t, len(sub.Events()), 1)
+	assert.Equal(t, b.poll(ctx) == nil, true)
+	assert.Equal(t, b.cursor, models.OutboxPosition{TxID: 12, EventID: 5})
+}
+
+func TestBrokerOverflow(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New(10)
+	b := NewBroker(s, time.Second, 2)
+	b.Start(ctx)
+	defer b.Close()
+
+	slow, _ := b.Subscribe(1)
+	fast, _ := b.Subscribe(1)
+	for i := 0; i < 3; i++ {
+		s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 100})
+		b.poll(ctx)
+		<-fast.Events()
+	}
+
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	assert.Equal(t, received, 2)
+	assert.Equal(t, slow.Err(), ErrOverflow)
+	assert.Equal(t, len(b.subs[1]), 1)
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker(memory.New(10), time.Second, 2)
+	b.Start(context.Background())
+
+	sub, _ := b.Subscribe(1)
+	b.Close()
+	_, ok := <-sub.Events()
+	assert.Equal(t, ok, false)
+	assert.Equal(t, sub.Err(), ErrClosed)
+
+	_, err := b.Subscribe(1)
+	assert.Equal(t, err, ErrClosed)
+}
@@ -1,17 +1,23 @@
 module github.com/dalconoid/balance-service
 
-go 1.13
+go 1.16
 
 require (
 	github.com/go-playground/universal-translator v0.17.0 // indirect
 	github.com/go-playground/validator v9.31.0+incompatible
 	github.com/golang/mock v1.3.1
 	github.com/gorilla/mux v1.8.0
+	github.com/jackc/pgconn v1.8.1
 	github.com/leodido/go-urn v1.2.1 // indirect
 	github.com/magiconair/properties v1.8.1
+	github.com/mattn/go-sqlite3 v1.14.5
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/viper v1.7.1
+	google.golang.org/grpc v1.43.0
+	google.golang.org/protobuf v1.27.1
 	gopkg.in/go-playground/assert.v1 v1.2.1 // indirect
+	gopkg.in/yaml.v2 v2.2.4
 	gorm.io/driver/postgres v1.1.0
+	gorm.io/driver/sqlite v1.1.4
 	gorm.io/gorm v1.21.9
 )
@@ -0,0 +1,310 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/dalconoid/balance-service/balancepb"
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage"
+	"github.com/go-playground/validator"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//Server serves the gRPC API backed by storage
+type Server struct {
+	balancepb.UnimplementedBalanceServer
+	storage storage.Store
+	grpc    *grpc.Server
+}
+
+//New creates a server of storage
+func New(storage storage.Store) *Server {
+	s := &Server{storage: storage, grpc: grpc.NewServer()}
+	balancepb.RegisterBalanceServer(s.grpc, s)
+	return s
+}
+
+//Start starts server
+func (s *Server) Start(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.grpc.Serve(listener)
+}
+
+//Shutdown stops server after the running calls finish or ctx is done; the calls still running then are canceled
+func (s *Server) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		return ctx.Err()
+	}
+}
+
+//GetBalance returns account with the requested id
+func (s *Server) GetBalance(ctx context.Context, request *balancepb.GetBalanceRequest) (*balancepb.Account, error) {
+	if request.Id <= 0 {
+		return nil, invalidArgument("Field [id] not valid: must be greater than [0]")
+	}
+	account, cErr := s.storage.GetBalance(ctx, int(request.Id))
+	if cErr != nil {
+		return nil, storageError(cErr)
+	}
+	return toAccount(account), nil
+}
+
+//GetTransactionHistory streams filtered and sorted transaction history of the requested account
+func (s *Server) GetTransactionHistory(request *balancepb.HistoryRequest, stream balancepb.Balance_GetTransactionHistoryServer) error {
+	hR, err := toHistoryRequest(request)
+	if err != nil {
+		return invalidArgument(err.Error())
+	}
+	cErr := s.storage.StreamTransactionHistory(stream.Context(), hR, func(transaction *models.Transaction) error {
+		return stream.Send(toTransaction(transaction))
+	})
+	if cErr != nil {
+		//the client is gone, its status is not sent anyway
+		if ctxErr := stream.Context().Err(); ctxErr != nil {
+			err := status.FromContextError(ctxErr).Err()
+			logStatus(status.Code(err), cErr.Err.Error())
+			return err
+		}
+		return storageError(cErr)
+	}
+	return nil
+}
+
+//UpdateBalance changes balance of the requested account by delta
+func (s *Server) UpdateBalance(ctx context.Context, request *balancepb.UpdateBalanceRequest) (*balancepb.Transaction, error) {
+	if err := checkAmount("delta", request.Delta); err != nil {
+		return nil, err
+	}
+	chBR := &models.ChangeBalanceRequest{
+		ID:             int(request.Id),
+		Delta:          models.Money(request.Delta),
+		IdempotencyKey: request.IdempotencyKey,
+	}
+	if err := validateRequest(chBR); err != nil {
+		return nil, err
+	}
+	transaction, cErr := s.storage.UpdateBalance(ctx, chBR)
+	if cErr != nil {
+		return nil, storageError(cErr)
+	}
+	return toTransaction(transaction), nil
+}
+
+//MakeTransfer transfers amount between the requested accounts
+func (s *Server) MakeTransfer(ctx context.Context, request *balancepb.TransferRequest) (*balancepb.Transaction, error) {
+	if err := checkAmount("amount", request.Amount); err != nil {
+		return nil, err
+	}
+	if err := checkAmount("fee", request.Fee); err != nil {
+		return nil, err
+	}
+	tR := &models.TransferRequest{
+		ID1:            int(request.FromId),
+		ID2:            int(request.ToId),
+		Delta:          models.Money(request.Amount),
+		Fee:            models.Money(request.Fee),
+		IdempotencyKey: request.IdempotencyKey,
+	}
+	if err := validateRequest(tR); err != nil {
+		return nil, err
+	}
+	transaction, cErr := s.storage.MakeTransfer(ctx, tR)
+	if cErr != nil {
+		return nil, storageError(cErr)
+	}
+	return toTransaction(transaction), nil
+}
+
+//errorStatus maps custom error code to gRPC status
+func errorStatus(cErr *models.CustomErr) error {
+	code := codes.Internal
+	switch cErr.ErrorCode {
+	case models.ErrorInsufficientFundsCode, models.ErrorAccountFrozenCode, models.ErrorAccountClosedCode,
+		models.ErrorHoldNotActiveCode, models.ErrorReversalNotAllowedCode, models.ErrorAccountStatusCode,
+		models.ErrorCreditLimitCode, models.ErrorScheduleStatusCode:
+		code = codes.FailedPrecondition
+	case models.ErrorIdempotencyConflictCode:
+		code = codes.AlreadyExists
+	case models.ErrorNotFoundCode:
+		code = codes.NotFound
+	case models.ErrorHoldAmountExceededCode, models.ErrorScheduleInvalidCode:
+		code = codes.InvalidArgument
+	case models.ErrorLimitExceededCode:
+		code = codes.ResourceExhausted
+	case models.ErrorCanceledCode:
+		if errors.Is(cErr.Err, context.DeadlineExceeded) {
+			code = codes.DeadlineExceeded
+		} else {
+			code = codes.Canceled
+		}
+	}
+	return status.Error(code, cErr.Message())
+}
+
+//validateRequest validates request; returns InvalidArgument status listing validation errors if it is not valid
+func validateRequest(request interface{}) error {
+	errs := validator.New().Struct(request)
+	if errs == nil {
+		return nil
+	}
+	validationErrs, ok := errs.(validator.ValidationErrors)
+	if !ok {
+		return storageError(models.InternalError(errs))
+	}
+	msgs := make([]string, 0)
+	for _, e := range validationErrs {
+		msgs = append(msgs, fmt.Sprintf("[%v]", e))
+	}
+	return invalidArgument("Validation error(s): " + strings.Join(msgs, ", "))
+}
+
+//checkAmount returns InvalidArgument status if amount of field is out of the range of money the HTTP API accepts
+func checkAmount(field string, amount int64) error {
+	if models.Money(amount).InRange() {
+		return nil
+	}
+	return invalidArgument(fmt.Sprintf("Field [%s] not valid: amount [%v] is out of range", field, amount))
+}
+
+//invalidArgument logs msg and returns InvalidArgument status with it
+func invalidArgument(msg string) error {
+	logStatus(codes.InvalidArgument, msg)
+	return status.Error(codes.InvalidArgument, msg)
+}
+
+//storageError logs cErr and returns its status; the description of internal errors is only logged
+func storageError(cErr *models.CustomErr) error {
+	err := errorStatus(cErr)
+	logStatus(status.Code(err), cErr.Err.Error())
+	return err
+}
+
+//logStatus logs description of an error returned with code; server errors are logged at Error level,
+//client errors at Info level
+func logStatus(code codes.Code, description string) {
+	if code == codes.Internal || code == codes.Unavailable {
+		log.Errorf("[%s] %s", code, description)
+		return
+	}
+	log.Infof("[%s] %s", code, description)
+}
+
+//toHistoryRequest converts request to a history query; sort and order default to by-time and asc
+func toHistoryRequest(request *balancepb.HistoryRequest) (*models.HistoryRequest, error) {
+	hR := &models.HistoryRequest{
+		AccountID: int(request.AccountId),
+		Sort:      strings.ToLower(request.Sort),
+		Order:     strings.ToLower(request.Order),
+		Page:      -1,
+		Direction: strings.ToLower(request.Direction),
+		AfterID:   int(request.AfterId),
+	}
+	if hR.AccountID <= 0 {
+		return nil, fmt.Errorf("Field [account_id] not valid: must be greater than [0]")
+	}
+	if hR.Sort == "" {
+		hR.Sort = models.SortByTimeString
+	}
+	if hR.Sort != models.SortBySumString && hR.Sort != models.SortByTimeString {
+		return nil, fmt.Errorf("Field [sort] not valid: valid options are [%s], [%s]", models.SortBySumString, models.SortByTimeString)
+	}
+	if hR.Order == "" {
+		hR.Order = models.OrderAscendingString
+	}
+	if hR.Order != models.OrderAscendingString && hR.Order != models.OrderDescendingString {
+		return nil, fmt.Errorf("Field [order] not valid: valid options are [%s], [%s]", models.OrderAscendingString, models.OrderDescendingString)
+	}
+
+	if request.From != nil {
+		from := request.From.AsTime()
+		hR.From = &from
+	}
+	if request.To != nil {
+		to := request.To.AsTime()
+		hR.To = &to
+	}
+	if hR.From != nil && hR.To != nil && hR.From.After(*hR.To) {
+		return nil, fmt.Errorf("Fields [from], [to] not valid: [from] is after [to]")
+	}
+	if request.MinAmount != nil {
+		min := models.Money(*request.MinAmount)
+		hR.MinAmount = &min
+	}
+	if request.MaxAmount != nil {
+		max := models.Money(*request.MaxAmount)
+		hR.MaxAmount = &max
+	}
+	if hR.MinAmount != nil && *hR.MinAmount < 0 || hR.MaxAmount != nil && *hR.MaxAmount < 0 {
+		return nil, fmt.Errorf("Fields [min_amount], [max_amount] not valid: amounts must be non-negative")
+	}
+	if hR.MinAmount != nil && hR.MaxAmount != nil && *hR.MinAmount > *hR.MaxAmount {
+		return nil, fmt.Errorf("Fields [min_amount], [max_amount] not valid: [min_amount] is greater than [max_amount]")
+	}
+
+	if hR.Direction != "" && hR.Direction != models.DirectionCredit && hR.Direction != models.DirectionDebit {
+		return nil, fmt.Errorf("Field [direction] not valid: valid options are [%s], [%s]", models.DirectionCredit, models.DirectionDebit)
+	}
+	for _, kind := range request.Kinds {
+		kind = strings.ToLower(kind)
+		valid := false
+		for _, k := range models.TransactionKinds {
+			valid = valid || kind == k
+		}
+		if !valid {
+			return nil, fmt.Errorf("Field [kinds] not valid: valid options are [%s]", strings.Join(models.TransactionKinds, "], ["))
+		}
+		hR.Kinds = append(hR.Kinds, kind)
+	}
+	return hR, nil
+}
+
+func toAccount(account *models.Account) *balancepb.Account {
+	return &balancepb.Account{
+		Id:          int64(account.ID),
+		Balance:     int64(account.Balance),
+		Held:        int64(account.Held),
+		CreditLimit: int64(account.CreditLimit),
+		Available:   int64(account.Available),
+		Status:      account.Status,
+		FreezeScope: account.FreezeScope,
+	}
+}
+
+func toTransaction(transaction *models.Transaction) *balancepb.Transaction {
+	t := &balancepb.Transaction{
+		Id:        int64(transaction.ID),
+		AccountId: int64(transaction.AccountID),
+		EntryId:   int64(transaction.EntryID),
+		Kind:      transaction.Kind,
+		CreatedAt: timestamppb.New(transaction.CreatedAt),
+		Delta:     int64(transaction.Delta),
+		Remaining: int64(transaction.Remaining),
+		Message:   transaction.Message,
+		Reversed:  int64(transaction.Reversed),
+	}
+	if transaction.ReversalOf != nil {
+		reversalOf := int64(*transaction.ReversalOf)
+		t.ReversalOf = &reversalOf
+	}
+	return t
+}
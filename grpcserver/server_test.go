@@ -0,0 +1,126 @@
+package grpcserver
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"testing"
+
+	"github.com/dalconoid/balance-service/balancepb"
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage/memory"
+	"github.com/magiconair/properties/assert"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+//dial starts a server of a fresh in-memory store and returns a client connected to it
+func dial(t *testing.T) balancepb.BalanceClient {
+	listener := bufconn.Listen(1 << 20)
+	s := New(memory.New(10))
+	go s.grpc.Serve(listener)
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return balancepb.NewBalanceClient(conn)
+}
+
+func TestBalance(t *testing.T) {
+	client := dial(t)
+	ctx := context.Background()
+
+	_, err := client.UpdateBalance(ctx, &balancepb.UpdateBalanceRequest{Id: 1, Delta: -100})
+	assert.Equal(t, status.Code(err), codes.FailedPrecondition)
+	_, err = client.UpdateBalance(ctx, &balancepb.UpdateBalanceRequest{Id: 0, Delta: 100})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+	_, err = client.UpdateBalance(ctx, &balancepb.UpdateBalanceRequest{Id: 1, Delta: int64(models.MaxMoney) + 1})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+
+	transaction, err := client.UpdateBalance(ctx, &balancepb.UpdateBalanceRequest{Id: 1, Delta: 5000})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, transaction.Remaining, int64(5000))
+
+	transaction, err = client.MakeTransfer(ctx, &balancepb.TransferRequest{FromId: 1, ToId: 2, Amount: 2000, Fee: 100})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, transaction.AccountId, int64(1))
+	assert.Equal(t, transaction.Delta, int64(-2000))
+	_, err = client.MakeTransfer(ctx, &balancepb.TransferRequest{FromId: 1, ToId: 2, Amount: 5000})
+	assert.Equal(t, status.Code(err), codes.FailedPrecondition)
+	_, err = client.MakeTransfer(ctx, &balancepb.TransferRequest{FromId: 1, ToId: 2, Amount: 100, Fee: math.MaxInt64})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+
+	account, err := client.GetBalance(ctx, &balancepb.GetBalanceRequest{Id: 1})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, account.Balance, int64(2900))
+	assert.Equal(t, account.Status, models.AccountStatusActive)
+	_, err = client.GetBalance(ctx, &balancepb.GetBalanceRequest{Id: 0})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+	_, err = client.GetBalance(ctx, &balancepb.GetBalanceRequest{Id: -1})
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+}
+
+func TestGetTransactionHistory(t *testing.T) {
+	client := dial(t)
+	ctx := context.Background()
+	client.UpdateBalance(ctx, &balancepb.UpdateBalanceRequest{Id: 1, Delta: 5000})
+	client.MakeTransfer(ctx, &balancepb.TransferRequest{FromId: 1, ToId: 2, Amount: 2000})
+	client.UpdateBalance(ctx, &balancepb.UpdateBalanceRequest{Id: 1, Delta: 300})
+
+	stream, err := client.GetTransactionHistory(ctx, &balancepb.HistoryRequest{AccountId: 1, Sort: models.SortBySumString, Order: models.OrderDescendingString})
+	assert.Equal(t, err, nil)
+	deltas := make([]int64, 0)
+	for {
+		transaction, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.Equal(t, err, nil)
+		deltas = append(deltas, transaction.Delta)
+	}
+	assert.Equal(t, deltas, []int64{5000, 300, -2000})
+
+	stream, _ = client.GetTransactionHistory(ctx, &balancepb.HistoryRequest{AccountId: 1, Order: "sideways"})
+	_, err = stream.Recv()
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+	stream, _ = client.GetTransactionHistory(ctx, &balancepb.HistoryRequest{AccountId: 0})
+	_, err = stream.Recv()
+	assert.Equal(t, status.Code(err), codes.InvalidArgument)
+}
+
+func TestErrorStatus(t *testing.T) {
+	for code, want := range map[models.ErrorCode]codes.Code{
+		models.ErrorDefaultCode:             codes.Internal,
+		models.ErrorInsufficientFundsCode:   codes.FailedPrecondition,
+		models.ErrorIdempotencyConflictCode: codes.AlreadyExists,
+		models.ErrorNotFoundCode:            codes.NotFound,
+		models.ErrorHoldAmountExceededCode:  codes.InvalidArgument,
+		models.ErrorLimitExceededCode:       codes.ResourceExhausted,
+		models.ErrorCanceledCode:            codes.Canceled,
+	} {
+		err := errorStatus(&models.CustomErr{Err: io.ErrUnexpectedEOF, ErrorCode: code})
+		assert.Equal(t, status.Code(err), want)
+	}
+	//internal details are not sent
+	err := errorStatus(&models.CustomErr{Err: io.ErrUnexpectedEOF, ErrorCode: models.ErrorDefaultCode})
+	assert.Equal(t, status.Convert(err).Message(), "Internal error")
+}
+
+func TestLogStatus(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	logStatus(codes.InvalidArgument, "Field [id] not valid")
+	assert.Equal(t, hook.LastEntry().Level, log.InfoLevel)
+	logStatus(codes.Internal, "database is locked")
+	assert.Equal(t, hook.LastEntry().Level, log.ErrorLevel)
+}
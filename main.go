@@ -1,28 +1,287 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"github.com/dalconoid/balance-service/events"
+	"github.com/dalconoid/balance-service/grpcserver"
+	"github.com/dalconoid/balance-service/models"
 	"github.com/dalconoid/balance-service/server"
 	"github.com/dalconoid/balance-service/storage"
+	"github.com/dalconoid/balance-service/storage/memory"
 	"github.com/dalconoid/balance-service/utils"
+	"github.com/dalconoid/balance-service/webhook"
 	log "github.com/sirupsen/logrus"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
 	configPath := flag.String("config", "config.yaml", "path to application config file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-config path] [migrate up|down|status]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	config, err := utils.LoadConfig(*configPath)
 	if err != nil {
 		log.Fatal(err)
 	}
-	db := &storage.Database{ConnString: config.DBConnectionString, PaginationNum: config.PaginationNumber}
-	err = db.Open()
-	if err != nil {
-		log.Fatal(err)
+	var db storage.Store
+	closeDb := func() error { return nil }
+	switch config.DBDriver {
+	case models.DriverMemory:
+		if flag.Arg(0) == "migrate" {
+			log.Fatal("migrate: in-memory storage has no schema")
+		}
+		log.Warn("Using in-memory storage: data will be lost on exit")
+		memDb := memory.New(config.PaginationNumber)
+		memDb.IdempotencyRetention = config.IdempotencyRetention
+		memDb.ScheduleRetryPolicy = config.ScheduleRetryPolicy
+		db = memDb
+	default:
+		sqlDb := &storage.Database{
+			Driver:               config.DBDriver,
+			ConnString:           config.DBConnectionString,
+			PaginationNum:        config.PaginationNumber,
+			IdempotencyRetention: config.IdempotencyRetention,
+			ScheduleRetryPolicy:  config.ScheduleRetryPolicy,
+			Timeouts:             config.DBTimeouts,
+		}
+		err = sqlDb.Open()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if flag.Arg(0) == "migrate" {
+			migrate(sqlDb, flag.Arg(1))
+			sqlDb.Close()
+			return
+		}
+		if err = sqlDb.CheckSchema(); err != nil {
+			log.Fatal(err)
+		}
+		db = sqlDb
+		closeDb = sqlDb.Close
 	}
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	w := newWorkers()
+	w.run(config.HoldSweepInterval, func(ctx context.Context) {
+		releaseExpiredHolds(ctx, db)
+	})
+	w.run(config.ScheduleInterval, func(ctx context.Context) {
+		runDueSchedules(ctx, db)
+	})
+	w.run(config.IdempotencySweepInterval, func(ctx context.Context) {
+		deleteExpiredIdempotencyKeys(ctx, db)
+	})
+	dispatcher := &webhook.Dispatcher{
+		Store:       db,
+		Client:      &http.Client{Timeout: config.WebhookTimeout},
+		MaxAttempts: config.WebhookMaxAttempts,
+		Backoff:     config.WebhookBackoff,
+		MaxBackoff:  config.WebhookMaxBackoff,
+		BatchSize:   100,
+	}
+	w.run(config.WebhookInterval, func(ctx context.Context) {
+		dispatchWebhooks(ctx, dispatcher)
+	})
+
+	broker := events.NewBroker(db, config.EventsPollInterval, config.EventsBuffer)
+	broker.Heartbeat = config.EventsHeartbeat
+	if cErr := broker.Start(context.Background()); cErr != nil {
+		log.Fatal(cErr.Err)
+	}
+
 	s := server.New()
-	s.ConfigureRouter(db)
-	log.Infof("Starting server on %s", config.ServerAddress)
-	log.Fatal(s.Start(config.ServerAddress))
+	s.ConfigureRouter(db, broker)
+	g := grpcserver.New(db)
+	serverErr := make(chan error, 2)
+	go func() {
+		log.Infof("Starting gRPC server on %s", config.GRPCAddress)
+		serverErr <- g.Start(config.GRPCAddress)
+	}()
+	go func() {
+		log.Infof("Starting server on %s", config.ServerAddress)
+		serverErr <- s.Start(config.ServerAddress)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err = <-serverErr:
+		log.Fatal(err)
+	case sig := <-signals:
+		log.Infof("Received [%v], shutting down", sig)
+	}
+	//a second signal stops the server at once
+	signal.Reset(syscall.SIGINT, syscall.SIGTERM)
+
+	s.Drain()
+	time.Sleep(config.ShutdownDrainDelay)
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownGracePeriod)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, stop := range []func(ctx context.Context) error{s.Shutdown, g.Shutdown, w.shutdown} {
+		wg.Add(1)
+		go func(stop func(ctx context.Context) error) {
+			defer wg.Done()
+			if err := stop(ctx); err != nil {
+				log.Error(err.Error())
+			}
+		}(stop)
+	}
+	wg.Wait()
+	if err = closeDb(); err != nil {
+		log.Error(err.Error())
+	}
+	log.Info("Server stopped")
+}
+
+//migrate runs migrate subcommand
+func migrate(db *storage.Database, command string) {
+	switch command {
+	case "up":
+		applied, err := db.MigrateUp()
+		for _, migration := range applied {
+			log.Infof("Applied migration [%v] %s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			log.Info("Schema is up to date")
+		}
+	case "down":
+		reverted, err := db.MigrateDown()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if reverted == nil {
+			log.Info("No migrations to revert")
+			return
+		}
+		log.Infof("Reverted migration [%v] %s", reverted.Version, reverted.Name)
+	case "status":
+		statuses, err := db.MigrationStatus()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied at " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %s: %s\n", status.Version, status.Name, applied)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+//workers run background jobs until shutdown
+type workers struct {
+	stop chan struct{}
+	//ctx of the jobs is canceled when the grace period of shutdown is over
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWorkers() *workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workers{stop: make(chan struct{}), ctx: ctx, cancel: cancel}
+}
+
+//run runs job every interval; zero interval disables job
+func (w *workers) run(interval time.Duration, job func(ctx context.Context)) {
+	if interval <= 0 {
+		return
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				job(w.ctx)
+			}
+		}
+	}()
+}
+
+//shutdown stops starting jobs and waits for the running ones to finish until ctx is done, then cancels them
+func (w *workers) shutdown(ctx context.Context) error {
+	close(w.stop)
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		w.cancel()
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+//releaseExpiredHolds releases expired holds
+func releaseExpiredHolds(ctx context.Context, db storage.Store) {
+	released, cErr := db.ReleaseExpiredHolds(ctx)
+	if cErr != nil {
+		log.Error(cErr.Err.Error())
+	}
+	if released > 0 {
+		log.Infof("Released [%v] expired hold(s)", released)
+	}
+}
+
+//deleteExpiredIdempotencyKeys deletes idempotency keys past their retention
+func deleteExpiredIdempotencyKeys(ctx context.Context, db storage.Store) {
+	deleted, cErr := db.DeleteExpiredIdempotencyKeys(ctx)
+	if cErr != nil {
+		log.Error(cErr.Err.Error())
+	}
+	if deleted > 0 {
+		log.Infof("Deleted [%v] expired idempotency key(s)", deleted)
+	}
+}
+
+//runDueSchedules makes transfers of due schedules
+func runDueSchedules(ctx context.Context, db storage.Store) {
+	runs, cErr := db.RunDueSchedules(ctx)
+	if cErr != nil {
+		log.Error(cErr.Err.Error())
+	}
+	if runs > 0 {
+		log.Infof("Made [%v] scheduled transfer run(s)", runs)
+	}
+}
+
+//dispatchWebhooks delivers outbox events to webhooks
+func dispatchWebhooks(ctx context.Context, d *webhook.Dispatcher) {
+	delivered, cErr := d.Dispatch(ctx)
+	if cErr != nil {
+		log.Error(cErr.Err.Error())
+	}
+	if delivered > 0 {
+		log.Infof("Delivered [%v] webhook event(s)", delivered)
+	}
 }
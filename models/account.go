@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+const (
+	//account statuses
+	AccountStatusActive = "active"
+	AccountStatusFrozen = "frozen"
+	AccountStatusClosed = "closed"
+
+	//freeze scopes: a frozen account rejects debits only or all movements
+	FreezeScopeDebits = "debits"
+	FreezeScopeAll    = "all"
+)
+
+//AccountStatusChange - account status history record; SweepEntryID is the journal entry moving the rest
+//of the balance of a closed account
+type AccountStatusChange struct {
+	ID             int `gorm:"primaryKey; column:status_change_id"`
+	AccountID      int
+	PreviousStatus string
+	Status         string
+	FreezeScope    string `json:"FreezeScope,omitempty"`
+	Reason         string
+	SweepEntryID   *int `json:"SweepEntryID,omitempty"`
+	CreatedAt      time.Time
+}
+
+//Headroom returns how much can be debited from account a including its credit line
+func (a *Account) Headroom() Money {
+	return a.Balance - a.Held + a.CreditLimit
+}
+
+//Accepts reports whether the status of account a allows to change its balance by delta
+func (a *Account) Accepts(delta Money) bool {
+	switch a.Status {
+	case "", AccountStatusActive:
+		return true
+	case AccountStatusFrozen:
+		return a.FreezeScope == FreezeScopeDebits && delta >= 0
+	}
+	return false
+}
+
+//FreezeAccountRequest is a model which handleFreezeAccount expects; Scope defaults to debits
+type FreezeAccountRequest struct {
+	AccountID int    `json:"-" validate:"required,gt=0"`
+	Scope     string `json:"scope" validate:"omitempty,oneof=debits all"`
+	Reason    string `json:"reason" validate:"required,max=1000"`
+}
+
+//UnfreezeAccountRequest is a model which handleUnfreezeAccount expects
+type UnfreezeAccountRequest struct {
+	AccountID int    `json:"-" validate:"required,gt=0"`
+	Reason    string `json:"reason" validate:"required,max=1000"`
+}
+
+//CreditLimitRequest is a model which handleSetCreditLimit expects; zero CreditLimit removes the credit line
+type CreditLimitRequest struct {
+	AccountID   int   `json:"-" validate:"required,gt=0"`
+	CreditLimit Money `json:"credit_limit" validate:"gte=0"`
+}
+
+//CloseAccountRequest is a model which handleCloseAccount expects; the rest of the balance is swept to SweepTo,
+//without SweepTo the balance must be zero
+type CloseAccountRequest struct {
+	AccountID int    `json:"-" validate:"required,gt=0"`
+	SweepTo   int    `json:"sweep_to" validate:"omitempty,gt=0,nefield=AccountID"`
+	Reason    string `json:"reason" validate:"required,max=1000"`
+}
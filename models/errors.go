@@ -0,0 +1,143 @@
+package models
+
+import "fmt"
+
+//ErrorCode - code of CustomErr; its name is the machine-readable code reported by the API and never changes
+type ErrorCode int
+
+const (
+	//errors of storage operations
+	ErrorDefaultCode             ErrorCode = 0
+	ErrorInsufficientFundsCode   ErrorCode = 1
+	ErrorIdempotencyConflictCode ErrorCode = 2
+	ErrorNotFoundCode            ErrorCode = 3
+	ErrorHoldNotActiveCode       ErrorCode = 4
+	ErrorHoldAmountExceededCode  ErrorCode = 5
+	ErrorReversalNotAllowedCode  ErrorCode = 6
+	ErrorAccountFrozenCode       ErrorCode = 7
+	ErrorAccountClosedCode       ErrorCode = 8
+	ErrorAccountStatusCode       ErrorCode = 9
+	ErrorCreditLimitCode         ErrorCode = 10
+	ErrorLimitExceededCode       ErrorCode = 11
+	ErrorScheduleStatusCode      ErrorCode = 12
+	ErrorScheduleInvalidCode     ErrorCode = 13
+	ErrorCanceledCode            ErrorCode = 14
+
+	//errors of requests rejected before they reach storage
+	ErrorBadRequestCode    ErrorCode = 15
+	ErrorValidationCode    ErrorCode = 16
+	ErrorNotAcceptableCode ErrorCode = 17
+	ErrorUnavailableCode   ErrorCode = 18
+)
+
+//errorCodeNames are the names of error codes
+var errorCodeNames = map[ErrorCode]string{
+	ErrorDefaultCode:             "internal",
+	ErrorInsufficientFundsCode:   "insufficient_funds",
+	ErrorIdempotencyConflictCode: "idempotency_conflict",
+	ErrorNotFoundCode:            "not_found",
+	ErrorHoldNotActiveCode:       "hold_not_active",
+	ErrorHoldAmountExceededCode:  "hold_amount_exceeded",
+	ErrorReversalNotAllowedCode:  "reversal_not_allowed",
+	ErrorAccountFrozenCode:       "account_frozen",
+	ErrorAccountClosedCode:       "account_closed",
+	ErrorAccountStatusCode:       "account_status",
+	ErrorCreditLimitCode:         "credit_limit",
+	ErrorLimitExceededCode:       "limit_exceeded",
+	ErrorScheduleStatusCode:      "schedule_status",
+	ErrorScheduleInvalidCode:     "schedule_invalid",
+	ErrorCanceledCode:            "canceled",
+	ErrorBadRequestCode:          "bad_request",
+	ErrorValidationCode:          "validation_failed",
+	ErrorNotAcceptableCode:       "not_acceptable",
+	ErrorUnavailableCode:         "unavailable",
+}
+
+//String returns the name of c; unknown codes are internal errors
+func (c ErrorCode) String() string {
+	if name, ok := errorCodeNames[c]; ok {
+		return name
+	}
+	return errorCodeNames[ErrorDefaultCode]
+}
+
+//MarshalText marshals c as its name
+func (c ErrorCode) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+//UnmarshalText parses the name of an error code
+func (c *ErrorCode) UnmarshalText(text []byte) error {
+	for code, name := range errorCodeNames {
+		if name == string(text) {
+			*c = code
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown error code [%s]", text)
+}
+
+//CustomErr - error of a storage operation. Err of an ErrorDefaultCode error may carry database details, so it is
+//only logged and Message hides it
+type CustomErr struct {
+	Err       error
+	ErrorCode ErrorCode
+}
+
+//Error returns the description of e with internal details
+func (e *CustomErr) Error() string {
+	return e.Err.Error()
+}
+
+//Unwrap returns Err
+func (e *CustomErr) Unwrap() error {
+	return e.Err
+}
+
+//NewError returns CustomErr of err with code
+func NewError(code ErrorCode, err error) *CustomErr {
+	return &CustomErr{Err: err, ErrorCode: code}
+}
+
+//NewErrorf returns CustomErr with code and the error formatted according to format
+func NewErrorf(code ErrorCode, format string, args ...interface{}) *CustomErr {
+	return NewError(code, fmt.Errorf(format, args...))
+}
+
+//InternalError returns internal CustomErr of err; its description is not shown to clients
+func InternalError(err error) *CustomErr {
+	return NewError(ErrorDefaultCode, err)
+}
+
+//NotFoundError returns CustomErr telling that entity with id does not exist
+func NotFoundError(entity string, id int) *CustomErr {
+	return NewErrorf(ErrorNotFoundCode, "%s [%v] not found", entity, id)
+}
+
+//Message returns the description of e which can be shown to clients
+func (e *CustomErr) Message() string {
+	if e.ErrorCode == ErrorDefaultCode {
+		return "Internal error"
+	}
+	return e.Err.Error()
+}
+
+//Problem - RFC 7807 error response of the API; Code tells what went wrong, Detail is a human readable description
+//and Errors list the failed validations of request fields
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      ErrorCode    `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+//FieldError - failed validation Rule of request field Field, e.g. rule "gt=0" of field "Transfers[1].Delta"
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestErrorCodeNames(t *testing.T) {
+	names := make(map[string]bool)
+	for code := ErrorDefaultCode; code <= ErrorUnavailableCode; code++ {
+		name := code.String()
+		assert.Equal(t, names[name], false)
+		names[name] = true
+
+		var parsed ErrorCode
+		assert.Equal(t, parsed.UnmarshalText([]byte(name)), nil)
+		assert.Equal(t, parsed, code)
+	}
+	assert.Equal(t, ErrorCode(100).String(), "internal")
+
+	data, _ := json.Marshal(&ScheduleRun{ErrorCode: new(ErrorCode)})
+	assert.Matches(t, string(data), `"ErrorCode":"internal"`)
+}
+
+func TestCustomErr(t *testing.T) {
+	cErr := &CustomErr{Err: fmt.Errorf("GetBalance: %w", errors.New("pq: password authentication failed")), ErrorCode: ErrorDefaultCode}
+	assert.Equal(t, cErr.Message(), "Internal error")
+	assert.Equal(t, cErr.Error(), "GetBalance: pq: password authentication failed")
+
+	cErr = &CustomErr{Err: &LimitExceededError{AccountID: 1, Limit: LimitHourlyTransfers}, ErrorCode: ErrorLimitExceededCode}
+	var limitErr *LimitExceededError
+	assert.Equal(t, errors.As(cErr, &limitErr), true)
+	assert.Equal(t, cErr.Message(), cErr.Err.Error())
+}
+
+func TestErrorConstructors(t *testing.T) {
+	cErr := NotFoundError("hold", 7)
+	assert.Equal(t, cErr.ErrorCode, ErrorNotFoundCode)
+	assert.Equal(t, cErr.Message(), "hold [7] not found")
+
+	cause := errors.New("database is locked")
+	cErr = InternalError(cause)
+	assert.Equal(t, cErr.ErrorCode, ErrorDefaultCode)
+	assert.Equal(t, errors.Is(cErr, cause), true)
+
+	cErr = NewErrorf(ErrorCanceledCode, "operation canceled: %w", cause)
+	assert.Equal(t, errors.Is(cErr, cause), true)
+}
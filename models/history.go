@@ -0,0 +1,119 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	//MaxHistoryLimit is the maximal number of transactions on a history page requested by cursor
+	MaxHistoryLimit = 1000
+
+	//history direction filters
+	DirectionCredit = "credit"
+	DirectionDebit  = "debit"
+)
+
+//TransactionKinds are valid values of the history kind filter
+var TransactionKinds = []string{
+	TransactionKindDeposit, TransactionKindWithdrawal, TransactionKindTransferIn, TransactionKindTransferOut,
+	TransactionKindCapture, TransactionKindFee, TransactionKindReversal, TransactionKindSweep,
+}
+
+//HistoryRequest is a query of account transaction history. Non-zero Page selects LIMIT/OFFSET pagination
+//(negative Page is the whole history), otherwise Limit transactions after Cursor are returned
+type HistoryRequest struct {
+	AccountID int
+	Sort      string
+	Order     string
+	Page      int
+	Cursor    *HistoryCursor
+	//Limit defaults to the storage pagination number
+	Limit int
+
+	//filters; the time range and amount bounds are inclusive, amount is the absolute value of delta
+	From      *time.Time
+	To        *time.Time
+	MinAmount *Money
+	MaxAmount *Money
+	Direction string
+	Kinds     []string
+	//AfterID keeps transactions with greater ids, those made after transaction AfterID
+	AfterID int
+}
+
+//Matches reports whether transaction passes the filters of request
+func (r *HistoryRequest) Matches(t *Transaction) bool {
+	if t.ID <= r.AfterID {
+		return false
+	}
+	if r.From != nil && t.CreatedAt.Before(*r.From) || r.To != nil && t.CreatedAt.After(*r.To) {
+		return false
+	}
+	if r.MinAmount != nil && t.Delta.Abs() < *r.MinAmount || r.MaxAmount != nil && t.Delta.Abs() > *r.MaxAmount {
+		return false
+	}
+	if r.Direction == DirectionCredit && t.Delta < 0 || r.Direction == DirectionDebit && t.Delta > 0 {
+		return false
+	}
+	if len(r.Kinds) == 0 {
+		return true
+	}
+	for _, kind := range r.Kinds {
+		if t.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+//HistoryPage is a page of transaction history; cursors are empty when there is no next or previous page
+type HistoryPage struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"next_cursor,omitempty"`
+	PrevCursor   string        `json:"prev_cursor,omitempty"`
+}
+
+//HistoryCursor is a position in transaction history sorted by Sort in Order; ties are broken by transaction ID.
+//Backward cursor points to the page preceding the position
+type HistoryCursor struct {
+	Sort      string    `json:"s"`
+	Order     string    `json:"o"`
+	Backward  bool      `json:"b,omitempty"`
+	CreatedAt time.Time `json:"t"`
+	Delta     Money     `json:"d"`
+	ID        int       `json:"i"`
+}
+
+//NewHistoryCursor returns cursor positioned at transaction
+func NewHistoryCursor(transaction *Transaction, sort string, order string, backward bool) *HistoryCursor {
+	return &HistoryCursor{
+		Sort:      sort,
+		Order:     order,
+		Backward:  backward,
+		CreatedAt: transaction.CreatedAt,
+		Delta:     transaction.Delta,
+		ID:        transaction.ID,
+	}
+}
+
+//String encodes cursor as an opaque token
+func (c *HistoryCursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+//ParseHistoryCursor decodes a token made by HistoryCursor.String
+func ParseHistoryCursor(token string) (*HistoryCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("cursor: malformed token")
+	}
+	cursor := &HistoryCursor{}
+	if err = json.Unmarshal(data, cursor); err != nil || cursor.ID <= 0 {
+		return nil, fmt.Errorf("cursor: malformed token")
+	}
+	return cursor, nil
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+const (
+	//hold statuses
+	HoldStatusActive   = "active"
+	HoldStatusCaptured = "captured"
+	HoldStatusReleased = "released"
+	HoldStatusExpired  = "expired"
+)
+
+//Hold - funds reservation model; Amount is reserved until the hold is captured, released or expired
+type Hold struct {
+	ID        int `gorm:"primaryKey; column:hold_id"`
+	AccountID int
+	OrderID   string
+	Amount    Money
+	Captured  Money
+	Status    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ExpiresAt *time.Time
+}
+
+//Expired reports whether an active hold is past its expiry at t
+func (h *Hold) Expired(t time.Time) bool {
+	return h.Status == HoldStatusActive && h.ExpiresAt != nil && !h.ExpiresAt.After(t)
+}
+
+//HoldRequest is a model which handlePlaceHold expects
+type HoldRequest struct {
+	AccountID int        `json:"account_id" validate:"required,gt=0"`
+	Amount    Money      `json:"amount" validate:"required,gt=0"`
+	OrderID   string     `json:"order_id" validate:"required,max=255"`
+	ExpiresAt *time.Time `json:"expires_at" validate:"omitempty,gt"`
+}
+
+//CaptureHoldRequest is a model which handleCaptureHold expects; zero Amount captures the whole hold
+type CaptureHoldRequest struct {
+	HoldID int   `json:"-" validate:"required,gt=0"`
+	Amount Money `json:"amount" validate:"gte=0"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+const (
+	//system accounts; they have negative ids and may have negative balance
+	SystemAccountCashIn  = -1
+	SystemAccountCashOut = -2
+	SystemAccountFees    = -3
+
+	//journal entry kinds
+	EntryKindDeposit    = "deposit"
+	EntryKindWithdrawal = "withdrawal"
+	EntryKindTransfer   = "transfer"
+	EntryKindCapture    = "capture"
+	EntryKindReversal   = "reversal"
+	EntryKindSweep      = "sweep"
+
+	//transaction (journal entry leg) kinds
+	TransactionKindDeposit     = "deposit"
+	TransactionKindWithdrawal  = "withdrawal"
+	TransactionKindTransferIn  = "transfer-in"
+	TransactionKindTransferOut = "transfer-out"
+	TransactionKindCapture     = "capture"
+	TransactionKindFee         = "fee"
+	TransactionKindReversal    = "reversal"
+	TransactionKindSweep       = "sweep"
+)
+
+//SystemAccountNames maps system account ids to their names
+var SystemAccountNames = map[int]string{
+	SystemAccountCashIn:  "cash-in",
+	SystemAccountCashOut: "cash-out",
+	SystemAccountFees:    "fees",
+}
+
+//IsSystemAccount reports whether id belongs to a system account
+func IsSystemAccount(id int) bool {
+	return id < 0
+}
+
+//SystemAccount - system account model
+type SystemAccount struct {
+	ID      int
+	Name    string
+	Balance Money
+}
+
+//JournalEntry - journal entry model; the deltas of transactions with EntryID=ID sum to zero
+type JournalEntry struct {
+	ID        int `gorm:"primaryKey; column:entry_id"`
+	Kind      string
+	CreatedAt time.Time
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	//spending limit names
+	LimitDailyDebit      = "daily_debit"
+	LimitHourlyTransfers = "hourly_transfers"
+	LimitSingleTransfer  = "single_transfer"
+
+	//rolling windows of the limits
+	DailyDebitWindow      = 24 * time.Hour
+	HourlyTransfersWindow = time.Hour
+)
+
+//SpendingKinds are the kinds of transactions counted as outgoing money by spending limits
+var SpendingKinds = []string{TransactionKindWithdrawal, TransactionKindTransferOut, TransactionKindCapture, TransactionKindFee}
+
+//SpendingLimits - spending limits of an account; a nil limit is not checked. DailyDebit limits the sum of outgoing
+//money for the last DailyDebitWindow, HourlyTransfers the number of transfers for the last HourlyTransfersWindow and
+//SingleTransfer the amount of one transfer
+type SpendingLimits struct {
+	AccountID       int    `gorm:"primaryKey" json:"-" validate:"required,gt=0"`
+	DailyDebit      *Money `json:"daily_debit" validate:"omitempty,gte=0"`
+	HourlyTransfers *int   `json:"hourly_transfers" validate:"omitempty,gte=0"`
+	SingleTransfer  *Money `json:"single_transfer" validate:"omitempty,gte=0"`
+}
+
+//SpendingUsage - outgoing money of an account within the windows of spending limits, the checked operation included;
+//OldestTransfer is the time of the oldest transfer counted in HourlyTransfers
+type SpendingUsage struct {
+	DailyDebit      Money
+	HourlyTransfers int
+	OldestTransfer  time.Time
+}
+
+//LimitExceededError - error of an operation breaching spending limit Limit of an account; RetryAfter is set
+//when the operation can succeed later
+type LimitExceededError struct {
+	AccountID  int
+	Limit      string
+	Value      string
+	Max        string
+	RetryAfter time.Duration
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("spending limit [%s] of account [%v] exceeded: [%s] of [%s] allowed", e.Limit, e.AccountID, e.Value, e.Max)
+}
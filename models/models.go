@@ -1,14 +1,14 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 const (
-	//custom error codes
-	ErrorDefaultCode           = 0
-	ErrorInsufficientFundsCode = 1
-
-	//name of database constraint
-	InsufficientFundsMessage = "non_negative_balance"
+	//names of database constraints
+	InsufficientFundsMessage          = "non_negative_balance"
+	InsufficientAvailableFundsMessage = "non_negative_available"
 
 	//valid URL query "sorted" param values
 	SortByTimeString = "by-time"
@@ -17,39 +17,107 @@ const (
 	//valid URL query "order" param values
 	OrderAscendingString  = "asc"
 	OrderDescendingString = "desc"
+
+	//name of idempotency key HTTP header
+	IdempotencyKeyHeader = "Idempotency-Key"
+	//name of HTTP header carrying the request id; the id is generated unless the client sends one
+	RequestIDHeader = "X-Request-ID"
+	//content type of error responses
+	ProblemContentType = "application/problem+json"
+
+	//idempotency key scopes: a key can only be replayed on the operation it was created with
+	IdempotencyScopeChangeBalance = "change-balance"
+	IdempotencyScopeTransfer      = "transfer"
+
+	//valid config DB.DRIVER values
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
 )
 
-//Account - account model
+//Account - account model; Balance is the ledger balance, Held is reserved by active holds and the balance
+//may go down to -CreditLimit. FreezeScope is set while the account is frozen
 type Account struct {
-	ID      int `gorm:"primaryKey; column:account_id"`
-	Balance float64
+	ID          int `gorm:"primaryKey; column:account_id"`
+	Balance     Money
+	Held        Money
+	CreditLimit Money
+	Available   Money `gorm:"-"`
+	Status      string
+	FreezeScope string `json:"FreezeScope,omitempty"`
+}
+
+//BalanceAsOf - account balance at time AsOf; TransactionID is the last transaction before AsOf, nil if there was none
+type BalanceAsOf struct {
+	ID            int
+	Balance       Money
+	AsOf          time.Time
+	TransactionID *int
 }
 
-//Transaction - transaction model
+//Transaction - transaction model, a leg of journal entry EntryID; ReversalOf is the transaction
+//compensated by this one and Reversed is the amount compensated so far
 type Transaction struct {
-	ID        int `gorm:"primaryKey; column:transaction_id"`
-	AccountID int
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	Delta     float64
-	Remaining float64
-	Message   string
+	ID         int `gorm:"primaryKey; column:transaction_id"`
+	AccountID  int
+	EntryID    int
+	Kind       string
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	Delta      Money
+	Remaining  Money
+	Message    string
+	ReversalOf *int
+	Reversed   Money
 }
 
-//CustomErr - custom error model
-type CustomErr struct {
-	Err       error
-	ErrorCode int
+//IdempotencyKey - stored response of a request made with Idempotency-Key header
+type IdempotencyKey struct {
+	Key         string `gorm:"primaryKey; column:idempotency_key"`
+	RequestHash string
+	Response    string
+	CreatedAt   time.Time
+}
+
+//Timeouts - timeouts of storage operations; an operation, named as its Store method, missing from Operations
+//has timeout Default. Zero timeout is no timeout
+type Timeouts struct {
+	Default    time.Duration
+	Operations map[string]time.Duration
+}
+
+//Of returns timeout of operation; operation names are case-insensitive
+func (t Timeouts) Of(operation string) time.Duration {
+	for name, timeout := range t.Operations {
+		if strings.EqualFold(name, operation) {
+			return timeout
+		}
+	}
+	return t.Default
 }
 
 //ChangeBalanceRequest is a model which handleChangeBalance expects
 type ChangeBalanceRequest struct {
-	ID    int     `validate:"required,gt=0"`
-	Delta float64 `validate:"required"`
+	ID             int    `validate:"required,gt=0"`
+	Delta          Money  `validate:"required"`
+	IdempotencyKey string `json:"-" validate:"max=255"`
 }
 
-//TransferRequest is a model which handleTransfer expects
+//ReverseRequest is a model which handleReverseTransaction expects; zero Amount reverses the rest of the transaction
+type ReverseRequest struct {
+	TransactionID int   `json:"-" validate:"required,gt=0"`
+	Amount        Money `json:"amount" validate:"gte=0"`
+}
+
+//TransferRequest is a model which handleTransfer expects; Fee is charged from ID1 to the fees system account
 type TransferRequest struct {
-	ID1   int     `validate:"required,gt=0"`
-	ID2   int     `validate:"required,nefield=ID1,gt=0"`
-	Delta float64 `validate:"required,gt=0"`
+	ID1            int    `validate:"required,gt=0"`
+	ID2            int    `validate:"required,nefield=ID1,gt=0"`
+	Delta          Money  `validate:"required,gt=0"`
+	Fee            Money  `validate:"gte=0"`
+	IdempotencyKey string `json:"-" validate:"max=255"`
+}
+
+//BatchTransferRequest is a model which handleBatchTransfer expects
+type BatchTransferRequest struct {
+	Transfers []TransferRequest `json:"transfers" validate:"required,min=1,max=1000,dive"`
 }
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	//MoneyScale is the number of decimal places (minor units) of Money
+	MoneyScale = 2
+	//maxMoneyDigits is the precision of NUMERIC(18, 2) columns
+	maxMoneyDigits = 18
+)
+
+//Money is an exact amount of money in minor units (cents)
+type Money int64
+
+//MaxMoney is the largest amount NUMERIC(18, 2) columns hold
+const MaxMoney Money = 999999999999999999
+
+//ParseMoney parses a decimal string like "-10.5"; more than MoneyScale decimal places is an error
+func ParseMoney(s string) (Money, error) {
+	str := strings.TrimSpace(s)
+	neg := false
+	if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+		neg = str[0] == '-'
+		str = str[1:]
+	}
+	intPart, fracPart := str, ""
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		intPart, fracPart = str[:i], str[i+1:]
+	}
+	if intPart == "" || !isDigits(intPart) || !isDigits(fracPart) || (strings.Contains(str, ".") && fracPart == "") {
+		return 0, fmt.Errorf("money: invalid amount [%s]", s)
+	}
+	fracPart = strings.TrimRight(fracPart, "0")
+	if len(fracPart) > MoneyScale {
+		return 0, fmt.Errorf("money: amount [%s] has more than %d decimal places", s, MoneyScale)
+	}
+	intPart = strings.TrimLeft(intPart, "0")
+	if len(intPart) > maxMoneyDigits-MoneyScale {
+		return 0, fmt.Errorf("money: amount [%s] is out of range", s)
+	}
+	fracPart += strings.Repeat("0", MoneyScale-len(fracPart))
+
+	v, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("money: invalid amount [%s]", s)
+	}
+	if neg {
+		v = -v
+	}
+	return Money(v), nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+//InRange returns true if m fits NUMERIC(18, 2) columns, the amounts ParseMoney accepts
+func (m Money) InRange() bool {
+	return m >= -MaxMoney && m <= MaxMoney
+}
+
+//Abs returns absolute value of m
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+//String formats m with exactly MoneyScale decimal places
+func (m Money) String() string {
+	v := int64(m)
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	unit := int64(math.Pow10(MoneyScale))
+	return fmt.Sprintf("%s%d.%0*d", sign, v/unit, MoneyScale, v%unit)
+}
+
+//MarshalJSON encodes m as a JSON number
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+//UnmarshalJSON decodes a JSON number or a numeric string
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+//Value implements driver.Valuer; amounts are passed to the database as decimal strings
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+//Scan implements sql.Scanner for NUMERIC columns
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = 0
+	case int64:
+		*m = Money(v * int64(math.Pow10(MoneyScale)))
+	case float64:
+		//SQLite returns NUMERIC values with a fractional part as REAL
+		*m = Money(math.Round(v * math.Pow10(MoneyScale)))
+	case []byte:
+		return m.Scan(string(v))
+	case string:
+		parsed, err := ParseMoney(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	default:
+		return fmt.Errorf("money: cannot scan %T", value)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	valid := map[string]Money{
+		"0":       0,
+		"10":      1000,
+		"-10.5":   -1050,
+		"0.01":    1,
+		"+1.10":   110,
+		"12.3400": 1234,
+	}
+	for s, expected := range valid {
+		m, err := ParseMoney(s)
+		assert.Equal(t, err == nil, true, s)
+		assert.Equal(t, m, expected, s)
+	}
+
+	for _, s := range []string{"", "-", "1.", ".5", "1.001", "1e2", "abc", "10000000000000000"} {
+		_, err := ParseMoney(s)
+		assert.Equal(t, err != nil, true, s)
+	}
+
+	//InRange matches what ParseMoney accepts
+	lowest, _ := ParseMoney("-9999999999999999.99")
+	assert.Equal(t, lowest, -MaxMoney)
+	assert.Equal(t, lowest.InRange(), true)
+	assert.Equal(t, (MaxMoney + 1).InRange(), false)
+}
+
+func TestMoneyJSON(t *testing.T) {
+	req := &ChangeBalanceRequest{}
+	err := json.Unmarshal([]byte(`{"id": 1, "delta": -0.1}`), req)
+	assert.Equal(t, err == nil, true)
+	assert.Equal(t, req.Delta, Money(-10))
+
+	err = json.Unmarshal([]byte(`{"id": 1, "delta": 0.105}`), req)
+	assert.Equal(t, err != nil, true)
+
+	data, _ := json.Marshal(Account{ID: 1, Balance: -705, Status: AccountStatusActive})
+	assert.Equal(t, string(data), `{"ID":1,"Balance":-7.05,"Held":0.00,"CreditLimit":0.00,"Available":0.00,"Status":"active"}`)
+}
+
+func TestMoneyScan(t *testing.T) {
+	var m Money
+	for value, expected := range map[interface{}]Money{
+		"70.10":   7010,
+		int64(3):  300,
+		0.1 + 0.2: 30,
+		"-0.50":   -50,
+	} {
+		assert.Equal(t, m.Scan(value) == nil, true)
+		assert.Equal(t, m, expected)
+	}
+}
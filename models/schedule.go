@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+const (
+	//schedule statuses
+	ScheduleStatusActive    = "active"
+	ScheduleStatusPaused    = "paused"
+	ScheduleStatusCompleted = "completed"
+	ScheduleStatusDeleted   = "deleted"
+
+	//schedule run statuses
+	ScheduleRunSucceeded = "succeeded"
+	ScheduleRunFailed    = "failed"
+)
+
+//RetryPolicy - how failed runs of a schedule are retried
+type RetryPolicy struct {
+	//MaxRetries is how many times a failed run is retried before the occurrence is skipped
+	MaxRetries int
+	//Interval is the delay before each retry
+	Interval time.Duration
+}
+
+//Schedule - transfer made once at a future time or repeatedly by a cron expression; Cron is empty for a one-off
+//schedule. OccurrenceAt is the time the current run is due, NextRunAt is the time of its next attempt,
+//later than OccurrenceAt while the run is retried. RetryInterval is in seconds. Version changes with every run
+//and status change
+type Schedule struct {
+	ID            int `gorm:"primaryKey; column:schedule_id"`
+	FromID        int
+	ToID          int
+	Amount        Money
+	Fee           Money
+	Cron          string `json:"Cron,omitempty"`
+	Status        string
+	MaxRetries    int
+	RetryInterval int
+	Attempt       int
+	OccurrenceAt  time.Time
+	NextRunAt     time.Time
+	Version       int `json:"-"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+//ScheduleRun - attempt to make the transfer of a schedule; RetryAt is set if a failed run will be retried
+type ScheduleRun struct {
+	ID            int `gorm:"primaryKey; column:run_id"`
+	ScheduleID    int
+	OccurrenceAt  time.Time
+	Attempt       int
+	Status        string
+	TransactionID *int       `json:"TransactionID,omitempty"`
+	ErrorCode     *ErrorCode `json:"ErrorCode,omitempty"`
+	Error         string     `json:"Error,omitempty"`
+	RetryAt       *time.Time `json:"RetryAt,omitempty"`
+	CreatedAt     time.Time
+}
+
+//ScheduleRequest is a model which handleCreateSchedule expects; exactly one of RunAt and Cron is set.
+//MaxRetries and RetryInterval (in seconds) default to the configured retry policy
+type ScheduleRequest struct {
+	FromID        int        `json:"from_id" validate:"required,gt=0"`
+	ToID          int        `json:"to_id" validate:"required,nefield=FromID,gt=0"`
+	Amount        Money      `json:"amount" validate:"required,gt=0"`
+	Fee           Money      `json:"fee" validate:"gte=0"`
+	RunAt         *time.Time `json:"run_at" validate:"required_without=Cron"`
+	Cron          string     `json:"cron" validate:"max=255"`
+	MaxRetries    *int       `json:"max_retries" validate:"omitempty,gte=0,lte=100"`
+	RetryInterval *int       `json:"retry_interval" validate:"omitempty,gt=0"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+const (
+	//outbox event types
+	EventTypeBalanceChanged = "balance.changed"
+
+	//webhook delivery statuses
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusFailed    = "failed"
+)
+
+//OutboxEvent - event written in the database transaction that caused it and delivered to webhooks afterwards;
+//Payload is a JSON document, the transaction of a balance.changed event. TxID is the id of the database transaction
+//that wrote the event, set by the database where transactions may commit out of the order of event ids
+type OutboxEvent struct {
+	ID            int   `gorm:"primaryKey; column:event_id"`
+	TxID          int64 `gorm:"column:txid; ->" json:"-"`
+	Type          string
+	AccountID     int
+	TransactionID int
+	Payload       string
+	Dispatched    bool `json:"-"`
+	CreatedAt     time.Time
+}
+
+//Position returns the position of the event in the outbox
+func (e *OutboxEvent) Position() OutboxPosition {
+	return OutboxPosition{TxID: e.TxID, EventID: e.ID}
+}
+
+//OutboxPosition - position in the outbox; events are ordered by TxID and then by event id
+type OutboxPosition struct {
+	TxID    int64
+	EventID int
+}
+
+//Webhook - URL which events are posted to, signed with Secret; a webhook with AccountID gets only events of that account
+type Webhook struct {
+	ID        int `gorm:"primaryKey; column:webhook_id"`
+	URL       string
+	Secret    string `json:"-"`
+	AccountID *int   `json:"AccountID,omitempty"`
+	CreatedAt time.Time
+}
+
+//WebhookDelivery - delivery of an event to a webhook; it keeps the result of the last attempt.
+//Version changes when the delivery is claimed for an attempt or redelivered
+type WebhookDelivery struct {
+	ID            int `gorm:"primaryKey; column:delivery_id"`
+	WebhookID     int
+	EventID       int
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	StatusCode    *int   `json:"StatusCode,omitempty"`
+	Error         string `json:"Error,omitempty"`
+	Version       int    `json:"-"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeliveredAt   *time.Time `json:"DeliveredAt,omitempty"`
+}
+
+//DeliveryTask - delivery claimed for an attempt together with its webhook and event
+type DeliveryTask struct {
+	Delivery WebhookDelivery
+	Webhook  Webhook
+	Event    OutboxEvent
+}
+
+//WebhookRequest is a model which handleCreateWebhook expects
+type WebhookRequest struct {
+	URL       string `json:"url" validate:"required,url,max=2048"`
+	Secret    string `json:"secret" validate:"required,min=16,max=255"`
+	AccountID *int   `json:"account_id" validate:"omitempty,gt=0"`
+}
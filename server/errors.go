@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/go-playground/validator"
+	log "github.com/sirupsen/logrus"
+)
+
+//maxRequestIDLength is the longest request id accepted from a client
+const maxRequestIDLength = 128
+
+type contextKey int
+
+//requestIDKey is the context key of the request id
+const requestIDKey contextKey = 0
+
+//withRequestID returns r with the request id in its context and sets it in the response header
+func withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := r.Header.Get(models.RequestIDHeader)
+	if id == "" || len(id) > maxRequestIDLength {
+		buf := make([]byte, 16)
+		rand.Read(buf)
+		id = hex.EncodeToString(buf)
+	}
+	w.Header().Set(models.RequestIDHeader, id)
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+}
+
+//requestID returns the id of request r
+func requestID(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey).(string); ok {
+		return id
+	}
+	return r.Header.Get(models.RequestIDHeader)
+}
+
+//writeProblem writes problem+json response with status and code to w and logs it; detail is shown to the client
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code models.ErrorCode, detail string,
+	fields ...models.FieldError) {
+	logProblem(r, status, code, detail)
+	encodeProblem(w, r, status, code, detail, fields)
+}
+
+//writeError writes error cErr of storage to w; the description of internal errors is only logged
+func writeError(w http.ResponseWriter, r *http.Request, cErr *models.CustomErr) {
+	status := errorStatus(cErr)
+	logProblem(r, status, cErr.ErrorCode, cErr.Error())
+	setRetryAfter(w, cErr)
+	encodeProblem(w, r, status, cErr.ErrorCode, cErr.Message(), nil)
+}
+
+//logProblem logs response with status and code; server errors are logged at Error level, client errors at Info level
+func logProblem(r *http.Request, status int, code models.ErrorCode, description string) {
+	entry := log.WithField("request_id", requestID(r))
+	if status >= http.StatusInternalServerError {
+		entry.Errorf("[%v] %s: %s", status, code, description)
+		return
+	}
+	entry.Infof("[%v] %s: %s", status, code, description)
+}
+
+//encodeProblem writes problem+json response with status and code to w
+func encodeProblem(w http.ResponseWriter, r *http.Request, status int, code models.ErrorCode, detail string,
+	fields []models.FieldError) {
+	problem := &models.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: requestID(r),
+		Errors:    fields,
+	}
+	w.Header().Set("Content-Type", models.ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+//errorStatus maps custom error code to HTTP status code
+func errorStatus(cErr *models.CustomErr) int {
+	switch cErr.ErrorCode {
+	case models.ErrorInsufficientFundsCode, models.ErrorAccountFrozenCode, models.ErrorAccountClosedCode:
+		return http.StatusForbidden
+	case models.ErrorIdempotencyConflictCode, models.ErrorHoldNotActiveCode, models.ErrorReversalNotAllowedCode,
+		models.ErrorAccountStatusCode, models.ErrorCreditLimitCode, models.ErrorScheduleStatusCode:
+		return http.StatusConflict
+	case models.ErrorNotFoundCode:
+		return http.StatusNotFound
+	case models.ErrorHoldAmountExceededCode, models.ErrorScheduleInvalidCode:
+		return http.StatusBadRequest
+	case models.ErrorLimitExceededCode:
+		//a rate limit passes with time unless it is zero, amount limits do not
+		var limitErr *models.LimitExceededError
+		if errors.As(cErr.Err, &limitErr) && limitErr.Limit == models.LimitHourlyTransfers && limitErr.RetryAfter > 0 {
+			return http.StatusTooManyRequests
+		}
+		return http.StatusForbidden
+	case models.ErrorCanceledCode:
+		if errors.Is(cErr.Err, context.DeadlineExceeded) {
+			return http.StatusGatewayTimeout
+		}
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+//setRetryAfter sets Retry-After header if the operation failed with cErr can succeed later
+func setRetryAfter(w http.ResponseWriter, cErr *models.CustomErr) {
+	var limitErr *models.LimitExceededError
+	if errors.As(cErr.Err, &limitErr) && limitErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.RetryAfter.Seconds()))))
+	}
+}
+
+//validateRequest validates request and writes validation errors to w; returns false if request is not valid
+func validateRequest(w http.ResponseWriter, r *http.Request, request interface{}) bool {
+	v := validator.New()
+	errs := v.Struct(request)
+	if errs == nil {
+		return true
+	}
+	validationErrs, ok := errs.(validator.ValidationErrors)
+	if !ok {
+		//request is not a struct, so the handler is broken rather than the request
+		writeError(w, r, models.InternalError(errs))
+		return false
+	}
+	fields := make([]models.FieldError, 0)
+	msgs := make([]string, 0)
+	for _, e := range validationErrs {
+		rule := e.Tag()
+		if e.Param() != "" {
+			rule += "=" + e.Param()
+		}
+		//the namespace starts with the name of the request struct
+		field := e.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+		fields = append(fields, models.FieldError{Field: field, Rule: rule, Message: fieldMessage(e)})
+		msgs = append(msgs, fmt.Sprintf("[%s] %s", field, fieldMessage(e)))
+	}
+	detail := "Validation error(s): " + strings.Join(msgs, ", ")
+	writeProblem(w, r, http.StatusBadRequest, models.ErrorValidationCode, detail, fields...)
+	return false
+}
+
+//fieldMessage describes failed field validation e
+func fieldMessage(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required":
+		return "is required"
+	case "required_without":
+		return fmt.Sprintf("is required without [%s]", e.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than [%s]", e.Param())
+	case "gte":
+		return fmt.Sprintf("must be at least [%s]", e.Param())
+	case "lte":
+		return fmt.Sprintf("must be at most [%s]", e.Param())
+	case "min":
+		return fmt.Sprintf("must have at least [%s] element(s) or characters", e.Param())
+	case "max":
+		return fmt.Sprintf("must have at most [%s] element(s) or characters", e.Param())
+	case "nefield":
+		return fmt.Sprintf("must differ from [%s]", e.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s]", strings.Join(strings.Fields(e.Param()), "], ["))
+	case "url":
+		return "must be a URL"
+	}
+	return fmt.Sprintf("failed on the [%s] rule", e.Tag())
+}
@@ -3,222 +3,1333 @@ package server
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/dalconoid/balance-service/events"
 	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/statement"
 	"github.com/dalconoid/balance-service/storage"
-	"github.com/go-playground/validator"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
-func handleAlive() http.HandlerFunc {
+//handleAlive reports that the server is ready; 503 once draining returns true
+func handleAlive(draining func() bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if draining() {
+			writeProblem(w, r, http.StatusServiceUnavailable, models.ErrorUnavailableCode, "Server is shutting down")
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
+func handleNotFound() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, http.StatusNotFound, models.ErrorNotFoundCode, fmt.Sprintf("Route [%s] not found", r.URL.Path))
+	}
+}
+
+func handleMethodNotAllowed() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, http.StatusMethodNotAllowed, models.ErrorBadRequestCode,
+			fmt.Sprintf("Method [%s] not allowed for route [%s]", r.Method, r.URL.Path))
+	}
+}
+
+func handleOpenAPI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(openAPISpec)
+	}
+}
+
 func handleGetBalance(storage storage.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		params := mux.Vars(r)
 		strId := params["id"]
 		id, err := strconv.Atoi(strId)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			log.Error(err.Error())
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		if strAsOf := r.URL.Query().Get("as_of"); strAsOf != "" {
+			asOf, err := time.Parse(time.RFC3339Nano, strAsOf)
+			if err != nil {
+				msg := "Query param [as_of] not valid: param must be RFC 3339 timestamp like [2006-01-02T15:04:05Z]"
+				writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, msg)
+				return
+			}
+			balance, cErr := storage.GetBalanceAsOf(r.Context(), id, asOf)
+			if cErr != nil {
+				writeError(w, r, cErr)
+				return
+			}
+			data, err := json.Marshal(balance)
+			if err != nil {
+				writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+				return
+			}
+			w.Write(data)
 			return
 		}
 
-		account, cErr := storage.GetBalance(id)
+		account, cErr := storage.GetBalance(r.Context(), id)
 		if cErr != nil {
-			http.Error(w, fmt.Sprintf("[%v]", cErr.Err.Error()), http.StatusInternalServerError)
-			log.Error(cErr.Err.Error())
+			writeError(w, r, cErr)
 			return
 		}
 		data, err := json.Marshal(account)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("JSON Marshalling failed. [%v]", err), http.StatusInternalServerError)
-			log.Error(err.Error())
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
 			return
 		}
 		w.Write(data)
 	}
 }
 
-func handleChangeBalance(storage storage.Store) http.HandlerFunc {
+func handleGetStatement(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		query := r.URL.Query()
+		format := strings.ToLower(query.Get("format"))
+		if format != "" {
+			valid := false
+			for _, f := range statement.Formats {
+				valid = valid || format == f
+			}
+			if !valid {
+				msg := fmt.Sprintf("Query param [format] not valid: valid options are [%s]", strings.Join(statement.Formats, "], ["))
+				writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, msg)
+				return
+			}
+		} else {
+			var ok bool
+			if format, ok = statement.FormatByAccept(r.Header.Get("Accept")); !ok {
+				msg := fmt.Sprintf("Header [Accept] not valid: supported formats are [%s]", strings.Join(statement.Formats, "], ["))
+				writeProblem(w, r, http.StatusNotAcceptable, models.ErrorNotAcceptableCode, msg)
+				return
+			}
+		}
+
+		from, err := parseTimeParam(query, "from")
+		if err == nil && from == nil {
+			err = fmt.Errorf("Query param [from] is required")
+		}
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+		now := time.Now()
+		to, err := parseTimeParam(query, "to")
+		if err == nil && to == nil {
+			to = &now
+		}
+		if err == nil && from.After(*to) {
+			err = fmt.Errorf("Query params [from], [to] not valid: [from] is after [to]")
+		}
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		//opening balance is the balance right before from
+		opening, cErr := storage.GetBalanceAsOf(r.Context(), id, from.Add(-time.Nanosecond))
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+		closing, cErr := storage.GetBalanceAsOf(r.Context(), id, *to)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		stmt := &statement.Statement{
+			AccountID: id,
+			From:      *from,
+			To:        *to,
+			Opening:   opening.Balance,
+			Closing:   closing.Balance,
+			CreatedAt: now,
+		}
+		streamStatement(w, r, storage, stmt, format)
+	}
+}
+
+//streamStatement writes stmt with transactions read from storage as they are encoded;
+//once the response is started errors can only be logged and the statement is cut short
+func streamStatement(w http.ResponseWriter, r *http.Request, storage storage.Store, stmt *statement.Statement, format string) {
+	encoder, err := statement.NewEncoder(format, w)
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", statement.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", statement.FileName(stmt, format)))
+
+	if err = encoder.Begin(stmt); err != nil {
+		log.Error(err.Error())
+		return
+	}
+	request := &models.HistoryRequest{
+		AccountID: stmt.AccountID,
+		Sort:      models.SortByTimeString,
+		Order:     models.OrderAscendingString,
+		From:      &stmt.From,
+		To:        &stmt.To,
+	}
+	if cErr := storage.StreamTransactionHistory(r.Context(), request, encoder.Transaction); cErr != nil {
+		log.Error(cErr.Err.Error())
+		return
+	}
+	if err = encoder.End(stmt); err != nil {
+		log.Error(err.Error())
+	}
+}
+
+func handleFreezeAccount(storage storage.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
 		data, err := ioutil.ReadAll(r.Body)
 		defer r.Body.Close()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			log.Error(err.Error())
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
 			return
 		}
 
-		chBR := &models.ChangeBalanceRequest{}
-		if err = json.Unmarshal(data, chBR); err != nil {
-			http.Error(w, fmt.Sprintf("JSON Unmarshalling failed. [%v]", err), http.StatusBadRequest)
-			log.Error(err.Error())
+		fR := &models.FreezeAccountRequest{}
+		if err = json.Unmarshal(data, fR); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, fmt.Sprintf("JSON Unmarshalling failed. [%v]", err))
 			return
 		}
+		fR.AccountID = id
 
-		v := validator.New()
-		errs := v.Struct(chBR)
-		if errs != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			logMsg := "Validation error(s):\n"
-			fmt.Fprint(w, "Validation error(s):\n")
-			for _, e := range errs.(validator.ValidationErrors) {
-				w.Write([]byte(fmt.Sprintf("%v\n", e)))
-				logMsg += fmt.Sprintf("[%v]\n", e)
-			}
-			log.Error(logMsg)
+		if !validateRequest(w, r, fR) {
 			return
 		}
 
-		transaction, cErr := storage.UpdateBalance(chBR)
+		account, cErr := storage.FreezeAccount(r.Context(), fR)
 		if cErr != nil {
-			if cErr.ErrorCode != models.ErrorInsufficientFundsCode {
-				http.Error(w, cErr.Err.Error(), http.StatusInternalServerError)
-				return
-			}
-			http.Error(w, cErr.Err.Error(), http.StatusForbidden)
-			log.Error(cErr.Err.Error())
+			writeError(w, r, cErr)
 			return
 		}
 
-		data, err = json.Marshal(transaction)
+		data, err = json.Marshal(account)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("JSON Marshalling failed. [%v]", err), http.StatusInternalServerError)
-			log.Error(err.Error())
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleUnfreezeAccount(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		data, err := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		uR := &models.UnfreezeAccountRequest{}
+		if err = json.Unmarshal(data, uR); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, fmt.Sprintf("JSON Unmarshalling failed. [%v]", err))
+			return
+		}
+		uR.AccountID = id
+
+		if !validateRequest(w, r, uR) {
+			return
+		}
+
+		account, cErr := storage.UnfreezeAccount(r.Context(), uR)
+		if cErr != nil {
+			writeError(w, r, cErr)
 			return
 		}
 
+		data, err = json.Marshal(account)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
 		w.Write(data)
 	}
 }
 
-func handleTransfer(storage storage.Store) http.HandlerFunc {
+func handleCloseAccount(storage storage.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
 		data, err := ioutil.ReadAll(r.Body)
 		defer r.Body.Close()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			log.Error(err.Error())
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
 			return
 		}
 
-		tR := &models.TransferRequest{}
-		if err = json.Unmarshal(data, tR); err != nil {
-			http.Error(w, fmt.Sprintf("JSON Unmarshalling failed. [%v]", err), http.StatusBadRequest)
-			log.Error(err.Error())
+		cR := &models.CloseAccountRequest{}
+		if err = json.Unmarshal(data, cR); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, fmt.Sprintf("JSON Unmarshalling failed. [%v]", err))
 			return
 		}
+		cR.AccountID = id
 
-		v := validator.New()
-		errs := v.Struct(tR)
-		if errs != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			logMsg := "Validation error(s):\n"
-			fmt.Fprint(w, "Validation error(s):\n")
-			for _, e := range errs.(validator.ValidationErrors) {
-				w.Write([]byte(fmt.Sprintf("%v\n", e)))
-				logMsg += fmt.Sprintf("[%v]\n", e)
-			}
-			log.Error(logMsg)
+		if !validateRequest(w, r, cR) {
 			return
 		}
 
-		transaction, cErr := storage.MakeTransfer(tR)
+		account, cErr := storage.CloseAccount(r.Context(), cR)
 		if cErr != nil {
-			if cErr.ErrorCode != models.ErrorInsufficientFundsCode {
-				http.Error(w, cErr.Err.Error(), http.StatusInternalServerError)
-				return
-			}
-			http.Error(w, cErr.Err.Error(), http.StatusForbidden)
-			log.Error(cErr.Err.Error())
+			writeError(w, r, cErr)
 			return
 		}
 
-		data, err = json.Marshal(transaction)
+		data, err = json.Marshal(account)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleGetSpendingLimits(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
 		if err != nil {
-			http.Error(w, fmt.Sprintf("JSON Marshalling failed. [%v]", err), http.StatusInternalServerError)
-			log.Error(err.Error())
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		limits, cErr := storage.GetSpendingLimits(r.Context(), id)
+		if cErr != nil {
+			writeError(w, r, cErr)
 			return
 		}
 
+		data, err := json.Marshal(limits)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
 		w.Write(data)
 	}
 }
 
-func handleGetTransactions(storage storage.Store) http.HandlerFunc {
+func handleSetSpendingLimits(storage storage.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		params := mux.Vars(r)
-		strId := params["id"]
-		id, err := strconv.Atoi(strId)
+		id, err := strconv.Atoi(params["id"])
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			log.Error(err)
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
 			return
 		}
 
-		sorting := strings.ToLower(r.URL.Query().Get("sort"))
-		if sorting != "" && sorting != models.SortBySumString && sorting != models.SortByTimeString {
-			msg := fmt.Sprintf("Query param [sort] not valid: valid options are [%s], [%s]", models.SortBySumString, models.SortByTimeString)
-			http.Error(w, msg, http.StatusBadRequest)
-			log.Error(msg)
+		data, err := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
 			return
 		}
-		if sorting == "" {
-			sorting = models.SortByTimeString
+
+		limits := &models.SpendingLimits{}
+		if err = json.Unmarshal(data, limits); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, fmt.Sprintf("JSON Unmarshalling failed. [%v]", err))
+			return
 		}
+		limits.AccountID = id
 
-		order := strings.ToLower(r.URL.Query().Get("order"))
-		if order != "" && order != models.OrderAscendingString && order != models.OrderDescendingString {
-			msg := fmt.Sprintf("Query param [order] not valid: valid options are [%s], [%s]", models.OrderAscendingString, models.OrderDescendingString)
-			http.Error(w, msg, http.StatusBadRequest)
-			log.Error(msg)
+		if !validateRequest(w, r, limits) {
 			return
 		}
-		if order == "" {
-			order = models.OrderAscendingString
+
+		limits, cErr := storage.SetSpendingLimits(r.Context(), limits)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
 		}
 
-		var page int
-		strPage := r.URL.Query().Get("page")
-		if strPage != "" {
-			page, err = strconv.Atoi(strPage)
-			if err != nil {
-				msg := "Query param [page] not valid: param must be integer number"
-				http.Error(w, msg, http.StatusBadRequest)
-				log.Error(msg)
-				return
-			}
-			if page == 0 {
-				page = 1
-			}
-		} else {
-			page = -1
+		data, err = json.Marshal(limits)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleSetCreditLimit(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		data, err := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		lR := &models.CreditLimitRequest{}
+		if err = json.Unmarshal(data, lR); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, fmt.Sprintf("JSON Unmarshalling failed. [%v]", err))
+			return
+		}
+		lR.AccountID = id
+
+		if !validateRequest(w, r, lR) {
+			return
 		}
 
-		history, cErr := storage.GetTransactionHistory(id, sorting, order, page)
+		account, cErr := storage.SetCreditLimit(r.Context(), lR)
 		if cErr != nil {
-			http.Error(w, cErr.Err.Error(), http.StatusInternalServerError)
-			log.Error(cErr.Err.Error())
+			writeError(w, r, cErr)
 			return
 		}
 
+		data, err = json.Marshal(account)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleGetAccountStatusHistory(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		changes, cErr := storage.GetAccountStatusHistory(r.Context(), id)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err := json.Marshal(changes)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleGetSystemAccounts(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accounts, cErr := storage.GetSystemAccounts(r.Context())
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+		data, err := json.Marshal(accounts)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleChangeBalance(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		chBR := &models.ChangeBalanceRequest{}
+		if err = json.Unmarshal(data, chBR); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, fmt.Sprintf("JSON Unmarshalling failed. [%v]", err))
+			return
+		}
+		chBR.IdempotencyKey = r.Header.Get(models.IdempotencyKeyHeader)
+
+		if !validateRequest(w, r, chBR) {
+			return
+		}
 
-		data, err := json.Marshal(history)
+		transaction, cErr := storage.UpdateBalance(r.Context(), chBR)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
 
+		data, err = json.Marshal(transaction)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("JSON Marshalling failed. [%v]", err), http.StatusInternalServerError)
-			log.Error(err)
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
 			return
 		}
 
 		w.Write(data)
 	}
 }
+
+func handleTransfer(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		tR := &models.TransferRequest{}
+		if err = json.Unmarshal(data, tR); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, fmt.Sprintf("JSON Unmarshalling failed. [%v]", err))
+			return
+		}
+		tR.IdempotencyKey = r.Header.Get(models.IdempotencyKeyHeader)
+
+		if !validateRequest(w, r, tR) {
+			return
+		}
+
+		transaction, cErr := storage.MakeTransfer(r.Context(), tR)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err = json.Marshal(transaction)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+
+		w.Write(data)
+	}
+}
+
+func handleGetTransactions(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		strId := params["id"]
+		id, err := strconv.Atoi(strId)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		sorting := strings.ToLower(r.URL.Query().Get("sort"))
+		if sorting != "" && sorting != models.SortBySumString && sorting != models.SortByTimeString {
+			msg := fmt.Sprintf("Query param [sort] not valid: valid options are [%s], [%s]", models.SortBySumString, models.SortByTimeString)
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, msg)
+			return
+		}
+		if sorting == "" {
+			sorting = models.SortByTimeString
+		}
+
+		order := strings.ToLower(r.URL.Query().Get("order"))
+		if order != "" && order != models.OrderAscendingString && order != models.OrderDescendingString {
+			msg := fmt.Sprintf("Query param [order] not valid: valid options are [%s], [%s]", models.OrderAscendingString, models.OrderDescendingString)
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, msg)
+			return
+		}
+		if order == "" {
+			order = models.OrderAscendingString
+		}
+
+		var page int
+		strPage := r.URL.Query().Get("page")
+		if strPage != "" {
+			page, err = strconv.Atoi(strPage)
+			if err != nil {
+				msg := "Query param [page] not valid: param must be integer number"
+				writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, msg)
+				return
+			}
+			if page == 0 {
+				page = 1
+			}
+		}
+
+		request := &models.HistoryRequest{AccountID: id, Sort: sorting, Order: order, Page: page}
+		query := r.URL.Query()
+		_, byCursor := query["cursor"]
+		_, byLimit := query["limit"]
+		if byCursor || byLimit {
+			if page != 0 {
+				msg := "Query params [page] and [cursor]/[limit] cannot be used together"
+				writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, msg)
+				return
+			}
+			if strLimit := query.Get("limit"); strLimit != "" {
+				request.Limit, err = strconv.Atoi(strLimit)
+				if err != nil || request.Limit < 1 || request.Limit > models.MaxHistoryLimit {
+					msg := fmt.Sprintf("Query param [limit] not valid: param must be integer number from 1 to %v", models.MaxHistoryLimit)
+					writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, msg)
+					return
+				}
+			}
+			if token := query.Get("cursor"); token != "" {
+				request.Cursor, err = models.ParseHistoryCursor(token)
+				if err == nil && (request.Cursor.Sort != sorting || request.Cursor.Order != order) {
+					err = fmt.Errorf("cursor was issued for sort [%s] and order [%s]", request.Cursor.Sort, request.Cursor.Order)
+				}
+				if err != nil {
+					msg := fmt.Sprintf("Query param [cursor] not valid: %v", err)
+					writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, msg)
+					return
+				}
+			}
+		} else if page == 0 {
+			request.Page = -1
+		}
+
+		if err = parseHistoryFilters(query, request); err != nil {
+			msg := err.Error()
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, msg)
+			return
+		}
+
+		history, cErr := storage.GetTransactionHistory(r.Context(), request)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		//page pagination keeps the plain list response
+		var data []byte
+		if request.Page != 0 {
+			data, err = json.Marshal(history.Transactions)
+		} else {
+			data, err = json.Marshal(history)
+		}
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+
+		w.Write(data)
+	}
+}
+
+func handleGetHold(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		hold, cErr := storage.GetHold(r.Context(), id)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err := json.Marshal(hold)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handlePlaceHold(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		hR := &models.HoldRequest{}
+		if err = json.Unmarshal(data, hR); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, fmt.Sprintf("JSON Unmarshalling failed. [%v]", err))
+			return
+		}
+
+		if !validateRequest(w, r, hR) {
+			return
+		}
+
+		hold, cErr := storage.PlaceHold(r.Context(), hR)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err = json.Marshal(hold)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleCaptureHold(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		data, err := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		cR := &models.CaptureHoldRequest{}
+		//body is optional: without amount the whole hold is captured
+		if len(strings.TrimSpace(string(data))) > 0 {
+			if err = json.Unmarshal(data, cR); err != nil {
+				writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, fmt.Sprintf("JSON Unmarshalling failed. [%v]", err))
+				return
+			}
+		}
+		cR.HoldID = id
+
+		if !validateRequest(w, r, cR) {
+			return
+		}
+
+		transaction, cErr := storage.CaptureHold(r.Context(), cR)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err = json.Marshal(transaction)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleReleaseHold(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		hold, cErr := storage.ReleaseHold(r.Context(), id)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err := json.Marshal(hold)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleReverseTransaction(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		data, err := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		rR := &models.ReverseRequest{}
+		//body is optional: without amount the rest of the transaction is reversed
+		if len(strings.TrimSpace(string(data))) > 0 {
+			if err = json.Unmarshal(data, rR); err != nil {
+				writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, fmt.Sprintf("JSON Unmarshalling failed. [%v]", err))
+				return
+			}
+		}
+		rR.TransactionID = id
+
+		if !validateRequest(w, r, rR) {
+			return
+		}
+
+		reversals, cErr := storage.ReverseTransaction(r.Context(), rR)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err = json.Marshal(reversals)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleBatchTransfer(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		bR := &models.BatchTransferRequest{}
+		if err = json.Unmarshal(data, bR); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, fmt.Sprintf("JSON Unmarshalling failed. [%v]", err))
+			return
+		}
+
+		if !validateRequest(w, r, bR) {
+			return
+		}
+
+		transactions, cErr := storage.MakeBatchTransfer(r.Context(), bR)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err = json.Marshal(transactions)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+//parseHistoryFilters sets filters of request from query params from, to, min, max, direction and kind
+func parseHistoryFilters(query url.Values, request *models.HistoryRequest) error {
+	var err error
+	if request.From, err = parseTimeParam(query, "from"); err != nil {
+		return err
+	}
+	if request.To, err = parseTimeParam(query, "to"); err != nil {
+		return err
+	}
+	if request.From != nil && request.To != nil && request.From.After(*request.To) {
+		return fmt.Errorf("Query params [from], [to] not valid: [from] is after [to]")
+	}
+
+	for _, param := range []struct {
+		name   string
+		target **models.Money
+	}{{"min", &request.MinAmount}, {"max", &request.MaxAmount}} {
+		if str := query.Get(param.name); str != "" {
+			amount, err := models.ParseMoney(str)
+			if err != nil || amount < 0 {
+				return fmt.Errorf("Query param [%s] not valid: param must be non-negative amount with at most %v decimal places",
+					param.name, models.MoneyScale)
+			}
+			*param.target = &amount
+		}
+	}
+	if request.MinAmount != nil && request.MaxAmount != nil && *request.MinAmount > *request.MaxAmount {
+		return fmt.Errorf("Query params [min], [max] not valid: [min] is greater than [max]")
+	}
+
+	request.Direction = strings.ToLower(query.Get("direction"))
+	if request.Direction != "" && request.Direction != models.DirectionCredit && request.Direction != models.DirectionDebit {
+		return fmt.Errorf("Query param [direction] not valid: valid options are [%s], [%s]", models.DirectionCredit, models.DirectionDebit)
+	}
+
+	if str := strings.ToLower(query.Get("kind")); str != "" {
+		for _, kind := range strings.Split(str, ",") {
+			valid := false
+			for _, k := range models.TransactionKinds {
+				valid = valid || kind == k
+			}
+			if !valid {
+				return fmt.Errorf("Query param [kind] not valid: valid options are [%s]", strings.Join(models.TransactionKinds, "], ["))
+			}
+			request.Kinds = append(request.Kinds, kind)
+		}
+	}
+	return nil
+}
+
+//parseTimeParam parses optional RFC 3339 query param name
+func parseTimeParam(query url.Values, name string) (*time.Time, error) {
+	str := query.Get(name)
+	if str == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, str)
+	if err != nil {
+		return nil, fmt.Errorf("Query param [%s] not valid: param must be RFC 3339 timestamp like [2006-01-02T15:04:05Z]", name)
+	}
+	return &t, nil
+}
+
+func handleCreateSchedule(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		sR := &models.ScheduleRequest{}
+		if err = json.Unmarshal(data, sR); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, fmt.Sprintf("JSON Unmarshalling failed. [%v]", err))
+			return
+		}
+
+		if !validateRequest(w, r, sR) {
+			return
+		}
+
+		schedule, cErr := storage.CreateSchedule(r.Context(), sR)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err = json.Marshal(schedule)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleGetSchedule(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		result, cErr := storage.GetSchedule(r.Context(), id)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleGetSchedules(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		result, cErr := storage.GetSchedules(r.Context(), id)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleGetScheduleRuns(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		result, cErr := storage.GetScheduleRuns(r.Context(), id)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handlePauseSchedule(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		result, cErr := storage.PauseSchedule(r.Context(), id)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleResumeSchedule(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		result, cErr := storage.ResumeSchedule(r.Context(), id)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleDeleteSchedule(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		result, cErr := storage.DeleteSchedule(r.Context(), id)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleCreateWebhook(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		wR := &models.WebhookRequest{}
+		if err = json.Unmarshal(data, wR); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, fmt.Sprintf("JSON Unmarshalling failed. [%v]", err))
+			return
+		}
+
+		if !validateRequest(w, r, wR) {
+			return
+		}
+
+		webhook, cErr := storage.CreateWebhook(r.Context(), wR)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err = json.Marshal(webhook)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleGetWebhooks(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		webhooks, cErr := storage.GetWebhooks(r.Context())
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+		data, err := json.Marshal(webhooks)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleDeleteWebhook(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		result, cErr := storage.DeleteWebhook(r.Context(), id)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleGetWebhookDeliveries(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		result, cErr := storage.GetWebhookDeliveries(r.Context(), id)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func handleRedeliverWebhookDelivery(storage storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		result, cErr := storage.RedeliverWebhookDelivery(r.Context(), id)
+		if cErr != nil {
+			writeError(w, r, cErr)
+			return
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, fmt.Sprintf("JSON Marshalling failed. [%v]", err))
+			return
+		}
+		w.Write(data)
+	}
+}
+
+//handleGetEvents streams transactions of an account as server-sent events. Given the id of the last received
+//transaction in header Last-Event-ID or query param after, transactions made since are sent first
+func handleGetEvents(storage storage.Store, broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		id, err := strconv.Atoi(params["id"])
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, err.Error())
+			return
+		}
+
+		strAfter := r.Header.Get("Last-Event-ID")
+		if strAfter == "" {
+			strAfter = r.URL.Query().Get("after")
+		}
+		after := 0
+		if strAfter != "" {
+			after, err = strconv.Atoi(strAfter)
+			if err != nil || after < 0 {
+				msg := "Header [Last-Event-ID] or query param [after] not valid: must be a transaction id"
+				writeProblem(w, r, http.StatusBadRequest, models.ErrorBadRequestCode, msg)
+				return
+			}
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			msg := "Streaming is not supported"
+			writeProblem(w, r, http.StatusInternalServerError, models.ErrorDefaultCode, msg)
+			return
+		}
+
+		//subscribing before the replay so that no transaction falls between them
+		sub, err := broker.Subscribe(id)
+		if err != nil {
+			writeProblem(w, r, http.StatusServiceUnavailable, models.ErrorUnavailableCode, err.Error())
+			return
+		}
+		defer broker.Unsubscribe(sub)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		if after > 0 {
+			request := &models.HistoryRequest{
+				AccountID: id,
+				Sort:      models.SortByTimeString,
+				Order:     models.OrderAscendingString,
+				Limit:     models.MaxHistoryLimit,
+				AfterID:   after,
+			}
+			for {
+				//the stream is started, so the client is left to reconnect and resume
+				history, cErr := storage.GetTransactionHistory(r.Context(), request)
+				if cErr != nil {
+					log.Error(cErr.Err.Error())
+					return
+				}
+				for i := range history.Transactions {
+					if err = writeEvent(w, &history.Transactions[i]); err != nil {
+						log.Error(err.Error())
+						return
+					}
+					if history.Transactions[i].ID > after {
+						after = history.Transactions[i].ID
+					}
+				}
+				if history.NextCursor == "" {
+					break
+				}
+				request.Cursor, _ = models.ParseHistoryCursor(history.NextCursor)
+			}
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(broker.Heartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				if _, err = fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+			case transaction, ok := <-sub.Events():
+				if !ok {
+					fmt.Fprintf(w, "event: close\ndata: %s\n\n", sub.Err())
+					flusher.Flush()
+					return
+				}
+				//already sent by the replay
+				if transaction.ID <= after {
+					continue
+				}
+				if err = writeEvent(w, &transaction); err != nil {
+					log.Error(err.Error())
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+//writeEvent writes transaction as a server-sent event with its id as the event id
+func writeEvent(w http.ResponseWriter, transaction *models.Transaction) error {
+	data, err := json.Marshal(transaction)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %v\nevent: transaction\ndata: %s\n\n", transaction.ID, data)
+	return err
+}
@@ -2,14 +2,15 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/dalconoid/balance-service/events"
 	"github.com/dalconoid/balance-service/models"
 	mockdb "github.com/dalconoid/balance-service/storage/mock"
 	"github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
 	"github.com/magiconair/properties/assert"
-	"math"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
@@ -22,10 +23,16 @@ func TestAliveHandle(t *testing.T) {
 	req, _ := http.NewRequest("GET", "/hello", nil)
 
 	rr := httptest.NewRecorder()
-	handler := handleAlive()
+	draining := false
+	handler := handleAlive(func() bool { return draining })
 	handler.ServeHTTP(rr, req)
 
 	assert.Equal(t, rr.Code, http.StatusOK)
+
+	draining = true
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
 }
 
 func TestGetBalanceHandleStandardBehaviour(t *testing.T) {
@@ -42,14 +49,136 @@ func TestGetBalanceHandleStandardBehaviour(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 	mockDb := mockdb.NewMockStore(mockCtrl)
-	dummyAccount := models.Account{ID: id, Balance: float64(id * 100)}
-	mockDb.EXPECT().GetBalance(id).Return(&dummyAccount, nil).Times(1)
+	dummyAccount := models.Account{ID: id, Balance: models.Money(id * 10000)}
+	mockDb.EXPECT().GetBalance(gomock.Any(), id).Return(&dummyAccount, nil).Times(1)
+
+	rr := httptest.NewRecorder()
+	handler := handleGetBalance(mockDb)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+}
+
+func TestGetBalanceHandleHidesInternalError(t *testing.T) {
+	vars := map[string]string{
+		"id": "7",
+	}
+	req, _ := http.NewRequest("GET", "/7", nil)
+	req = mux.SetURLVars(req, vars)
+	req.Header.Set(models.RequestIDHeader, "req-1")
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	cErr := models.CustomErr{Err: fmt.Errorf("GetBalance: dial tcp 10.0.0.5:5432: connect: connection refused"), ErrorCode: models.ErrorDefaultCode}
+	mockDb.EXPECT().GetBalance(gomock.Any(), 7).Return(nil, &cErr).Times(1)
 
 	rr := httptest.NewRecorder()
 	handler := handleGetBalance(mockDb)
 	handler.ServeHTTP(rr, req)
 
+	assert.Equal(t, rr.Code, http.StatusInternalServerError)
+	assert.Equal(t, rr.Header().Get("Content-Type"), models.ProblemContentType)
+	problem := models.Problem{}
+	assert.Equal(t, json.Unmarshal(rr.Body.Bytes(), &problem), nil)
+	assert.Equal(t, problem, models.Problem{Type: "about:blank", Title: "Internal Server Error", Status: 500,
+		Detail: "Internal error", Instance: "/7", Code: models.ErrorDefaultCode, RequestID: "req-1"})
+}
+
+func TestGetBalanceHandleAsOf(t *testing.T) {
+	vars := map[string]string{
+		"id": "42",
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	handler := handleGetBalance(mockDb)
+
+	req, _ := http.NewRequest("GET", "/42?as_of=2026-06-30", nil)
+	req = mux.SetURLVars(req, vars)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	asOf := time.Date(2026, 6, 30, 23, 59, 59, 0, time.UTC)
+	transactionID := 7
+	balance := &models.BalanceAsOf{ID: 42, Balance: 150000, AsOf: asOf, TransactionID: &transactionID}
+	mockDb.EXPECT().GetBalanceAsOf(gomock.Any(), 42, asOf).Return(balance, nil).Times(1)
+
+	req, _ = http.NewRequest("GET", "/42?as_of=2026-06-30T23:59:59Z", nil)
+	req = mux.SetURLVars(req, vars)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Body.String(), `{"ID":42,"Balance":1500.00,"AsOf":"2026-06-30T23:59:59Z","TransactionID":7}`)
+}
+
+func TestGetStatementHandle(t *testing.T) {
+	vars := map[string]string{
+		"id": "1",
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	handler := handleGetStatement(mockDb)
+
+	for query, status := range map[string]int{
+		"":                                     http.StatusBadRequest,
+		"from=2021-03-01T00:00:00Z&format=pdf": http.StatusBadRequest,
+		"from=2021-03-01T00:00:00Z&to=2021-02-01T00:00:00Z": http.StatusBadRequest,
+	} {
+		req, _ := http.NewRequest("GET", "/1/statement?"+query, nil)
+		req = mux.SetURLVars(req, vars)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, status)
+	}
+	req, _ := http.NewRequest("GET", "/1/statement?from=2021-03-01T00:00:00Z", nil)
+	req.Header.Set("Accept", "application/json")
+	req = mux.SetURLVars(req, vars)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusNotAcceptable)
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	mockDb.EXPECT().GetBalanceAsOf(gomock.Any(), 1, from.Add(-time.Nanosecond)).Return(&models.BalanceAsOf{ID: 1, Balance: 10000}, nil)
+	mockDb.EXPECT().GetBalanceAsOf(gomock.Any(), 1, to).Return(&models.BalanceAsOf{ID: 1, Balance: 11000}, nil)
+	mockDb.EXPECT().StreamTransactionHistory(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, request *models.HistoryRequest, fn func(*models.Transaction) error) *models.CustomErr {
+			assert.Equal(t, *request.From, from)
+			fn(&models.Transaction{ID: 3, CreatedAt: from.Add(time.Hour), Delta: 1000, Remaining: 11000})
+			return nil
+		})
+
+	req, _ = http.NewRequest("GET", "/1/statement?from=2021-03-01T00:00:00Z&to=2021-04-01T00:00:00Z", nil)
+	req.Header.Set("Accept", "text/csv")
+	req = mux.SetURLVars(req, vars)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
 	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("Content-Type"), "text/csv")
+	assert.Equal(t, rr.Body.String(), "record,transaction_id,time,kind,amount,balance,message\n"+
+		"opening,,2021-03-01T00:00:00Z,,,100.00,\n"+
+		"transaction,3,2021-03-01T01:00:00Z,,10.00,110.00,\n"+
+		"closing,,2021-04-01T00:00:00Z,,,110.00,\n")
+}
+
+func TestGetSystemAccountsHandle(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/system-accounts", nil)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	accounts := []models.SystemAccount{{ID: models.SystemAccountCashIn, Name: "cash-in", Balance: -10000}}
+	mockDb.EXPECT().GetSystemAccounts(gomock.Any()).Return(accounts, nil).Times(1)
+
+	rr := httptest.NewRecorder()
+	handler := handleGetSystemAccounts(mockDb)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Body.String(), `[{"ID":-1,"Name":"cash-in","Balance":-100.00}]`)
 }
 
 func TestChangeBalanceHandleStandardBehaviour(t *testing.T) {
@@ -57,11 +186,10 @@ func TestChangeBalanceHandleStandardBehaviour(t *testing.T) {
 	minId := 1
 	maxId := 20
 	id := rand.Intn(maxId-minId+1) + 1
-	minDelta := 0.01
-	maxDelta := 9999.99
-	delta := minDelta + rand.Float64() * (maxDelta - minDelta)
-	delta = math.Round(delta*100)/100
-	chBR := models.ChangeBalanceRequest{ID: id, Delta: 100}
+	minDelta := models.Money(1)
+	maxDelta := models.Money(999999)
+	delta := minDelta + models.Money(rand.Int63n(int64(maxDelta-minDelta)))
+	chBR := models.ChangeBalanceRequest{ID: id, Delta: 10000}
 	entryData, _ := json.Marshal(chBR)
 	req, _ := http.NewRequest("GET", "change-balance", bytes.NewBuffer(entryData))
 
@@ -73,10 +201,10 @@ func TestChangeBalanceHandleStandardBehaviour(t *testing.T) {
 		AccountID: id,
 		CreatedAt: time.Now(),
 		Delta:     delta,
-		Remaining: 100 + delta,
+		Remaining: 10000 + delta,
 	}
-	dummyTransaction.Message = fmt.Sprintf("Account [%v]: balance changed by [%.2f], [%.2f] remaining", dummyTransaction.AccountID, dummyTransaction.Delta, dummyTransaction.Remaining)
-	mockDb.EXPECT().UpdateBalance(&chBR).Return(&dummyTransaction, nil).Times(1)
+	dummyTransaction.Message = fmt.Sprintf("Account [%v]: balance changed by [%v], [%v] remaining", dummyTransaction.AccountID, dummyTransaction.Delta, dummyTransaction.Remaining)
+	mockDb.EXPECT().UpdateBalance(gomock.Any(), &chBR).Return(&dummyTransaction, nil).Times(1)
 
 	rr := httptest.NewRecorder()
 	handler := handleChangeBalance(mockDb)
@@ -85,16 +213,91 @@ func TestChangeBalanceHandleStandardBehaviour(t *testing.T) {
 	assert.Equal(t, rr.Code, http.StatusOK)
 }
 
+func TestChangeBalanceHandleRejectsExcessPrecision(t *testing.T) {
+	req, _ := http.NewRequest("POST", "change-balance", bytes.NewBufferString(`{"id": 1, "delta": 10.005}`))
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+
+	rr := httptest.NewRecorder()
+	handler := handleChangeBalance(mockDb)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestChangeBalanceHandleIdempotencyConflict(t *testing.T) {
+	chBR := models.ChangeBalanceRequest{ID: 1, Delta: 10000}
+	entryData, _ := json.Marshal(chBR)
+	req, _ := http.NewRequest("POST", "change-balance", bytes.NewBuffer(entryData))
+	req.Header.Set(models.IdempotencyKeyHeader, "key-1")
+	chBR.IdempotencyKey = "key-1"
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	cErr := models.CustomErr{Err: fmt.Errorf("conflict"), ErrorCode: models.ErrorIdempotencyConflictCode}
+	mockDb.EXPECT().UpdateBalance(gomock.Any(), &chBR).Return(nil, &cErr).Times(1)
+
+	rr := httptest.NewRecorder()
+	handler := handleChangeBalance(mockDb)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusConflict)
+}
+
+func TestChangeBalanceHandleTimeout(t *testing.T) {
+	chBR := models.ChangeBalanceRequest{ID: 1, Delta: 10000}
+	entryData, _ := json.Marshal(chBR)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "POST", "change-balance", bytes.NewBuffer(entryData))
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	cErr := models.CustomErr{Err: fmt.Errorf("operation canceled: %w", context.DeadlineExceeded), ErrorCode: models.ErrorCanceledCode}
+	mockDb.EXPECT().UpdateBalance(ctx, &chBR).Return(nil, &cErr).Times(1)
+
+	rr := httptest.NewRecorder()
+	handler := handleChangeBalance(mockDb)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusGatewayTimeout)
+}
+
+func TestChangeBalanceHandleValidation(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/change-balance", bytes.NewBufferString(`{"id": -1}`))
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+
+	rr := httptest.NewRecorder()
+	handler := handleChangeBalance(mockDb)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	problem := models.Problem{}
+	assert.Equal(t, json.Unmarshal(rr.Body.Bytes(), &problem), nil)
+	assert.Equal(t, problem.Code, models.ErrorValidationCode)
+	assert.Equal(t, problem.Detail, "Validation error(s): [ID] must be greater than [0], [Delta] is required")
+	assert.Equal(t, problem.Errors, []models.FieldError{
+		{Field: "ID", Rule: "gt=0", Message: "must be greater than [0]"},
+		{Field: "Delta", Rule: "required", Message: "is required"},
+	})
+}
+
 func TestTransferHandleStandardBehaviour(t *testing.T) {
 	rand.Seed(time.Now().UnixNano())
 	minId := 1
 	maxId := 20
 	id := rand.Intn(maxId-minId+1) + 1
-	minDelta := 0.01
-	maxDelta := 9999.99
-	delta := minDelta + rand.Float64() * (maxDelta - minDelta)
-	delta = math.Round(delta*100)/100
-	tR := models.TransferRequest{ID1: id, ID2: id + 1, Delta: 100}
+	minDelta := models.Money(1)
+	maxDelta := models.Money(999999)
+	delta := minDelta + models.Money(rand.Int63n(int64(maxDelta-minDelta)))
+	tR := models.TransferRequest{ID1: id, ID2: id + 1, Delta: 10000}
 	entryData, _ := json.Marshal(tR)
 	req, _ := http.NewRequest("GET", "change-balance", bytes.NewBuffer(entryData))
 
@@ -106,11 +309,11 @@ func TestTransferHandleStandardBehaviour(t *testing.T) {
 		AccountID: id,
 		CreatedAt: time.Now(),
 		Delta:     -delta,
-		Remaining: 10000 - delta,
+		Remaining: 1000000 - delta,
 	}
-	dummyTransaction.Message = fmt.Sprintf("Transfer from account [%v] to account [%v]: balance changed by [%.2f], [%.2f] remaining",
+	dummyTransaction.Message = fmt.Sprintf("Transfer from account [%v] to account [%v]: balance changed by [%v], [%v] remaining",
 		dummyTransaction.ID, tR.ID2, dummyTransaction.Delta, dummyTransaction.Remaining)
-	mockDb.EXPECT().MakeTransfer(&tR).Return(&dummyTransaction, nil).Times(1)
+	mockDb.EXPECT().MakeTransfer(gomock.Any(), &tR).Return(&dummyTransaction, nil).Times(1)
 
 	rr := httptest.NewRecorder()
 	handler := handleTransfer(mockDb)
@@ -136,20 +339,420 @@ func TestGetTransactionsHandleStandardBehaviour(t *testing.T) {
 	dummyTransactions := make([]models.Transaction, 0, 15)
 	for i := 1; i < 15; i++ {
 		t := models.Transaction{
-			ID: i,
+			ID:        i,
 			AccountID: id,
-			CreatedAt: time.Now().Add(1*time.Hour),
-			Delta: 10.00,
-			Remaining: float64(i * 10),
-			Message: fmt.Sprintf("Account [%v]: balance changed by [%.2f], [%.2f] remaining", id, 10.00, float64(i * 10)),
+			CreatedAt: time.Now().Add(1 * time.Hour),
+			Delta:     1000,
+			Remaining: models.Money(i * 1000),
+			Message:   fmt.Sprintf("Account [%v]: balance changed by [%v], [%v] remaining", id, models.Money(1000), models.Money(i*1000)),
 		}
 		dummyTransactions = append(dummyTransactions, t)
 	}
-	mockDb.EXPECT().GetTransactionHistory(id, "by-time", "asc", -1).Return(dummyTransactions, nil).Times(1)
+	request := &models.HistoryRequest{AccountID: id, Sort: "by-time", Order: "asc", Page: -1}
+	mockDb.EXPECT().GetTransactionHistory(gomock.Any(), request).Return(&models.HistoryPage{Transactions: dummyTransactions}, nil).Times(1)
 
 	rr := httptest.NewRecorder()
 	handler := handleGetTransactions(mockDb)
 	handler.ServeHTTP(rr, req)
 
 	assert.Equal(t, rr.Code, http.StatusOK)
-}
\ No newline at end of file
+}
+
+func TestGetTransactionsHandleCursor(t *testing.T) {
+	vars := map[string]string{
+		"id": "3",
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	handler := handleGetTransactions(mockDb)
+
+	for _, query := range []string{"limit=0", "limit=abc", "page=2&limit=5", "cursor=bad", "sort=by-sum&cursor=" +
+		(&models.HistoryCursor{Sort: "by-time", Order: "asc", ID: 1}).String()} {
+		req, _ := http.NewRequest("GET", "/transactions/3?"+query, nil)
+		req = mux.SetURLVars(req, vars)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, http.StatusBadRequest)
+	}
+
+	cursor := &models.HistoryCursor{Sort: "by-time", Order: "desc", ID: 5}
+	request := &models.HistoryRequest{AccountID: 3, Sort: "by-time", Order: "desc", Cursor: cursor, Limit: 5}
+	page := &models.HistoryPage{Transactions: []models.Transaction{}, PrevCursor: "prev"}
+	mockDb.EXPECT().GetTransactionHistory(gomock.Any(), request).Return(page, nil).Times(1)
+
+	req, _ := http.NewRequest("GET", "/transactions/3?order=desc&limit=5&cursor="+cursor.String(), nil)
+	req = mux.SetURLVars(req, vars)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Body.String(), `{"transactions":[],"prev_cursor":"prev"}`)
+}
+
+func TestGetTransactionsHandleFilters(t *testing.T) {
+	vars := map[string]string{
+		"id": "3",
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	handler := handleGetTransactions(mockDb)
+
+	for _, query := range []string{"from=yesterday", "from=2021-03-02T00:00:00Z&to=2021-03-01T00:00:00Z", "min=-1",
+		"min=10.005", "min=20&max=10", "direction=up", "kind=deposit,bonus"} {
+		req, _ := http.NewRequest("GET", "/transactions/3?"+query, nil)
+		req = mux.SetURLVars(req, vars)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, http.StatusBadRequest)
+	}
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	min := models.Money(100000)
+	request := &models.HistoryRequest{AccountID: 3, Sort: "by-time", Order: "asc", Page: -1,
+		From: &from, MinAmount: &min, Direction: models.DirectionDebit, Kinds: []string{"withdrawal", "transfer-out"}}
+	mockDb.EXPECT().GetTransactionHistory(gomock.Any(), request).Return(&models.HistoryPage{}, nil).Times(1)
+
+	req, _ := http.NewRequest("GET", "/transactions/3?from=2021-03-01T00:00:00Z&min=1000&direction=debit&kind=withdrawal,transfer-out", nil)
+	req = mux.SetURLVars(req, vars)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+}
+
+func TestCaptureHoldHandleWithoutBody(t *testing.T) {
+	vars := map[string]string{
+		"id": "7",
+	}
+	req, _ := http.NewRequest("POST", "/holds/7/capture", bytes.NewBuffer(nil))
+	req = mux.SetURLVars(req, vars)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	cErr := models.CustomErr{Err: fmt.Errorf("hold [7] not found"), ErrorCode: models.ErrorNotFoundCode}
+	mockDb.EXPECT().CaptureHold(gomock.Any(), &models.CaptureHoldRequest{HoldID: 7}).Return(nil, &cErr).Times(1)
+
+	rr := httptest.NewRecorder()
+	handler := handleCaptureHold(mockDb)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+}
+
+func TestBatchTransferHandleValidatesEveryTransfer(t *testing.T) {
+	body := `{"transfers": [{"id1": 1, "id2": 2, "delta": 10}, {"id1": 3, "id2": 3, "delta": 10}]}`
+	req, _ := http.NewRequest("POST", "/batch-transfer", bytes.NewBufferString(body))
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+
+	rr := httptest.NewRecorder()
+	handler := handleBatchTransfer(mockDb)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	assert.Matches(t, rr.Body.String(), `Transfers\[1\]\.ID2`)
+}
+
+func TestFreezeAccountHandle(t *testing.T) {
+	vars := map[string]string{
+		"id": "5",
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	handler := handleFreezeAccount(mockDb)
+
+	req, _ := http.NewRequest("POST", "/5/freeze", bytes.NewBufferString(`{"scope": "everything", "reason": "fraud check"}`))
+	req = mux.SetURLVars(req, vars)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	req, _ = http.NewRequest("POST", "/5/freeze", bytes.NewBufferString(`{"scope": "all"}`))
+	req = mux.SetURLVars(req, vars)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	frozen := models.Account{ID: 5, Status: models.AccountStatusFrozen, FreezeScope: models.FreezeScopeAll}
+	mockDb.EXPECT().FreezeAccount(gomock.Any(), &models.FreezeAccountRequest{AccountID: 5, Scope: models.FreezeScopeAll, Reason: "fraud check"}).
+		Return(&frozen, nil).Times(1)
+	req, _ = http.NewRequest("POST", "/5/freeze", bytes.NewBufferString(`{"scope": "all", "reason": "fraud check"}`))
+	req = mux.SetURLVars(req, vars)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Matches(t, rr.Body.String(), `"Status":"frozen","FreezeScope":"all"`)
+
+	mockDb.EXPECT().FreezeAccount(gomock.Any(), &models.FreezeAccountRequest{AccountID: 5, Reason: "typo"}).
+		Return(nil, models.NotFoundError("account", 5)).Times(1)
+	req, _ = http.NewRequest("POST", "/5/freeze", bytes.NewBufferString(`{"reason": "typo"}`))
+	req = mux.SetURLVars(req, vars)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+}
+
+func TestCloseAccountHandle(t *testing.T) {
+	vars := map[string]string{
+		"id": "5",
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	handler := handleCloseAccount(mockDb)
+
+	req, _ := http.NewRequest("POST", "/5/close", bytes.NewBufferString(`{"sweep_to": 5, "reason": "customer request"}`))
+	req = mux.SetURLVars(req, vars)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	for _, test := range []struct {
+		code   models.ErrorCode
+		status int
+	}{{models.ErrorAccountStatusCode, http.StatusConflict}, {models.ErrorAccountClosedCode, http.StatusForbidden}} {
+		cErr := models.CustomErr{Err: fmt.Errorf("cannot close account [5]"), ErrorCode: test.code}
+		mockDb.EXPECT().CloseAccount(gomock.Any(), &models.CloseAccountRequest{AccountID: 5, Reason: "customer request"}).Return(nil, &cErr).Times(1)
+		req, _ = http.NewRequest("POST", "/5/close", bytes.NewBufferString(`{"reason": "customer request"}`))
+		req = mux.SetURLVars(req, vars)
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, test.status)
+	}
+}
+
+func TestSetCreditLimitHandle(t *testing.T) {
+	vars := map[string]string{
+		"id": "5",
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	handler := handleSetCreditLimit(mockDb)
+
+	req, _ := http.NewRequest("PUT", "/5/credit-limit", bytes.NewBufferString(`{"credit_limit": -100}`))
+	req = mux.SetURLVars(req, vars)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	cErr := models.CustomErr{Err: fmt.Errorf("account [5] uses [70.00] of its credit line"), ErrorCode: models.ErrorCreditLimitCode}
+	mockDb.EXPECT().SetCreditLimit(gomock.Any(), &models.CreditLimitRequest{AccountID: 5, CreditLimit: 5000}).Return(nil, &cErr).Times(1)
+	req, _ = http.NewRequest("PUT", "/5/credit-limit", bytes.NewBufferString(`{"credit_limit": 50}`))
+	req = mux.SetURLVars(req, vars)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusConflict)
+
+	account := models.Account{ID: 5, Balance: -7000, CreditLimit: 10000, Available: 3000, Status: models.AccountStatusActive}
+	mockDb.EXPECT().SetCreditLimit(gomock.Any(), &models.CreditLimitRequest{AccountID: 5, CreditLimit: 10000}).Return(&account, nil).Times(1)
+	req, _ = http.NewRequest("PUT", "/5/credit-limit", bytes.NewBufferString(`{"credit_limit": 100}`))
+	req = mux.SetURLVars(req, vars)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Matches(t, rr.Body.String(), `"CreditLimit":100.00`)
+}
+
+func TestTransferHandleLimitExceeded(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	handler := handleTransfer(mockDb)
+
+	for _, test := range []struct {
+		err        *models.LimitExceededError
+		status     int
+		retryAfter string
+	}{
+		{&models.LimitExceededError{AccountID: 1, Limit: models.LimitDailyDebit, Value: "120.00", Max: "100.00"}, http.StatusForbidden, ""},
+		{&models.LimitExceededError{AccountID: 1, Limit: models.LimitHourlyTransfers, Value: "6", Max: "5",
+			RetryAfter: 90500 * time.Millisecond}, http.StatusTooManyRequests, "91"},
+		{&models.LimitExceededError{AccountID: 1, Limit: models.LimitHourlyTransfers, Value: "1", Max: "0"}, http.StatusForbidden, ""},
+	} {
+		cErr := models.CustomErr{Err: test.err, ErrorCode: models.ErrorLimitExceededCode}
+		mockDb.EXPECT().MakeTransfer(gomock.Any(), gomock.Any()).Return(nil, &cErr).Times(1)
+
+		req, _ := http.NewRequest("POST", "/transfer", bytes.NewBufferString(`{"id1": 1, "id2": 2, "delta": 10}`))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, test.status)
+		assert.Equal(t, rr.Header().Get("Retry-After"), test.retryAfter)
+		assert.Matches(t, rr.Body.String(), fmt.Sprintf(`spending limit \[%s\]`, test.err.Limit))
+	}
+}
+
+func TestSetSpendingLimitsHandle(t *testing.T) {
+	vars := map[string]string{
+		"id": "5",
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	handler := handleSetSpendingLimits(mockDb)
+
+	req, _ := http.NewRequest("PUT", "/5/limits", bytes.NewBufferString(`{"hourly_transfers": -1}`))
+	req = mux.SetURLVars(req, vars)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	daily, hourly := models.Money(10000), 5
+	limits := &models.SpendingLimits{AccountID: 5, DailyDebit: &daily, HourlyTransfers: &hourly}
+	mockDb.EXPECT().SetSpendingLimits(gomock.Any(), limits).Return(limits, nil).Times(1)
+	req, _ = http.NewRequest("PUT", "/5/limits", bytes.NewBufferString(`{"daily_debit": 100, "hourly_transfers": 5}`))
+	req = mux.SetURLVars(req, vars)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Body.String(), `{"daily_debit":100.00,"hourly_transfers":5,"single_transfer":null}`)
+}
+
+func TestCreateScheduleHandle(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	handler := handleCreateSchedule(mockDb)
+
+	//either run_at or cron is required
+	req, _ := http.NewRequest("POST", "/schedules", bytes.NewBufferString(`{"from_id": 1, "to_id": 2, "amount": 10}`))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	request := &models.ScheduleRequest{FromID: 1, ToID: 2, Amount: 1000, Cron: "61 * * * *"}
+	cErr := models.CustomErr{Err: fmt.Errorf("schedule not valid: minute [61] out of range 0-59"), ErrorCode: models.ErrorScheduleInvalidCode}
+	mockDb.EXPECT().CreateSchedule(gomock.Any(), request).Return(nil, &cErr).Times(1)
+	req, _ = http.NewRequest("POST", "/schedules", bytes.NewBufferString(`{"from_id": 1, "to_id": 2, "amount": 10, "cron": "61 * * * *"}`))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	request.Cron = "@monthly"
+	schedule := models.Schedule{ID: 3, FromID: 1, ToID: 2, Amount: 1000, Cron: "@monthly", Status: models.ScheduleStatusActive}
+	mockDb.EXPECT().CreateSchedule(gomock.Any(), request).Return(&schedule, nil).Times(1)
+	req, _ = http.NewRequest("POST", "/schedules", bytes.NewBufferString(`{"from_id": 1, "to_id": 2, "amount": 10, "cron": "@monthly"}`))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Matches(t, rr.Body.String(), `"ID":3`)
+}
+
+func TestPauseScheduleHandle(t *testing.T) {
+	vars := map[string]string{
+		"id": "3",
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	handler := handlePauseSchedule(mockDb)
+
+	cErr := models.CustomErr{Err: fmt.Errorf("schedule [3] is not active: it is paused"), ErrorCode: models.ErrorScheduleStatusCode}
+	mockDb.EXPECT().PauseSchedule(gomock.Any(), 3).Return(nil, &cErr).Times(1)
+	req, _ := http.NewRequest("POST", "/schedules/3/pause", nil)
+	req = mux.SetURLVars(req, vars)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusConflict)
+
+	schedule := models.Schedule{ID: 3, Status: models.ScheduleStatusPaused}
+	mockDb.EXPECT().PauseSchedule(gomock.Any(), 3).Return(&schedule, nil).Times(1)
+	req, _ = http.NewRequest("POST", "/schedules/3/pause", nil)
+	req = mux.SetURLVars(req, vars)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Matches(t, rr.Body.String(), `"Status":"paused"`)
+}
+
+func TestCreateWebhookHandle(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	handler := handleCreateWebhook(mockDb)
+
+	req, _ := http.NewRequest("POST", "/webhooks", bytes.NewBufferString(`{"url": "not a url", "secret": "0123456789abcdef"}`))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	request := &models.WebhookRequest{URL: "https://example.com/hook", Secret: "0123456789abcdef"}
+	webhook := models.Webhook{ID: 2, URL: request.URL, Secret: request.Secret}
+	mockDb.EXPECT().CreateWebhook(gomock.Any(), request).Return(&webhook, nil).Times(1)
+	req, _ = http.NewRequest("POST", "/webhooks", bytes.NewBufferString(`{"url": "https://example.com/hook", "secret": "0123456789abcdef"}`))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	//the secret is not shown
+	assert.Equal(t, rr.Body.String(), `{"ID":2,"URL":"https://example.com/hook","CreatedAt":"0001-01-01T00:00:00Z"}`)
+}
+
+func TestRedeliverWebhookDeliveryHandle(t *testing.T) {
+	vars := map[string]string{
+		"id": "7",
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	handler := handleRedeliverWebhookDelivery(mockDb)
+
+	cErr := models.CustomErr{Err: fmt.Errorf("webhook delivery [7] not found"), ErrorCode: models.ErrorNotFoundCode}
+	mockDb.EXPECT().RedeliverWebhookDelivery(gomock.Any(), 7).Return(nil, &cErr).Times(1)
+	req, _ := http.NewRequest("POST", "/webhooks/deliveries/7/redeliver", nil)
+	req = mux.SetURLVars(req, vars)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+
+	delivery := models.WebhookDelivery{ID: 7, Status: models.DeliveryStatusPending}
+	mockDb.EXPECT().RedeliverWebhookDelivery(gomock.Any(), 7).Return(&delivery, nil).Times(1)
+	req, _ = http.NewRequest("POST", "/webhooks/deliveries/7/redeliver", nil)
+	req = mux.SetURLVars(req, vars)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Matches(t, rr.Body.String(), `"Status":"pending"`)
+}
+
+func TestGetEventsHandle(t *testing.T) {
+	vars := map[string]string{
+		"id": "3",
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockDb := mockdb.NewMockStore(mockCtrl)
+	broker := events.NewBroker(mockDb, time.Second, 10)
+	handler := handleGetEvents(mockDb, broker)
+
+	req, _ := http.NewRequest("GET", "/3/events?after=x", nil)
+	req = mux.SetURLVars(req, vars)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+
+	//transactions made after the last received one are replayed, then the stream lasts until the broker is closed
+	history := &models.HistoryPage{Transactions: []models.Transaction{{ID: 6, AccountID: 3, Delta: 100, Remaining: 100}}}
+	mockDb.EXPECT().GetTransactionHistory(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, request *models.HistoryRequest) (*models.HistoryPage, *models.CustomErr) {
+		assert.Equal(t, request.AfterID, 5)
+		broker.Close()
+		return history, nil
+	}).Times(1)
+	req, _ = http.NewRequest("GET", "/3/events", nil)
+	req.Header.Set("Last-Event-ID", "5")
+	req = mux.SetURLVars(req, vars)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("Content-Type"), "text/event-stream")
+	assert.Matches(t, rr.Body.String(), `^id: 6\nevent: transaction\ndata: \{"ID":6,.*\n\nevent: close\ndata: events: server is shutting down\n\n$`)
+
+	req, _ = http.NewRequest("GET", "/3/events", nil)
+	req = mux.SetURLVars(req, vars)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
+}
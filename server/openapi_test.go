@@ -0,0 +1,83 @@
+package server
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/events"
+	"github.com/gorilla/mux"
+	"github.com/magiconair/properties/assert"
+	"gopkg.in/yaml.v2"
+)
+
+//routeVariable matches a path variable with a pattern, e.g. {id:[0-9]+}
+var routeVariable = regexp.MustCompile(`\{(\w+):[^}]+\}`)
+
+func TestOpenAPIMatchesRouter(t *testing.T) {
+	s := New()
+	s.ConfigureRouter(nil, events.NewBroker(nil, time.Second, 1))
+	routes := make([]string, 0)
+	err := s.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			routes = append(routes, strings.ToLower(method)+" "+routeVariable.ReplaceAllString(path, "{$1}"))
+		}
+		return nil
+	})
+	assert.Equal(t, err, nil)
+
+	spec := struct {
+		Paths map[string]map[string]interface{} `yaml:"paths"`
+	}{}
+	assert.Equal(t, yaml.Unmarshal(openAPISpec, &spec), nil)
+	documented := make([]string, 0)
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented = append(documented, method+" "+path)
+		}
+	}
+
+	sort.Strings(routes)
+	sort.Strings(documented)
+	assert.Equal(t, documented, routes)
+}
+
+func TestOpenAPIReferences(t *testing.T) {
+	spec := map[interface{}]interface{}{}
+	assert.Equal(t, yaml.Unmarshal(openAPISpec, &spec), nil)
+
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch node := node.(type) {
+		case map[interface{}]interface{}:
+			if ref, ok := node["$ref"].(string); ok {
+				var target interface{} = spec
+				for _, name := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					parent, _ := target.(map[interface{}]interface{})
+					target = parent[name]
+				}
+				if target == nil {
+					t.Errorf("reference [%s] not found", ref)
+				}
+			}
+			for _, child := range node {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range node {
+				walk(child)
+			}
+		}
+	}
+	walk(spec)
+}
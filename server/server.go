@@ -1,33 +1,108 @@
 package server
 
 import (
+	"context"
+	_ "embed"
+	"github.com/dalconoid/balance-service/events"
 	"github.com/dalconoid/balance-service/storage"
 	"github.com/gorilla/mux"
 	"net/http"
+	"sync/atomic"
 )
 
+//openAPISpec is the OpenAPI document of the routes bound by ConfigureRouter
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
 
 //Server represents a server
 type Server struct {
 	router *mux.Router
+	http   *http.Server
+	//draining is set to 1 once the server starts shutting down
+	draining int32
 }
 
 //New creates a server
 func New() *Server {
-	s := Server{router: mux.NewRouter()}
+	s := Server{router: mux.NewRouter(), http: &http.Server{}}
 	return &s
 }
 
 //Start starts server
 func (s *Server) Start(address string) error {
-	return http.ListenAndServe(address, s.router)
+	s.http.Addr = address
+	s.http.Handler = s
+	return s.http.ListenAndServe()
 }
 
-//ConfigureRouter binds handles to routes
-func (s *Server) ConfigureRouter(storage storage.Store) {
-	s.router.HandleFunc("/alive", handleAlive()).Methods("GET")
+//ServeHTTP dispatches request to the handle of its route; every request gets an id
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, withRequestID(w, r))
+}
+
+//Drain makes /alive report that the server is not ready, so that load balancers stop sending requests to it.
+//Requests are still served until Shutdown
+func (s *Server) Drain() {
+	atomic.StoreInt32(&s.draining, 1)
+	s.http.SetKeepAlivesEnabled(false)
+}
+
+//Draining returns true after Drain is called
+func (s *Server) Draining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+//Shutdown stops server: it stops accepting connections, event streams are closed, then it waits for other requests
+//to finish until ctx is done. The connections of requests still running then are closed, which cancels their
+//contexts and rolls back their transactions
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.Drain()
+	err := s.http.Shutdown(ctx)
+	if ctx.Err() != nil {
+		s.http.Close()
+	}
+	return err
+}
+
+//ConfigureRouter binds handles to routes; event streams are fed by broker
+func (s *Server) ConfigureRouter(storage storage.Store, broker *events.Broker) {
+	//Shutdown does not wait for streams, they have to be closed
+	s.http.RegisterOnShutdown(broker.Close)
+	s.router.NotFoundHandler = handleNotFound()
+	s.router.MethodNotAllowedHandler = handleMethodNotAllowed()
+	s.router.HandleFunc("/alive", handleAlive(s.Draining)).Methods("GET")
+	s.router.HandleFunc("/openapi.yaml", handleOpenAPI()).Methods("GET")
 	s.router.HandleFunc("/{id:[0-9]+}", handleGetBalance(storage)).Methods("GET")
+	s.router.HandleFunc("/{id:[0-9]+}/statement", handleGetStatement(storage)).Methods("GET")
+	s.router.HandleFunc("/{id:[0-9]+}/status-history", handleGetAccountStatusHistory(storage)).Methods("GET")
+	s.router.HandleFunc("/{id:[0-9]+}/freeze", handleFreezeAccount(storage)).Methods("POST")
+	s.router.HandleFunc("/{id:[0-9]+}/unfreeze", handleUnfreezeAccount(storage)).Methods("POST")
+	s.router.HandleFunc("/{id:[0-9]+}/close", handleCloseAccount(storage)).Methods("POST")
+	s.router.HandleFunc("/{id:[0-9]+}/credit-limit", handleSetCreditLimit(storage)).Methods("PUT")
+	s.router.HandleFunc("/{id:[0-9]+}/limits", handleGetSpendingLimits(storage)).Methods("GET")
+	s.router.HandleFunc("/{id:[0-9]+}/limits", handleSetSpendingLimits(storage)).Methods("PUT")
+	s.router.HandleFunc("/{id:[0-9]+}/schedules", handleGetSchedules(storage)).Methods("GET")
+	s.router.HandleFunc("/{id:[0-9]+}/events", handleGetEvents(storage, broker)).Methods("GET")
+	s.router.HandleFunc("/system-accounts", handleGetSystemAccounts(storage)).Methods("GET")
 	s.router.HandleFunc("/transactions/{id:[0-9]+}", handleGetTransactions(storage)).Methods("GET")
+	s.router.HandleFunc("/transactions/{id:[0-9]+}/reverse", handleReverseTransaction(storage)).Methods("POST")
 	s.router.HandleFunc("/transfer", handleTransfer(storage)).Methods("POST")
+	s.router.HandleFunc("/batch-transfer", handleBatchTransfer(storage)).Methods("POST")
 	s.router.HandleFunc("/change-balance", handleChangeBalance(storage)).Methods("POST")
+	s.router.HandleFunc("/holds", handlePlaceHold(storage)).Methods("POST")
+	s.router.HandleFunc("/holds/{id:[0-9]+}", handleGetHold(storage)).Methods("GET")
+	s.router.HandleFunc("/holds/{id:[0-9]+}/capture", handleCaptureHold(storage)).Methods("POST")
+	s.router.HandleFunc("/holds/{id:[0-9]+}/release", handleReleaseHold(storage)).Methods("POST")
+	s.router.HandleFunc("/schedules", handleCreateSchedule(storage)).Methods("POST")
+	s.router.HandleFunc("/schedules/{id:[0-9]+}", handleGetSchedule(storage)).Methods("GET")
+	s.router.HandleFunc("/schedules/{id:[0-9]+}", handleDeleteSchedule(storage)).Methods("DELETE")
+	s.router.HandleFunc("/schedules/{id:[0-9]+}/runs", handleGetScheduleRuns(storage)).Methods("GET")
+	s.router.HandleFunc("/schedules/{id:[0-9]+}/pause", handlePauseSchedule(storage)).Methods("POST")
+	s.router.HandleFunc("/schedules/{id:[0-9]+}/resume", handleResumeSchedule(storage)).Methods("POST")
+	s.router.HandleFunc("/webhooks", handleCreateWebhook(storage)).Methods("POST")
+	s.router.HandleFunc("/webhooks", handleGetWebhooks(storage)).Methods("GET")
+	s.router.HandleFunc("/webhooks/{id:[0-9]+}", handleDeleteWebhook(storage)).Methods("DELETE")
+	s.router.HandleFunc("/webhooks/{id:[0-9]+}/deliveries", handleGetWebhookDeliveries(storage)).Methods("GET")
+	s.router.HandleFunc("/webhooks/deliveries/{id:[0-9]+}/redeliver", handleRedeliverWebhookDelivery(storage)).Methods("POST")
 }
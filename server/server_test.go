@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/events"
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage/memory"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestShutdown(t *testing.T) {
+	s := New()
+	s.ConfigureRouter(memory.New(10), events.NewBroker(nil, time.Second, 1))
+	started, release := make(chan struct{}), make(chan struct{})
+	s.router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	go s.Start(address)
+	var resp *http.Response
+	for i := 0; i < 100; i++ {
+		if resp, err = http.Get("http://" + address + "/alive"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, err, nil)
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+
+	slow := make(chan int)
+	go func() {
+		resp, err := http.Get("http://" + address + "/slow")
+		if err != nil {
+			slow <- 0
+			return
+		}
+		slow <- resp.StatusCode
+	}()
+	<-started
+
+	s.Drain()
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/alive", nil))
+	assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
+
+	//the running request finishes, new connections are refused
+	shutdown := make(chan error)
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+	time.Sleep(50 * time.Millisecond)
+	_, err = http.Get("http://" + address + "/alive")
+	assert.Equal(t, err != nil, true)
+	close(release)
+	assert.Equal(t, <-slow, http.StatusOK)
+	assert.Equal(t, <-shutdown, nil)
+}
+
+func TestShutdownGracePeriod(t *testing.T) {
+	s := New()
+	s.ConfigureRouter(memory.New(10), events.NewBroker(nil, time.Second, 1))
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	s.router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(canceled)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	go s.Start(address)
+	go func() {
+		for i := 0; i < 100; i++ {
+			if _, err := http.Get("http://" + address + "/slow"); err == nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	<-started
+
+	//the request still running after the grace period is canceled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, s.Shutdown(ctx), context.DeadlineExceeded)
+	<-canceled
+}
+
+func TestRequestID(t *testing.T) {
+	s := New()
+	s.ConfigureRouter(memory.New(10), events.NewBroker(nil, time.Second, 1))
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/alive", nil))
+	assert.Equal(t, len(rr.Header().Get(models.RequestIDHeader)), 32)
+
+	//unknown routes are problems too
+	req := httptest.NewRequest("GET", "/unknown", nil)
+	req.Header.Set(models.RequestIDHeader, "req-1")
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+	assert.Equal(t, rr.Header().Get(models.RequestIDHeader), "req-1")
+	problem := models.Problem{}
+	assert.Equal(t, json.Unmarshal(rr.Body.Bytes(), &problem), nil)
+	assert.Equal(t, problem.RequestID, "req-1")
+	assert.Equal(t, problem.Code, models.ErrorNotFoundCode)
+}
+
+func TestValidateRequestNotStruct(t *testing.T) {
+	rr := httptest.NewRecorder()
+	assert.Equal(t, validateRequest(rr, httptest.NewRequest("POST", "/", nil), nil), false)
+	assert.Equal(t, rr.Code, http.StatusInternalServerError)
+	problem := models.Problem{}
+	assert.Equal(t, json.Unmarshal(rr.Body.Bytes(), &problem), nil)
+	assert.Equal(t, problem.Code, models.ErrorDefaultCode)
+	assert.Equal(t, problem.Detail, "Internal error")
+}
@@ -0,0 +1,122 @@
+package statement
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+)
+
+//camt053Encoder writes ISO 20022 camt.053.001.02 bank to customer statement
+type camt053Encoder struct {
+	w io.Writer
+}
+
+//camtAmount - ActiveOrHistoricCurrencyAndAmount
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+//camtDateTime - DateAndDateTimeChoice
+type camtDateTime struct {
+	DateTime string `xml:"DtTm"`
+}
+
+//camtBalance - Bal element
+type camtBalance struct {
+	XMLName   xml.Name     `xml:"Bal"`
+	Code      string       `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    camtAmount   `xml:"Amt"`
+	Indicator string       `xml:"CdtDbtInd"`
+	Date      camtDateTime `xml:"Dt"`
+}
+
+//camtEntry - Ntry element
+type camtEntry struct {
+	XMLName        xml.Name     `xml:"Ntry"`
+	Reference      string       `xml:"NtryRef"`
+	Amount         camtAmount   `xml:"Amt"`
+	Indicator      string       `xml:"CdtDbtInd"`
+	Status         string       `xml:"Sts"`
+	BookingDate    camtDateTime `xml:"BookgDt"`
+	ValueDate      camtDateTime `xml:"ValDt"`
+	TxCode         string       `xml:"BkTxCd>Prtry>Cd"`
+	TransactionID  string       `xml:"NtryDtls>TxDtls>Refs>TxId"`
+	AdditionalInfo string       `xml:"NtryDtls>TxDtls>AddtlTxInf"`
+}
+
+//camtTime formats t as ISODateTime in UTC
+func camtTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+//camtAmountOf returns absolute amount and credit/debit indicator of m
+func camtAmountOf(m models.Money) (camtAmount, string) {
+	indicator := "CRDT"
+	if m < 0 {
+		indicator = "DBIT"
+	}
+	return camtAmount{Currency: Currency, Value: m.Abs().String()}, indicator
+}
+
+func camtBalanceOf(code string, m models.Money, t time.Time) camtBalance {
+	amount, indicator := camtAmountOf(m)
+	return camtBalance{Code: code, Amount: amount, Indicator: indicator, Date: camtDateTime{camtTime(t)}}
+}
+
+//Begin writes group header and both balances which precede entries in camt.053
+func (e *camt053Encoder) Begin(s *Statement) error {
+	id := fmt.Sprintf("%v-%s-%s", s.AccountID, s.From.UTC().Format("20060102"), s.To.UTC().Format("20060102"))
+	_, err := fmt.Fprintf(e.w, `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+<BkToCstmrStmt>
+<GrpHdr><MsgId>STMT-%s</MsgId><CreDtTm>%s</CreDtTm></GrpHdr>
+<Stmt>
+<Id>%s</Id><CreDtTm>%s</CreDtTm>
+<FrToDt><FrDtTm>%s</FrDtTm><ToDtTm>%s</ToDtTm></FrToDt>
+<Acct><Id><Othr><Id>%v</Id></Othr></Id><Ccy>%s</Ccy></Acct>
+`, s.CreatedAt.UTC().Format("20060102150405"), camtTime(s.CreatedAt), id, camtTime(s.CreatedAt),
+		camtTime(s.From), camtTime(s.To), s.AccountID, Currency)
+	if err != nil {
+		return err
+	}
+	for _, balance := range []camtBalance{camtBalanceOf("OPBD", s.Opening, s.From), camtBalanceOf("CLBD", s.Closing, s.To)} {
+		if err = e.encode(balance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *camt053Encoder) Transaction(t *models.Transaction) error {
+	amount, indicator := camtAmountOf(t.Delta)
+	return e.encode(camtEntry{
+		Reference:      strconv.Itoa(t.ID),
+		Amount:         amount,
+		Indicator:      indicator,
+		Status:         "BOOK",
+		BookingDate:    camtDateTime{camtTime(t.CreatedAt)},
+		ValueDate:      camtDateTime{camtTime(t.CreatedAt)},
+		TxCode:         t.Kind,
+		TransactionID:  strconv.Itoa(t.ID),
+		AdditionalInfo: t.Message,
+	})
+}
+
+func (e *camt053Encoder) End(s *Statement) error {
+	_, err := io.WriteString(e.w, "</Stmt>\n</BkToCstmrStmt>\n</Document>\n")
+	return err
+}
+
+func (e *camt053Encoder) encode(v interface{}) error {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.w, "%s\n", data)
+	return err
+}
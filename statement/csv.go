@@ -0,0 +1,44 @@
+package statement
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+)
+
+//csvEncoder writes a row per transaction between opening and closing balance rows
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) Begin(s *Statement) error {
+	e.w.Write([]string{"record", "transaction_id", "time", "kind", "amount", "balance", "message"})
+	return e.write("opening", "", s.From, "", "", s.Opening, "")
+}
+
+func (e *csvEncoder) Transaction(t *models.Transaction) error {
+	return e.write("transaction", strconv.Itoa(t.ID), t.CreatedAt, t.Kind, t.Delta.String(), t.Remaining, t.Message)
+}
+
+func (e *csvEncoder) End(s *Statement) error {
+	if err := e.write("closing", "", s.To, "", "", s.Closing, ""); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+//write writes a row; csv.Writer flushes to the underlying writer when its buffer is full
+func (e *csvEncoder) write(record string, id string, t time.Time, kind string, amount string, balance models.Money, message string) error {
+	if err := e.w.Write([]string{record, id, t.UTC().Format(time.RFC3339Nano), kind, amount, balance.String(), message}); err != nil {
+		return err
+	}
+	return e.w.Error()
+}
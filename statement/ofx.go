@@ -0,0 +1,75 @@
+package statement
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+)
+
+//ofxEncoder writes OFX 2.2 bank statement response
+type ofxEncoder struct {
+	w io.Writer
+}
+
+//ofxTransaction - STMTTRN aggregate
+type ofxTransaction struct {
+	XMLName xml.Name `xml:"STMTTRN"`
+	Type    string   `xml:"TRNTYPE"`
+	Posted  string   `xml:"DTPOSTED"`
+	Amount  string   `xml:"TRNAMT"`
+	FITID   string   `xml:"FITID"`
+	Name    string   `xml:"NAME"`
+	Memo    string   `xml:"MEMO"`
+}
+
+//ofxTime formats t as OFX datetime in UTC
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405.000") + "[0:GMT]"
+}
+
+func (e *ofxEncoder) Begin(s *Statement) error {
+	_, err := fmt.Fprintf(e.w, `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS><DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1><STMTTRNRS><TRNUID>0</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>
+<STMTRS><CURDEF>%s</CURDEF>
+<BANKACCTFROM><BANKID>BALANCE</BANKID><ACCTID>%v</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>
+<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>
+`, ofxTime(s.CreatedAt), Currency, s.AccountID, ofxTime(s.From), ofxTime(s.To))
+	return err
+}
+
+func (e *ofxEncoder) Transaction(t *models.Transaction) error {
+	trnType := "CREDIT"
+	if t.Delta < 0 {
+		trnType = "DEBIT"
+	}
+	data, err := xml.Marshal(ofxTransaction{
+		Type:   trnType,
+		Posted: ofxTime(t.CreatedAt),
+		Amount: t.Delta.String(),
+		FITID:  strconv.Itoa(t.ID),
+		Name:   t.Kind,
+		Memo:   t.Message,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.w, "%s\n", data)
+	return err
+}
+
+func (e *ofxEncoder) End(s *Statement) error {
+	_, err := fmt.Fprintf(e.w, `</BANKTRANLIST>
+<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>
+<BALLIST><BAL><NAME>Opening balance</NAME><DESC>Balance before %s</DESC><BALTYPE>DOLLAR</BALTYPE><VALUE>%s</VALUE><DTASOF>%s</DTASOF></BAL></BALLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`, s.Closing, ofxTime(s.To), ofxTime(s.From), s.Opening, ofxTime(s.From))
+	return err
+}
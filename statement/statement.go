@@ -0,0 +1,97 @@
+package statement
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+)
+
+const (
+	//supported statement formats
+	FormatCSV     = "csv"
+	FormatOFX     = "ofx"
+	FormatCamt053 = "camt053"
+
+	//Currency of account balances
+	Currency = "RUB"
+)
+
+//Formats are supported statement formats
+var Formats = []string{FormatCSV, FormatOFX, FormatCamt053}
+
+//contentTypes are media types of formats; the first one is used in responses
+var contentTypes = map[string][]string{
+	FormatCSV:     {"text/csv"},
+	FormatOFX:     {"application/x-ofx", "application/ofx"},
+	FormatCamt053: {"application/xml", "text/xml", "application/vnd.iso20022.camt.053+xml"},
+}
+
+//Statement is a statement of account AccountID for period [From, To]; Opening is the balance before From
+//and Closing is the balance at To
+type Statement struct {
+	AccountID int
+	From      time.Time
+	To        time.Time
+	Opening   models.Money
+	Closing   models.Money
+	CreatedAt time.Time
+}
+
+//Encoder writes a statement: Begin, then Transaction for every transaction in time order, then End
+type Encoder interface {
+	Begin(s *Statement) error
+	Transaction(t *models.Transaction) error
+	End(s *Statement) error
+}
+
+//NewEncoder returns encoder of format writing to w
+func NewEncoder(format string, w io.Writer) (Encoder, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVEncoder(w), nil
+	case FormatOFX:
+		return &ofxEncoder{w: w}, nil
+	case FormatCamt053:
+		return &camt053Encoder{w: w}, nil
+	}
+	return nil, fmt.Errorf("statement: unsupported format [%s]", format)
+}
+
+//ContentType returns media type of format
+func ContentType(format string) string {
+	return contentTypes[format][0]
+}
+
+//FormatByAccept returns the first format acceptable by Accept header value; an empty header and */* mean CSV.
+//Returns false if no format is acceptable
+func FormatByAccept(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return FormatCSV, true
+	}
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(mediaRange, ";", 2)[0]))
+		if mediaType == "*/*" || mediaType == "text/*" {
+			return FormatCSV, true
+		}
+		for _, format := range Formats {
+			for _, contentType := range contentTypes[format] {
+				if mediaType == contentType {
+					return format, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+//FileName returns statement file name with extension of format
+func FileName(s *Statement, format string) string {
+	extension := format
+	if format == FormatCamt053 {
+		extension = "xml"
+	}
+	return fmt.Sprintf("statement-%v-%s-%s.%s", s.AccountID, s.From.UTC().Format("20060102"), s.To.UTC().Format("20060102"), extension)
+}
@@ -0,0 +1,105 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/magiconair/properties/assert"
+)
+
+func testStatement(t *testing.T, format string) []byte {
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	s := &Statement{AccountID: 1, From: from, To: from.AddDate(0, 1, 0), Opening: 10000, Closing: 8500, CreatedAt: from.AddDate(0, 1, 1)}
+	transactions := []models.Transaction{
+		{ID: 4, Kind: models.TransactionKindDeposit, CreatedAt: from.Add(time.Hour), Delta: 1000, Remaining: 11000,
+			Message: "Account [1]: balance changed by [10.00], [110.00] remaining"},
+		{ID: 9, Kind: models.TransactionKindTransferOut, CreatedAt: from.Add(2 * time.Hour), Delta: -2500, Remaining: 8500,
+			Message: "Transfer from account [1] to account [2] & <back>"},
+	}
+
+	var buf bytes.Buffer
+	encoder, err := NewEncoder(format, &buf)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, encoder.Begin(s), nil)
+	for i := range transactions {
+		assert.Equal(t, encoder.Transaction(&transactions[i]), nil)
+	}
+	assert.Equal(t, encoder.End(s), nil)
+	return buf.Bytes()
+}
+
+//wellFormed reports whether data is a well-formed XML document
+func wellFormed(data []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	rows, err := csv.NewReader(bytes.NewReader(testStatement(t, FormatCSV))).ReadAll()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(rows), 5)
+	assert.Equal(t, rows[1], []string{"opening", "", "2021-03-01T00:00:00Z", "", "", "100.00", ""})
+	assert.Equal(t, rows[3][4], "-25.00")
+	assert.Equal(t, rows[4][5], "85.00")
+}
+
+func TestOFX(t *testing.T) {
+	data := testStatement(t, FormatOFX)
+	assert.Equal(t, wellFormed(data), true)
+	assert.Equal(t, strings.Count(string(data), "<STMTTRN>"), 2)
+	assert.Equal(t, strings.Contains(string(data), "<TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20210301020000.000[0:GMT]</DTPOSTED><TRNAMT>-25.00</TRNAMT>"), true)
+	assert.Equal(t, strings.Contains(string(data), "<LEDGERBAL><BALAMT>85.00</BALAMT>"), true)
+}
+
+func TestCamt053(t *testing.T) {
+	data := testStatement(t, FormatCamt053)
+	assert.Equal(t, wellFormed(data), true)
+
+	var document struct {
+		Balances []struct {
+			Code   string `xml:"Tp>CdOrPrtry>Cd"`
+			Amount string `xml:"Amt"`
+		} `xml:"BkToCstmrStmt>Stmt>Bal"`
+		Entries []struct {
+			Amount    string `xml:"Amt"`
+			Indicator string `xml:"CdtDbtInd"`
+		} `xml:"BkToCstmrStmt>Stmt>Ntry"`
+	}
+	assert.Equal(t, xml.Unmarshal(data, &document), nil)
+	assert.Equal(t, len(document.Balances), 2)
+	assert.Equal(t, document.Balances[1].Code, "CLBD")
+	assert.Equal(t, document.Balances[1].Amount, "85.00")
+	assert.Equal(t, len(document.Entries), 2)
+	assert.Equal(t, document.Entries[1].Amount, "25.00")
+	assert.Equal(t, document.Entries[1].Indicator, "DBIT")
+}
+
+func TestFormatByAccept(t *testing.T) {
+	for accept, expected := range map[string]string{
+		"":                                  FormatCSV,
+		"*/*":                               FormatCSV,
+		"application/x-ofx":                 FormatOFX,
+		"application/json, application/xml": FormatCamt053,
+		"text/csv;q=0.9, application/xml":   FormatCSV,
+	} {
+		format, ok := FormatByAccept(accept)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, format, expected)
+	}
+	_, ok := FormatByAccept("application/json")
+	assert.Equal(t, ok, false)
+}
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/dalconoid/balance-service/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+//FreezeAccount makes account reject debits or all movements; a frozen account can be frozen again with another scope
+func (db *Database) FreezeAccount(ctx context.Context, request *models.FreezeAccountRequest) (_ *models.Account, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "FreezeAccount")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	scope := FreezeScope(request)
+	return db.changeAccountStatus(request.AccountID, func(tx *gorm.DB, account *models.Account) (*models.AccountStatusChange, *models.CustomErr) {
+		if err := CheckFreeze(account, scope); err != nil {
+			return nil, err
+		}
+		return &models.AccountStatusChange{Status: models.AccountStatusFrozen, FreezeScope: scope, Reason: request.Reason}, nil
+	})
+}
+
+//UnfreezeAccount makes frozen account active again
+func (db *Database) UnfreezeAccount(ctx context.Context, request *models.UnfreezeAccountRequest) (_ *models.Account, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "UnfreezeAccount")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	return db.changeAccountStatus(request.AccountID, func(tx *gorm.DB, account *models.Account) (*models.AccountStatusChange, *models.CustomErr) {
+		if err := CheckUnfreeze(account); err != nil {
+			return nil, err
+		}
+		return &models.AccountStatusChange{Status: models.AccountStatusActive, Reason: request.Reason}, nil
+	})
+}
+
+//CloseAccount closes account for good; the rest of the balance is swept to another account in the same
+//database transaction
+func (db *Database) CloseAccount(ctx context.Context, request *models.CloseAccountRequest) (_ *models.Account, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "CloseAccount")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	return db.changeAccountStatus(request.AccountID, func(tx *gorm.DB, account *models.Account) (*models.AccountStatusChange, *models.CustomErr) {
+		if err := CheckClose(account, request); err != nil {
+			return nil, err
+		}
+		change := &models.AccountStatusChange{Status: models.AccountStatusClosed, Reason: request.Reason}
+		if account.Balance != 0 {
+			transactions, err := postEntry(tx, models.EntryKindSweep, time.Now(), SweepLegs(account, request.SweepTo))
+			if err != nil {
+				return nil, err
+			}
+			change.SweepEntryID = &transactions[0].EntryID
+		}
+		return change, nil
+	})
+}
+
+//SetCreditLimit sets the credit limit of account; the limit cannot be lowered below what is already used
+func (db *Database) SetCreditLimit(ctx context.Context, request *models.CreditLimitRequest) (_ *models.Account, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "SetCreditLimit")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	tx := db.Db.Begin()
+
+	account, err := lockAccount(tx, request.AccountID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = CheckCreditLimit(account, request.CreditLimit); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	result := tx.Model(&models.Account{ID: request.AccountID}).UpdateColumn("credit_limit", request.CreditLimit)
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, models.InternalError(result.Error)
+	}
+
+	tx.Commit()
+	account.CreditLimit = request.CreditLimit
+	account.Available = account.Headroom()
+	return account, nil
+}
+
+//GetAccountStatusHistory returns status changes of account with id=id in the order they were made
+func (db *Database) GetAccountStatusHistory(ctx context.Context, id int) (_ []models.AccountStatusChange, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "GetAccountStatusHistory")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	changes := make([]models.AccountStatusChange, 0)
+	result := db.Db.Where("account_id = ?", id).Order("status_change_id").Find(&changes)
+	if result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	return changes, nil
+}
+
+//changeAccountStatus locks account, lets transition check it and describe the change, then applies
+//and records the change
+func (db *Database) changeAccountStatus(id int,
+	transition func(tx *gorm.DB, account *models.Account) (*models.AccountStatusChange, *models.CustomErr)) (*models.Account, *models.CustomErr) {
+	tx := db.Db.Begin()
+	now := time.Now()
+
+	account, err := lockAccount(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	change, err := transition(tx, account)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	result := tx.Model(&models.Account{ID: id}).
+		Updates(map[string]interface{}{"status": change.Status, "freeze_scope": change.FreezeScope})
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, models.InternalError(result.Error)
+	}
+	change.AccountID = id
+	change.PreviousStatus = account.Status
+	change.CreatedAt = now
+	if result = tx.Create(change); result.Error != nil {
+		tx.Rollback()
+		return nil, models.InternalError(result.Error)
+	}
+
+	account, err = findAccount(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+	account.Available = account.Headroom()
+	return account, nil
+}
+
+//lockAccount locks account with id=id until the end of tx; returns a not found error if it does not exist
+func lockAccount(tx *gorm.DB, id int) (*models.Account, *models.CustomErr) {
+	//SQLite has no SELECT ... FOR UPDATE, an update locks the row in both databases
+	result := tx.Model(&models.Account{ID: id}).UpdateColumn("status", gorm.Expr("status"))
+	if result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, models.NotFoundError("account", id)
+	}
+	return findAccount(tx, id)
+}
+
+//findAccount returns account with id=id or nil if it does not exist
+func findAccount(tx *gorm.DB, id int) (*models.Account, *models.CustomErr) {
+	account := &models.Account{}
+	result := tx.Limit(1).Find(account, id)
+	if result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return account, nil
+}
+
+//FreezeScope returns scope of request; debits by default
+func FreezeScope(request *models.FreezeAccountRequest) string {
+	if request.Scope == "" {
+		return models.FreezeScopeDebits
+	}
+	return request.Scope
+}
+
+//CheckFreeze returns an error unless account can be frozen with scope
+func CheckFreeze(account *models.Account, scope string) *models.CustomErr {
+	if account.Status == models.AccountStatusClosed {
+		return StatusRejected(account)
+	}
+	if account.Status == models.AccountStatusFrozen && account.FreezeScope == scope {
+		return statusTransition(account, "is already frozen for %s", scope)
+	}
+	return nil
+}
+
+//CheckUnfreeze returns an error unless account is frozen
+func CheckUnfreeze(account *models.Account) *models.CustomErr {
+	if account.Status == models.AccountStatusClosed {
+		return StatusRejected(account)
+	}
+	if account.Status != models.AccountStatusFrozen {
+		return statusTransition(account, "is not frozen")
+	}
+	return nil
+}
+
+//CheckCreditLimit returns an error unless credit limit of account can be set to limit
+func CheckCreditLimit(account *models.Account, limit models.Money) *models.CustomErr {
+	if account.Status == models.AccountStatusClosed {
+		return StatusRejected(account)
+	}
+	if used := account.CreditLimit - account.Headroom(); used > limit {
+		return models.NewErrorf(models.ErrorCreditLimitCode,
+			"account [%v] uses [%v] of its credit line, the limit cannot be lowered to [%v]", account.ID, used, limit)
+	}
+	return nil
+}
+
+//CheckClose returns an error unless account can be closed by request: it has no active holds, no debt and its
+//balance is zero or there is an account to sweep it to
+func CheckClose(account *models.Account, request *models.CloseAccountRequest) *models.CustomErr {
+	if account.Status == models.AccountStatusClosed {
+		return StatusRejected(account)
+	}
+	if account.Held != 0 {
+		return statusTransition(account, "has [%v] held by active holds, capture or release them first", account.Held)
+	}
+	if account.Balance < 0 {
+		return statusTransition(account, "owes [%v] on its credit line, it must be repaid first", -account.Balance)
+	}
+	if account.Balance != 0 && request.SweepTo == 0 {
+		return statusTransition(account, "has balance [%v], it must be zero or swept to another account", account.Balance)
+	}
+	return nil
+}
+
+//StatusRejected reports that the status of account does not allow to change its balance
+func StatusRejected(account *models.Account) *models.CustomErr {
+	if account.Status == models.AccountStatusClosed {
+		return models.NewErrorf(models.ErrorAccountClosedCode, "account [%v] is closed", account.ID)
+	}
+	return models.NewErrorf(models.ErrorAccountFrozenCode,
+		"account [%v] is frozen for %s", account.ID, account.FreezeScope)
+}
+
+//InsufficientFunds reports that amount cannot be debited from account with id=id; account is nil if it does not exist
+func InsufficientFunds(id int, account *models.Account, amount models.Money) *models.CustomErr {
+	var headroom models.Money
+	if account != nil {
+		headroom = account.Headroom()
+	}
+	return models.NewErrorf(models.ErrorInsufficientFundsCode,
+		"insuffisient funds on account [%v]: [%v] requested, headroom is [%v]", id, amount, headroom)
+}
+
+func statusTransition(account *models.Account, format string, args ...interface{}) *models.CustomErr {
+	return models.NewErrorf(models.ErrorAccountStatusCode,
+		"account [%v] %s", account.ID, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestSQLiteFreezeAccount(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+
+	account, cErr := db.FreezeAccount(ctx, &models.FreezeAccountRequest{AccountID: 1, Reason: "fraud check"})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, account.Status, models.AccountStatusFrozen)
+	assert.Equal(t, account.FreezeScope, models.FreezeScopeDebits)
+
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -1000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountFrozenCode)
+	_, cErr = db.PlaceHold(ctx, &models.HoldRequest{AccountID: 1, Amount: 1000, OrderID: "order-1"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountFrozenCode)
+	_, cErr = db.MakeTransfer(ctx, &models.TransferRequest{ID1: 2, ID2: 1, Delta: 1000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 1000})
+	assert.Equal(t, cErr == nil, true)
+
+	_, cErr = db.FreezeAccount(ctx, &models.FreezeAccountRequest{AccountID: 1, Reason: "again"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountStatusCode)
+	_, cErr = db.FreezeAccount(ctx, &models.FreezeAccountRequest{AccountID: 1, Scope: models.FreezeScopeAll, Reason: "court order"})
+	assert.Equal(t, cErr == nil, true)
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 1000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountFrozenCode)
+
+	account, cErr = db.UnfreezeAccount(ctx, &models.UnfreezeAccountRequest{AccountID: 1, Reason: "resolved"})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, account.Status, models.AccountStatusActive)
+	assert.Equal(t, account.FreezeScope, "")
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -1000})
+	assert.Equal(t, cErr == nil, true)
+	_, cErr = db.UnfreezeAccount(ctx, &models.UnfreezeAccountRequest{AccountID: 1, Reason: "resolved"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountStatusCode)
+
+	changes, _ := db.GetAccountStatusHistory(ctx, 1)
+	assert.Equal(t, len(changes), 3)
+	assert.Equal(t, changes[0].PreviousStatus, models.AccountStatusActive)
+	assert.Equal(t, changes[1].FreezeScope, models.FreezeScopeAll)
+	assert.Equal(t, changes[2].Status, models.AccountStatusActive)
+	assert.Equal(t, changes[2].Reason, "resolved")
+}
+
+func TestSQLiteFreezeUnknownAccount(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+
+	_, cErr := db.FreezeAccount(ctx, &models.FreezeAccountRequest{AccountID: 999999, Reason: "typo"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorNotFoundCode)
+	_, cErr = db.SetCreditLimit(ctx, &models.CreditLimitRequest{AccountID: 999999, CreditLimit: 1000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorNotFoundCode)
+
+	var accounts, changes int64
+	db.Db.Model(&models.Account{}).Where("account_id = ?", 999999).Count(&accounts)
+	assert.Equal(t, accounts, int64(0))
+	db.Db.Model(&models.AccountStatusChange{}).Count(&changes)
+	assert.Equal(t, changes, int64(0))
+}
+
+func TestSQLiteCloseAccount(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+	hold, _ := db.PlaceHold(ctx, &models.HoldRequest{AccountID: 1, Amount: 1000, OrderID: "order-1"})
+
+	_, cErr := db.CloseAccount(ctx, &models.CloseAccountRequest{AccountID: 1, SweepTo: 2, Reason: "customer request"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountStatusCode)
+	db.ReleaseHold(ctx, hold.ID)
+	_, cErr = db.CloseAccount(ctx, &models.CloseAccountRequest{AccountID: 1, Reason: "customer request"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountStatusCode)
+
+	//a frozen account is swept anyway
+	db.FreezeAccount(ctx, &models.FreezeAccountRequest{AccountID: 1, Scope: models.FreezeScopeAll, Reason: "court order"})
+	account, cErr := db.CloseAccount(ctx, &models.CloseAccountRequest{AccountID: 1, SweepTo: 2, Reason: "customer request"})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, account.Status, models.AccountStatusClosed)
+	assert.Equal(t, account.Balance, models.Money(0))
+	target, _ := db.GetBalance(ctx, 2)
+	assert.Equal(t, target.Balance, models.Money(10000))
+
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 1000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountClosedCode)
+	_, cErr = db.MakeTransfer(ctx, &models.TransferRequest{ID1: 2, ID2: 1, Delta: 1000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountClosedCode)
+	_, cErr = db.UnfreezeAccount(ctx, &models.UnfreezeAccountRequest{AccountID: 1, Reason: "reopen"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountClosedCode)
+
+	changes, _ := db.GetAccountStatusHistory(ctx, 1)
+	assert.Equal(t, len(changes), 2)
+	assert.Equal(t, changes[1].PreviousStatus, models.AccountStatusFrozen)
+	assert.Equal(t, changes[1].SweepEntryID != nil, true)
+	history, _ := db.GetTransactionHistory(ctx, &models.HistoryRequest{AccountID: 1, Sort: models.SortByTimeString,
+		Order: models.OrderAscendingString, Page: -1, Kinds: []string{models.TransactionKindSweep}})
+	assert.Equal(t, len(history.Transactions), 1)
+	assert.Equal(t, history.Transactions[0].EntryID, *changes[1].SweepEntryID)
+
+	//an account that does not exist is not created
+	_, cErr = db.CloseAccount(ctx, &models.CloseAccountRequest{AccountID: 3, Reason: "unused"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorNotFoundCode)
+}
+
+func TestSQLiteCreditLimit(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 1000})
+
+	_, cErr := db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -5000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+	assert.Equal(t, cErr.Err.Error(), "insuffisient funds on account [1]: [50.00] requested, headroom is [10.00]")
+
+	account, cErr := db.SetCreditLimit(ctx, &models.CreditLimitRequest{AccountID: 1, CreditLimit: 10000})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, account.Available, models.Money(11000))
+
+	tr, cErr := db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 8000})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, tr.Remaining, models.Money(-7000))
+	_, cErr = db.PlaceHold(ctx, &models.HoldRequest{AccountID: 1, Amount: 4000, OrderID: "order-1"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -3001})
+	assert.Equal(t, cErr.Err.Error(), "insuffisient funds on account [1]: [30.01] requested, headroom is [30.00]")
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -3000})
+	assert.Equal(t, cErr == nil, true)
+
+	_, cErr = db.SetCreditLimit(ctx, &models.CreditLimitRequest{AccountID: 1, CreditLimit: 5000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorCreditLimitCode)
+	_, cErr = db.CloseAccount(ctx, &models.CloseAccountRequest{AccountID: 1, SweepTo: 2, Reason: "customer request"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountStatusCode)
+
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+	account, cErr = db.SetCreditLimit(ctx, &models.CreditLimitRequest{AccountID: 1, CreditLimit: 0})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, account.Available, models.Money(0))
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -1})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/dalconoid/balance-service/models"
+)
+
+//session returns a copy of db which runs queries with ctx limited by the timeout of operation; the copy must not
+//be used after cancel is called
+func (db *Database) session(ctx context.Context, operation string) (*Database, context.CancelFunc) {
+	cancel := context.CancelFunc(func() {})
+	if timeout := db.Timeouts.Of(operation); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	session := *db
+	session.Db = db.Db.WithContext(ctx)
+	session.ctx = ctx
+	return &session, cancel
+}
+
+//canceled replaces error *cErr of an operation with the error of its context if the context is done: the database
+//errors of a canceled operation only say that it was cut short, and its transaction is rolled back
+func (db *Database) canceled(cErr **models.CustomErr) {
+	if *cErr == nil {
+		return
+	}
+	if err := Canceled(db.ctx); err != nil {
+		*cErr = err
+	}
+}
+
+//Canceled returns the error of an operation canceled by ctx; nil if ctx is not done
+func Canceled(ctx context.Context) *models.CustomErr {
+	if ctx.Err() == nil {
+		return nil
+	}
+	return models.NewErrorf(models.ErrorCanceledCode, "operation canceled: %w", ctx.Err())
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestSQLiteCanceled(t *testing.T) {
+	db := openTestDatabase(t)
+	db.UpdateBalance(context.Background(), &models.ChangeBalanceRequest{ID: 1, Delta: 5000})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, cErr := db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 2000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorCanceledCode)
+	assert.Equal(t, errors.Is(cErr.Err, context.Canceled), true)
+
+	//canceled while the history is streamed
+	ctx, cancel = context.WithCancel(context.Background())
+	db.UpdateBalance(context.Background(), &models.ChangeBalanceRequest{ID: 1, Delta: 100})
+	streamed := 0
+	cErr = db.StreamTransactionHistory(ctx, &models.HistoryRequest{AccountID: 1, Sort: models.SortByTimeString,
+		Order: models.OrderAscendingString}, func(*models.Transaction) error {
+		streamed++
+		cancel()
+		return nil
+	})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorCanceledCode)
+	assert.Equal(t, streamed, 1)
+
+	account, _ := db.GetBalance(context.Background(), 1)
+	assert.Equal(t, account.Balance, models.Money(5100))
+}
+
+func TestSQLiteTimeout(t *testing.T) {
+	db := openTestDatabase(t)
+	db.Timeouts = models.Timeouts{Default: time.Minute, Operations: map[string]time.Duration{"updatebalance": time.Nanosecond}}
+
+	_, cErr := db.UpdateBalance(context.Background(), &models.ChangeBalanceRequest{ID: 1, Delta: 5000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorCanceledCode)
+	assert.Equal(t, errors.Is(cErr.Err, context.DeadlineExceeded), true)
+
+	account, cErr := db.GetBalance(context.Background(), 1)
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, account.Balance, models.Money(0))
+}
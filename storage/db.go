@@ -1,186 +1,471 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/dalconoid/balance-service/models"
+	"github.com/jackc/pgconn"
+	"github.com/mattn/go-sqlite3"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"strings"
 	"time"
 )
 
+//postgres check_violation error code
+const pgCheckViolation = "23514"
+
 //Database represents a real database
 type Database struct {
-	Db         *gorm.DB
-	ConnString string
+	Db            *gorm.DB
+	Driver        string
+	ConnString    string
 	PaginationNum int
+	//IdempotencyRetention is how long idempotency keys are kept; zero keeps them forever
+	IdempotencyRetention time.Duration
+	//ScheduleRetryPolicy is the retry policy of schedules created without one
+	ScheduleRetryPolicy models.RetryPolicy
+	//Timeouts limit the time operations take
+	Timeouts models.Timeouts
+
+	//ctx is the context of a session, see session
+	ctx context.Context
 }
 
-//Open establishes a connection to database
+//Open establishes a connection to database; Driver defaults to postgres
 func (db *Database) Open() error {
 	var err error
-	db.Db, err = gorm.Open(postgres.Open(db.ConnString), &gorm.Config{})
+	switch db.Driver {
+	case models.DriverSQLite:
+		return db.openSQLite()
+	case "", models.DriverPostgres:
+		db.Db, err = gorm.Open(postgres.Open(db.ConnString), &gorm.Config{})
+	default:
+		return fmt.Errorf("Open: unsupported driver [%s]", db.Driver)
+	}
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+//Close closes the connection pool; queries running at the moment fail
+func (db *Database) Close() error {
+	sqlDb, err := db.Db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.Close()
+}
+
 //GetBalance returns account with id=id
-func (db *Database) GetBalance(id int) (*models.Account, *models.CustomErr) {
+func (db *Database) GetBalance(ctx context.Context, id int) (_ *models.Account, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "GetBalance")
+	defer cancel()
+	defer db.canceled(&cErr)
+
 	var account = &models.Account{}
 	result := db.Db.First(account, id)
 	if result.Error != nil && result.Error == gorm.ErrRecordNotFound {
-		return &models.Account{ID: id, Balance: 0}, nil
+		return &models.Account{ID: id, Balance: 0, Status: models.AccountStatusActive}, nil
 	} else if result.Error != nil {
-		return nil, &models.CustomErr{Err: fmt.Errorf("GetBalance: %v", result.Error), ErrorCode: models.ErrorDefaultCode}
+		return nil, models.InternalError(fmt.Errorf("GetBalance: %v", result.Error))
 	}
+	account.Available = account.Headroom()
 
 	return account, nil
 }
 
-//GetTransactionHistory returns transaction history sorted by time/sum asc/desc; supports pagination
-func (db *Database) GetTransactionHistory(accId int, sorting string, order string, page int) ([]models.Transaction, *models.CustomErr) {
+//GetBalanceAsOf returns balance of account with id=id at time asOf, i.e. remaining of its last transaction until asOf.
+//Transactions sharing a timestamp are ordered by id: changes of one account are serialized by the account row lock
+func (db *Database) GetBalanceAsOf(ctx context.Context, id int, asOf time.Time) (_ *models.BalanceAsOf, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "GetBalanceAsOf")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	balance := &models.BalanceAsOf{ID: id, AsOf: asOf}
+	transaction := &models.Transaction{}
+	//stored in the same time zone as other timestamps so that SQLite can compare them as text
+	result := db.Db.Where("account_id = ? AND created_at <= ?", id, asOf.In(time.Now().Location())).
+		Order("created_at desc, transaction_id desc").Limit(1).Find(transaction)
+	if result.Error != nil {
+		return nil, models.InternalError(fmt.Errorf("GetBalanceAsOf: %v", result.Error))
+	}
+	if result.RowsAffected > 0 {
+		balance.Balance = transaction.Remaining
+		balance.TransactionID = &transaction.ID
+	}
+	return balance, nil
+}
+
+//GetSystemAccounts returns system accounts with their balances
+func (db *Database) GetSystemAccounts(ctx context.Context) (_ []models.SystemAccount, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "GetSystemAccounts")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	accounts := make([]models.Account, 0, len(models.SystemAccountNames))
+	result := db.Db.Where("account_id < 0").Order("account_id desc").Find(&accounts)
+	if result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+
+	systemAccounts := make([]models.SystemAccount, 0, len(accounts))
+	for _, account := range accounts {
+		systemAccounts = append(systemAccounts, models.SystemAccount{
+			ID:      account.ID,
+			Name:    models.SystemAccountNames[account.ID],
+			Balance: account.Balance,
+		})
+	}
+	return systemAccounts, nil
+}
+
+//GetTransactionHistory returns transaction history sorted by time/sum asc/desc with ties broken by transaction id;
+//supports page and cursor pagination
+func (db *Database) GetTransactionHistory(ctx context.Context, request *models.HistoryRequest) (_ *models.HistoryPage, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "GetTransactionHistory")
+	defer cancel()
+	defer db.canceled(&cErr)
+
 	history := make([]models.Transaction, 0, 0)
 
-	query := db.Db.Where("account_id = ?", accId)
-	var sortStr string
-	switch sorting {
+	query := db.Db.Where("account_id = ?", request.AccountID)
+	filterHistory(query, request)
+	var column string
+	switch request.Sort {
 	case models.SortByTimeString:
-		sortStr = "created_at "
+		column = "created_at"
 	case models.SortBySumString:
-		sortStr = "delta "
+		column = "delta"
 	}
-	sortStr += order
-	query.Order(sortStr)
 
-	if page > 0 {
-		query.Limit(db.PaginationNum).Offset((page-1)*db.PaginationNum)
+	if request.Page != 0 {
+		query.Order(column + " " + request.Order + ", transaction_id " + request.Order)
+		if request.Page > 0 {
+			query.Limit(db.PaginationNum).Offset((request.Page - 1) * db.PaginationNum)
+		}
+		if result := query.Find(&history); result.Error != nil {
+			return nil, models.InternalError(result.Error)
+		}
+		return &models.HistoryPage{Transactions: history}, nil
 	}
 
-	result := query.Find(&history)
-	if result.Error != nil {
-		return nil, &models.CustomErr{
-			Err:       result.Error,
-			ErrorCode: models.ErrorDefaultCode,
+	//keyset pagination: a backward cursor scans in the opposite order and the result is reversed
+	cursor := request.Cursor
+	backward := cursor != nil && cursor.Backward
+	order, op := models.OrderAscendingString, ">"
+	if (request.Order == models.OrderDescendingString) != backward {
+		order, op = models.OrderDescendingString, "<"
+	}
+	if cursor != nil {
+		var key interface{} = cursor.Delta
+		if request.Sort == models.SortByTimeString {
+			//stored in the same time zone as other timestamps so that SQLite can compare them as text
+			key = cursor.CreatedAt.In(time.Now().Location())
 		}
+		query.Where(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND transaction_id %[2]s ?))", column, op), key, key, cursor.ID)
 	}
+	limit := HistoryLimit(request, db.PaginationNum)
+	query.Order(column + " " + order + ", transaction_id " + order).Limit(limit + 1)
 
-	return history, nil
+	if result := query.Find(&history); result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	more := len(history) > limit
+	if more {
+		history = history[:limit]
+	}
+	if backward {
+		for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+			history[i], history[j] = history[j], history[i]
+		}
+	}
+	return HistoryPage(request, history, more), nil
 }
 
-//UpdateBalance changes account balance
-func (db *Database) UpdateBalance(request *models.ChangeBalanceRequest) (*models.Transaction, *models.CustomErr) {
-	tx := db.Db.Begin()
-	now := time.Now()
-	account, err := updOrCreateAccBalance(tx, request.ID, request.Delta)
-	if err != nil {
-		tx.Rollback()
-		return nil, err
+//StreamTransactionHistory calls fn for every transaction of filtered and sorted history without loading it
+//into memory; pagination of request is ignored. Stops at the first error of fn
+func (db *Database) StreamTransactionHistory(ctx context.Context, request *models.HistoryRequest, fn func(*models.Transaction) error) (cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "StreamTransactionHistory")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	query := db.Db.Model(&models.Transaction{}).Where("account_id = ?", request.AccountID)
+	filterHistory(query, request)
+	column := "created_at"
+	if request.Sort == models.SortBySumString {
+		column = "delta"
 	}
+	query.Order(column + " " + request.Order + ", transaction_id " + request.Order)
 
-	transaction := &models.Transaction{
-		AccountID: account.ID,
-		CreatedAt: now,
-		Delta:     request.Delta,
-		Remaining: account.Balance,
-		Message:   fmt.Sprintf("Account [%v]: balance changed by [%.2f], [%.2f] remaining", account.ID, request.Delta, account.Balance),
+	rows, err := query.Rows()
+	if err != nil {
+		return models.InternalError(err)
 	}
+	defer rows.Close()
+	for rows.Next() {
+		transaction := &models.Transaction{}
+		if err = db.Db.ScanRows(rows, transaction); err != nil {
+			return models.InternalError(err)
+		}
+		if err = fn(transaction); err != nil {
+			return models.InternalError(err)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return models.InternalError(err)
+	}
+	return nil
+}
 
-	if err = writeTransaction(tx, transaction); err != nil {
-		tx.Rollback()
-		return nil, err
+//filterHistory adds filters of request to query
+func filterHistory(query *gorm.DB, request *models.HistoryRequest) {
+	//stored in the same time zone as other timestamps so that SQLite can compare them as text
+	location := time.Now().Location()
+	if request.From != nil {
+		query.Where("created_at >= ?", request.From.In(location))
+	}
+	if request.To != nil {
+		query.Where("created_at <= ?", request.To.In(location))
+	}
+	//amounts are cast explicitly because SQLite does not convert a text parameter compared to an expression
+	if request.MinAmount != nil {
+		query.Where("ABS(delta) >= CAST(? AS NUMERIC)", *request.MinAmount)
+	}
+	if request.MaxAmount != nil {
+		query.Where("ABS(delta) <= CAST(? AS NUMERIC)", *request.MaxAmount)
+	}
+	switch request.Direction {
+	case models.DirectionCredit:
+		query.Where("delta > 0")
+	case models.DirectionDebit:
+		query.Where("delta < 0")
+	}
+	if len(request.Kinds) > 0 {
+		query.Where("kind IN ?", request.Kinds)
 	}
+	if request.AfterID > 0 {
+		query.Where("transaction_id > ?", request.AfterID)
+	}
+}
+
+//UpdateBalance changes account balance; the change is balanced against cash-in or cash-out system account
+func (db *Database) UpdateBalance(ctx context.Context, request *models.ChangeBalanceRequest) (_ *models.Transaction, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "UpdateBalance")
+	defer cancel()
+	defer db.canceled(&cErr)
 
-	tx.Commit()
-	return transaction, nil
+	hash := RequestHash(models.IdempotencyScopeChangeBalance, request)
+	return db.withIdempotency(request.IdempotencyKey, hash, func(tx *gorm.DB) (*models.Transaction, *models.CustomErr) {
+		now := time.Now()
+		kind, legs := ChangeBalanceLegs(request)
+		transactions, err := postEntry(tx, kind, now, legs)
+		if err != nil {
+			return nil, err
+		}
+		if request.Delta < 0 {
+			if err = checkSpendingLimits(tx, request.ID, 0, now); err != nil {
+				return nil, err
+			}
+		}
+		return &transactions[0], nil
+	})
 }
 
 //MakeTransfer makes transfer between accounts
-func (db *Database) MakeTransfer(request *models.TransferRequest) (*models.Transaction, *models.CustomErr) {
-	tx := db.Db.Begin()
-	now := time.Now()
+func (db *Database) MakeTransfer(ctx context.Context, request *models.TransferRequest) (_ *models.Transaction, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "MakeTransfer")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	hash := RequestHash(models.IdempotencyScopeTransfer, request)
+	return db.withIdempotency(request.IdempotencyKey, hash, func(tx *gorm.DB) (*models.Transaction, *models.CustomErr) {
+		return makeTransfer(tx, request, time.Now())
+	})
+}
 
-	account1, err := updOrCreateAccBalance(tx, request.ID1, -request.Delta)
+//makeTransfer posts transfer of request at now and checks spending limits of the payer; returns the transaction
+//of the payer
+func makeTransfer(tx *gorm.DB, request *models.TransferRequest, now time.Time) (*models.Transaction, *models.CustomErr) {
+	transactions, err := postEntry(tx, models.EntryKindTransfer, now, TransferLegs(request))
 	if err != nil {
-		tx.Rollback()
 		return nil, err
 	}
-	account2, err := updOrCreateAccBalance(tx, request.ID2, request.Delta)
-	if err != nil {
-		tx.Rollback()
+	if err = checkSpendingLimits(tx, request.ID1, request.Delta, now); err != nil {
 		return nil, err
 	}
+	return &transactions[0], nil
+}
 
-	transaction1 := &models.Transaction{
-		AccountID: account1.ID,
-		CreatedAt: now,
-		Delta:     -request.Delta,
-		Remaining: account1.Balance,
-		Message: fmt.Sprintf("Transfer from account [%v] to account [%v]: balance changed by [%.2f], [%.2f] remaining",
-			account1.ID, account2.ID, -request.Delta, account1.Balance),
-	}
-	transaction2 := &models.Transaction{
-		AccountID: account2.ID,
-		CreatedAt: now,
-		Delta:     request.Delta,
-		Remaining: account2.Balance,
-		Message: fmt.Sprintf("Transfer from account [%v] to account [%v]: balance changed by [%.2f], [%.2f] remaining",
-			account1.ID, account2.ID, request.Delta, account2.Balance),
-	}
+//MakeBatchTransfer makes all transfers of request in one database transaction or none of them;
+//returns transactions of all legs of every transfer
+func (db *Database) MakeBatchTransfer(ctx context.Context, request *models.BatchTransferRequest) (_ []models.Transaction, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "MakeBatchTransfer")
+	defer cancel()
+	defer db.canceled(&cErr)
 
-	if err = writeTransaction(tx, transaction1); err != nil {
-		tx.Rollback()
-		return nil, err
+	tx := db.Db.Begin()
+	now := time.Now()
+
+	transactions := make([]models.Transaction, 0, 2*len(request.Transfers))
+	for i := range request.Transfers {
+		posted, err := postEntry(tx, models.EntryKindTransfer, now, TransferLegs(&request.Transfers[i]))
+		if err == nil {
+			err = checkSpendingLimits(tx, request.Transfers[i].ID1, request.Transfers[i].Delta, now)
+		}
+		if err != nil {
+			tx.Rollback()
+			return nil, BatchTransferFailed(i, err)
+		}
+		transactions = append(transactions, posted...)
 	}
-	if err = writeTransaction(tx, transaction2); err != nil {
-		tx.Rollback()
-		return nil, err
+
+	if result := tx.Commit(); result.Error != nil {
+		return nil, models.InternalError(result.Error)
 	}
+	return transactions, nil
+}
 
-	tx.Commit()
-	return transaction1, err
+//BatchTransferFailed reports failed transfer i of a batch keeping the error code of the cause
+func BatchTransferFailed(i int, err *models.CustomErr) *models.CustomErr {
+	return models.NewErrorf(err.ErrorCode, "transfers[%v]: %w; no transfers were made", i, err.Err)
 }
 
-func updOrCreateAccBalance(tx *gorm.DB, id int, delta float64) (*models.Account, *models.CustomErr) {
-	result := tx.Model(&models.Account{ID: id}).UpdateColumn("balance", gorm.Expr("balance + ?", delta))
+func updOrCreateAccBalance(tx *gorm.DB, leg *EntryLeg) (*models.Account, *models.CustomErr) {
+	id, delta := leg.AccountID, leg.Delta
+	query := tx.Model(&models.Account{ID: id})
+	if !leg.IgnoreStatus {
+		//status is checked in the update itself so that a concurrent freeze or close cannot be missed
+		query = acceptsDelta(query, delta)
+	}
+	if delta < 0 && !models.IsSystemAccount(id) {
+		query = hasHeadroom(query, -delta)
+	}
+	result := query.UpdateColumn("balance", gorm.Expr("balance + ?", delta))
 	if result.Error != nil {
-		if strings.Contains(result.Error.Error(), models.InsufficientFundsMessage) {
-			return nil, &models.CustomErr{
-				Err:       fmt.Errorf("insuffisient funds on account [%v]", id),
-				ErrorCode: models.ErrorInsufficientFundsCode,
-			}
+		if isInsufficientFunds(result.Error) {
+			return nil, insufficientFunds(id)
 		}
-		return nil, &models.CustomErr{Err: result.Error, ErrorCode: models.ErrorDefaultCode}
+		return nil, models.InternalError(result.Error)
 	}
 	if result.RowsAffected == 0 {
+		account, err := findAccount(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if account != nil && !leg.IgnoreStatus && !account.Accepts(delta) {
+			return nil, StatusRejected(account)
+		}
+		if account != nil {
+			return nil, InsufficientFunds(id, account, -delta)
+		}
 		//create account if delta > 0
 		if delta >= 0 {
-			account := &models.Account{ID: id, Balance: delta}
-			result = tx.Create(account)
-		} else {
-			return nil, &models.CustomErr{
-				Err:       fmt.Errorf("insuffisient funds on account [%v]", id),
-				ErrorCode: models.ErrorInsufficientFundsCode,
+			account := &models.Account{ID: id, Balance: delta, Status: models.AccountStatusActive}
+			if result = tx.Create(account); result.Error != nil {
+				return nil, models.InternalError(result.Error)
 			}
+		} else {
+			return nil, InsufficientFunds(id, nil, -delta)
 		}
 	}
-	fmt.Printf("UPDATE BALANCE: rows affected = [%v]", result.RowsAffected)
 	account := &models.Account{}
-	tx.First(account, id)
+	if result = tx.First(account, id); result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
 
 	return account, nil
 }
 
+//acceptsDelta restricts query to accounts whose status allows to change balance by delta, see Account.Accepts
+func acceptsDelta(query *gorm.DB, delta models.Money) *gorm.DB {
+	if delta >= 0 {
+		return query.Where("(status = ? OR (status = ? AND freeze_scope = ?))",
+			models.AccountStatusActive, models.AccountStatusFrozen, models.FreezeScopeDebits)
+	}
+	return query.Where("status = ?", models.AccountStatusActive)
+}
+
+//hasHeadroom restricts query to accounts from which amount can be debited including their credit line;
+//debits are checked here rather than by the constraints so that the error can report the headroom
+func hasHeadroom(query *gorm.DB, amount models.Money) *gorm.DB {
+	//SQLite stores NUMERIC values with a fractional part as REAL and does not convert a text parameter
+	return query.Where("ROUND(balance - held + credit_limit - CAST(? AS NUMERIC), 2) >= 0", amount)
+}
+
+//postEntry writes journal entry of kind, applies its legs to account balances and writes their transactions
+//and outbox events
+func postEntry(tx *gorm.DB, kind string, now time.Time, legs []EntryLeg) ([]models.Transaction, *models.CustomErr) {
+	if err := CheckBalanced(legs); err != nil {
+		return nil, err
+	}
+	entry := &models.JournalEntry{Kind: kind, CreatedAt: now}
+	if result := tx.Create(entry); result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+
+	transactions := make([]models.Transaction, 0, len(legs))
+	for i := range legs {
+		account, err := updOrCreateAccBalance(tx, &legs[i])
+		if err != nil {
+			return nil, err
+		}
+		transaction := legs[i].Transaction(entry, account.Balance)
+		if err = writeTransaction(tx, &transaction); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+
+	//events are written in the same database transaction, so they exist only if the change is committed
+	if events := BalanceEvents(transactions, now); len(events) > 0 {
+		if result := tx.Create(&events); result.Error != nil {
+			return nil, models.InternalError(result.Error)
+		}
+	}
+	return transactions, nil
+}
+
 func writeTransaction(tx *gorm.DB, transaction *models.Transaction) *models.CustomErr {
 	result := tx.Create(transaction)
 	if result.Error != nil {
-		return &models.CustomErr{
-			Err:       result.Error,
-			ErrorCode: models.ErrorDefaultCode,
-		}
+		return models.InternalError(result.Error)
 	}
-	fmt.Printf("WRITE TRANSACTION: rows affected = [%v]", result.RowsAffected)
 	return nil
 }
 
+//insufficientFunds reports a violation of insufficientFundsConstraints; the headroom is not known then
+func insufficientFunds(id int) *models.CustomErr {
+	return models.NewErrorf(models.ErrorInsufficientFundsCode, "insuffisient funds on account [%v]", id)
+}
+
+//insufficientFundsConstraints are the account constraints violated by overdrafts
+var insufficientFundsConstraints = []string{models.InsufficientFundsMessage, models.InsufficientAvailableFundsMessage}
+
+//isInsufficientFunds reports whether err is a violation of one of insufficientFundsConstraints
+func isInsufficientFunds(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if pgErr.Code != pgCheckViolation {
+			return false
+		}
+		for _, name := range insufficientFundsConstraints {
+			if pgErr.ConstraintName == name {
+				return true
+			}
+		}
+		return false
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode != sqlite3.ErrConstraintCheck {
+		return false
+	}
+	for _, name := range insufficientFundsConstraints {
+		if strings.Contains(err.Error(), name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/magiconair/properties/assert"
+)
+
+func openTestDatabase(t *testing.T) *Database {
+	db := &Database{
+		Driver:        models.DriverSQLite,
+		ConnString:    filepath.Join(t.TempDir(), "balance.db") + "?_sync=OFF&_journal=MEMORY",
+		PaginationNum: 2,
+	}
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLiteUpdateBalance(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+
+	_, cErr := db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -1000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+
+	tr, cErr := db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10050})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, tr.Remaining, models.Money(10050))
+
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -20000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+
+	account, _ := db.GetBalance(ctx, 1)
+	assert.Equal(t, account.Balance, models.Money(10050))
+}
+
+func TestSQLiteUpdateBalanceCreateFails(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.Db.Exec("CREATE TRIGGER fail_insert BEFORE INSERT ON accounts BEGIN SELECT RAISE(ABORT, 'database is locked'); END")
+
+	//the failed insert is reported rather than a later error caused by the missing account
+	_, cErr := db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 1000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorDefaultCode)
+	assert.Matches(t, cErr.Error(), "database is locked")
+	var transactions int64
+	db.Db.Model(&models.Transaction{}).Where("account_id = ?", 1).Count(&transactions)
+	assert.Equal(t, transactions, int64(0))
+}
+
+func TestSQLiteMakeTransfer(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 5000})
+
+	_, cErr := db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 6000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+
+	_, cErr = db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 2000})
+	assert.Equal(t, cErr == nil, true)
+
+	acc1, _ := db.GetBalance(ctx, 1)
+	acc2, _ := db.GetBalance(ctx, 2)
+	assert.Equal(t, acc1.Balance, models.Money(3000))
+	assert.Equal(t, acc2.Balance, models.Money(2000))
+
+	history, _ := db.GetTransactionHistory(ctx, &models.HistoryRequest{
+		AccountID: 1, Sort: models.SortBySumString, Order: models.OrderAscendingString, Page: 1})
+	assert.Equal(t, len(history.Transactions), 2)
+	assert.Equal(t, history.Transactions[0].Delta, models.Money(-2000))
+}
+
+func TestSQLiteIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+
+	request := &models.ChangeBalanceRequest{ID: 1, Delta: 1000, IdempotencyKey: "key-1"}
+	tr1, cErr := db.UpdateBalance(ctx, request)
+	assert.Equal(t, cErr == nil, true)
+	tr2, cErr := db.UpdateBalance(ctx, request)
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, tr2.ID, tr1.ID)
+
+	account, _ := db.GetBalance(ctx, 1)
+	assert.Equal(t, account.Balance, models.Money(1000))
+
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 2000, IdempotencyKey: "key-1"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorIdempotencyConflictCode)
+	_, cErr = db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 1000, IdempotencyKey: "key-1"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorIdempotencyConflictCode)
+}
+
+func TestSQLiteIdempotencyKeyExpires(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.IdempotencyRetention = time.Millisecond
+
+	request := &models.ChangeBalanceRequest{ID: 1, Delta: 1000, IdempotencyKey: "key-1"}
+	db.UpdateBalance(ctx, request)
+	time.Sleep(5 * time.Millisecond)
+	db.UpdateBalance(ctx, request)
+
+	account, _ := db.GetBalance(ctx, 1)
+	assert.Equal(t, account.Balance, models.Money(2000))
+
+	//keys are deleted by the sweep, not by requests
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 1000, IdempotencyKey: "key-2"})
+	time.Sleep(5 * time.Millisecond)
+	var keys int64
+	db.Db.Model(&models.IdempotencyKey{}).Count(&keys)
+	assert.Equal(t, keys, int64(2))
+	deleted, cErr := db.DeleteExpiredIdempotencyKeys(ctx)
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, deleted, 2)
+}
+
+func TestSQLiteMakeBatchTransfer(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+
+	_, cErr := db.MakeBatchTransfer(ctx, &models.BatchTransferRequest{Transfers: []models.TransferRequest{
+		{ID1: 1, ID2: 2, Delta: 6000},
+		{ID1: 1, ID2: 3, Delta: 6000},
+	}})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+	assert.Equal(t, cErr.Err.Error(), "transfers[1]: insuffisient funds on account [1]: [60.00] requested, headroom is [40.00]; no transfers were made")
+	acc1, _ := db.GetBalance(ctx, 1)
+	assert.Equal(t, acc1.Balance, models.Money(10000))
+
+	transactions, cErr := db.MakeBatchTransfer(ctx, &models.BatchTransferRequest{Transfers: []models.TransferRequest{
+		{ID1: 1, ID2: 2, Delta: 6000},
+		{ID1: 2, ID2: 3, Delta: 1000},
+	}})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, len(transactions), 4)
+	assert.Equal(t, transactions[3].Remaining, models.Money(1000))
+	acc2, _ := db.GetBalance(ctx, 2)
+	assert.Equal(t, acc2.Balance, models.Money(5000))
+}
@@ -0,0 +1,50 @@
+package storage
+
+import "github.com/dalconoid/balance-service/models"
+
+//HistoryPage makes a page of transactions fetched after request.Cursor in the direction of the cursor;
+//more reports whether there are transactions beyond the page in that direction
+func HistoryPage(request *models.HistoryRequest, transactions []models.Transaction, more bool) *models.HistoryPage {
+	page := &models.HistoryPage{Transactions: transactions}
+	cursor := request.Cursor
+	backward := cursor != nil && cursor.Backward
+
+	if len(transactions) == 0 {
+		//the way back is the cursor itself
+		if cursor != nil {
+			reverse := *cursor
+			reverse.Backward = !backward
+			if backward {
+				page.NextCursor = reverse.String()
+			} else {
+				page.PrevCursor = reverse.String()
+			}
+		}
+		return page
+	}
+
+	first := models.NewHistoryCursor(&transactions[0], request.Sort, request.Order, true)
+	last := models.NewHistoryCursor(&transactions[len(transactions)-1], request.Sort, request.Order, false)
+	if backward {
+		page.NextCursor = last.String()
+		if more {
+			page.PrevCursor = first.String()
+		}
+	} else {
+		if more {
+			page.NextCursor = last.String()
+		}
+		if cursor != nil {
+			page.PrevCursor = first.String()
+		}
+	}
+	return page
+}
+
+//HistoryLimit returns page size of request
+func HistoryLimit(request *models.HistoryRequest, paginationNum int) int {
+	if request.Limit > 0 {
+		return request.Limit
+	}
+	return paginationNum
+}
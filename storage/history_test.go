@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestSQLiteHistoryCursor(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	for _, d := range []models.Money{3000, 1000, 1000, 2000, 1000} {
+		db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: d})
+	}
+
+	for _, sorting := range []string{models.SortByTimeString, models.SortBySumString} {
+		for _, order := range []string{models.OrderAscendingString, models.OrderDescendingString} {
+			all, _ := db.GetTransactionHistory(ctx, &models.HistoryRequest{AccountID: 1, Sort: sorting, Order: order, Page: -1})
+
+			//forward through all pages
+			request := &models.HistoryRequest{AccountID: 1, Sort: sorting, Order: order, Limit: 2}
+			var pages []*models.HistoryPage
+			ids := make([]int, 0)
+			for {
+				page, cErr := db.GetTransactionHistory(ctx, request)
+				assert.Equal(t, cErr == nil, true)
+				pages = append(pages, page)
+				for _, tr := range page.Transactions {
+					ids = append(ids, tr.ID)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				request.Cursor, _ = models.ParseHistoryCursor(page.NextCursor)
+			}
+			assert.Equal(t, len(pages), 3)
+			assert.Equal(t, pages[0].PrevCursor, "")
+			assert.Equal(t, len(ids), len(all.Transactions))
+			for i := range ids {
+				assert.Equal(t, ids[i], all.Transactions[i].ID)
+			}
+
+			//back from the last page
+			request.Cursor, _ = models.ParseHistoryCursor(pages[2].PrevCursor)
+			page, _ := db.GetTransactionHistory(ctx, request)
+			assert.Equal(t, page.Transactions, pages[1].Transactions)
+			request.Cursor, _ = models.ParseHistoryCursor(page.PrevCursor)
+			page, _ = db.GetTransactionHistory(ctx, request)
+			assert.Equal(t, page.Transactions, pages[0].Transactions)
+			assert.Equal(t, page.PrevCursor, "")
+		}
+	}
+}
+
+func TestSQLiteHistoryCursorSkipsNewTransactions(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	for _, d := range []models.Money{3000, 1000, 2000} {
+		db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: d})
+	}
+
+	request := &models.HistoryRequest{AccountID: 1, Sort: models.SortByTimeString, Order: models.OrderDescendingString, Limit: 2}
+	page, _ := db.GetTransactionHistory(ctx, request)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 500})
+
+	request.Cursor, _ = models.ParseHistoryCursor(page.NextCursor)
+	page, _ = db.GetTransactionHistory(ctx, request)
+	assert.Equal(t, len(page.Transactions), 1)
+	assert.Equal(t, page.Transactions[0].Delta, models.Money(3000))
+	assert.Equal(t, page.NextCursor, "")
+}
+
+func TestSQLiteHistoryFilters(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 500000})
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -150000})
+	from := time.Now()
+	db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 120000})
+	db.MakeTransfer(ctx, &models.TransferRequest{ID1: 2, ID2: 1, Delta: 20000})
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -50000})
+	to := time.Now()
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -110000})
+
+	min := models.Money(100000)
+	max := models.Money(130000)
+	request := &models.HistoryRequest{AccountID: 1, Sort: models.SortByTimeString, Order: models.OrderAscendingString, Page: -1,
+		Direction: models.DirectionDebit, MinAmount: &min}
+	history, cErr := db.GetTransactionHistory(ctx, request)
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, len(history.Transactions), 3)
+
+	request.MaxAmount = &max
+	history, _ = db.GetTransactionHistory(ctx, request)
+	assert.Equal(t, len(history.Transactions), 2)
+
+	request.From, request.To = &from, &to
+	history, _ = db.GetTransactionHistory(ctx, request)
+	assert.Equal(t, len(history.Transactions), 1)
+	assert.Equal(t, history.Transactions[0].Kind, models.TransactionKindTransferOut)
+
+	request = &models.HistoryRequest{AccountID: 1, Sort: models.SortBySumString, Order: models.OrderAscendingString, Limit: 1,
+		Kinds: []string{models.TransactionKindTransferIn, models.TransactionKindDeposit}}
+	history, _ = db.GetTransactionHistory(ctx, request)
+	assert.Equal(t, history.Transactions[0].Delta, models.Money(20000))
+	request.Cursor, _ = models.ParseHistoryCursor(history.NextCursor)
+	history, _ = db.GetTransactionHistory(ctx, request)
+	assert.Equal(t, history.Transactions[0].Delta, models.Money(500000))
+	assert.Equal(t, history.NextCursor, "")
+
+	request = &models.HistoryRequest{AccountID: 1, Sort: models.SortByTimeString, Order: models.OrderAscendingString, Page: -1,
+		Direction: models.DirectionCredit}
+	history, _ = db.GetTransactionHistory(ctx, request)
+	assert.Equal(t, len(history.Transactions), 2)
+
+	request = &models.HistoryRequest{AccountID: 1, Sort: models.SortByTimeString, Order: models.OrderAscendingString, Page: -1,
+		AfterID: history.Transactions[1].ID}
+	history, _ = db.GetTransactionHistory(ctx, request)
+	assert.Equal(t, len(history.Transactions), 2)
+	assert.Equal(t, history.Transactions[0].Delta, models.Money(-50000))
+}
+
+func TestSQLiteStreamTransactionHistory(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	for _, d := range []models.Money{3000, 1000, -2000} {
+		db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: d})
+	}
+
+	request := &models.HistoryRequest{AccountID: 1, Sort: models.SortByTimeString, Order: models.OrderAscendingString, Limit: 1}
+	deltas := make([]models.Money, 0)
+	cErr := db.StreamTransactionHistory(ctx, request, func(tr *models.Transaction) error {
+		deltas = append(deltas, tr.Delta)
+		return nil
+	})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, deltas, []models.Money{3000, 1000, -2000})
+
+	cErr = db.StreamTransactionHistory(ctx, request, func(tr *models.Transaction) error {
+		return fmt.Errorf("client gone")
+	})
+	assert.Equal(t, cErr.Err.Error(), "client gone")
+}
@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"context"
+	"github.com/dalconoid/balance-service/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+//GetHold returns hold with id=id
+func (db *Database) GetHold(ctx context.Context, id int) (_ *models.Hold, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "GetHold")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	return findHold(db.Db, id)
+}
+
+//PlaceHold reserves funds on account; reserved funds are not available for debits until the hold is finished
+func (db *Database) PlaceHold(ctx context.Context, request *models.HoldRequest) (_ *models.Hold, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "PlaceHold")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	tx := db.Db.Begin()
+	now := time.Now()
+
+	//a hold reserves funds for a debit, so it is placed only if the account accepts debits
+	result := hasHeadroom(acceptsDelta(tx.Model(&models.Account{ID: request.AccountID}), -request.Amount), request.Amount).
+		UpdateColumn("held", gorm.Expr("held + ?", request.Amount))
+	if result.Error != nil {
+		tx.Rollback()
+		if isInsufficientFunds(result.Error) {
+			return nil, insufficientFunds(request.AccountID)
+		}
+		return nil, models.InternalError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		account, err := findAccount(tx, request.AccountID)
+		tx.Rollback()
+		if err != nil {
+			return nil, err
+		}
+		if account != nil && !account.Accepts(-request.Amount) {
+			return nil, StatusRejected(account)
+		}
+		return nil, InsufficientFunds(request.AccountID, account, request.Amount)
+	}
+
+	hold := &models.Hold{
+		AccountID: request.AccountID,
+		OrderID:   request.OrderID,
+		Amount:    request.Amount,
+		Status:    models.HoldStatusActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if request.ExpiresAt != nil {
+		//stored in the same time zone as other timestamps so that SQLite can compare them as text
+		expiresAt := request.ExpiresAt.In(now.Location())
+		hold.ExpiresAt = &expiresAt
+	}
+	if result = tx.Create(hold); result.Error != nil {
+		tx.Rollback()
+		return nil, models.InternalError(result.Error)
+	}
+
+	tx.Commit()
+	return hold, nil
+}
+
+//CaptureHold charges the whole hold or a part of it; the rest of the hold is released
+func (db *Database) CaptureHold(ctx context.Context, request *models.CaptureHoldRequest) (_ *models.Transaction, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "CaptureHold")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	tx := db.Db.Begin()
+	now := time.Now()
+
+	hold, err := findHold(tx, request.HoldID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if hold.Expired(now) {
+		//the hold is released even though capture fails
+		if err = finishHold(tx, hold, models.HoldStatusExpired, 0, now); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		tx.Commit()
+		return nil, holdNotActive(hold)
+	}
+	if hold.Status != models.HoldStatusActive {
+		tx.Rollback()
+		return nil, holdNotActive(hold)
+	}
+
+	amount := request.Amount
+	if amount == 0 {
+		amount = hold.Amount
+	}
+	if amount > hold.Amount {
+		tx.Rollback()
+		return nil, models.NewErrorf(models.ErrorHoldAmountExceededCode,
+			"capture amount [%v] exceeds hold [%v] amount [%v]", amount, hold.ID, hold.Amount)
+	}
+
+	if err = finishHold(tx, hold, models.HoldStatusCaptured, amount, now); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	transactions, err := postEntry(tx, models.EntryKindCapture, now, CaptureLegs(hold, amount))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	//the captured amount leaves the account, the hold only reserved it
+	if err = checkSpendingLimits(tx, hold.AccountID, 0, now); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	tx.Commit()
+	return &transactions[0], nil
+}
+
+//ReleaseHold cancels hold and makes reserved funds available again
+func (db *Database) ReleaseHold(ctx context.Context, id int) (_ *models.Hold, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "ReleaseHold")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	tx := db.Db.Begin()
+	now := time.Now()
+
+	hold, err := findHold(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if hold.Status != models.HoldStatusActive {
+		tx.Rollback()
+		return nil, holdNotActive(hold)
+	}
+
+	status := models.HoldStatusReleased
+	if hold.Expired(now) {
+		status = models.HoldStatusExpired
+	}
+	if err = finishHold(tx, hold, status, 0, now); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	tx.Commit()
+	return hold, nil
+}
+
+//ReleaseExpiredHolds releases all active holds past their expiry and returns their number
+func (db *Database) ReleaseExpiredHolds(ctx context.Context) (_ int, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "ReleaseExpiredHolds")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	now := time.Now()
+	expired := make([]models.Hold, 0)
+	result := db.Db.Where("status = ? AND expires_at <= ?", models.HoldStatusActive, now).Find(&expired)
+	if result.Error != nil {
+		return 0, models.InternalError(result.Error)
+	}
+
+	released := 0
+	for i := range expired {
+		tx := db.Db.Begin()
+		err := finishHold(tx, &expired[i], models.HoldStatusExpired, 0, now)
+		if err != nil && err.ErrorCode == models.ErrorHoldNotActiveCode {
+			//finished concurrently
+			tx.Rollback()
+			continue
+		}
+		if err != nil {
+			tx.Rollback()
+			return released, err
+		}
+		tx.Commit()
+		released++
+	}
+	return released, nil
+}
+
+func findHold(tx *gorm.DB, id int) (*models.Hold, *models.CustomErr) {
+	hold := &models.Hold{}
+	result := tx.Limit(1).Find(hold, id)
+	if result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, models.NotFoundError("hold", id)
+	}
+	return hold, nil
+}
+
+//finishHold moves active hold to status recording captured amount and unreserves the hold amount;
+//captured amount is charged by the capture journal entry
+func finishHold(tx *gorm.DB, hold *models.Hold, status string, captured models.Money, now time.Time) *models.CustomErr {
+	result := tx.Model(&models.Hold{}).Where("hold_id = ? AND status = ?", hold.ID, models.HoldStatusActive).
+		Updates(map[string]interface{}{"status": status, "captured": captured, "updated_at": now})
+	if result.Error != nil {
+		return models.InternalError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return holdNotActive(hold)
+	}
+
+	result = tx.Model(&models.Account{ID: hold.AccountID}).UpdateColumn("held", gorm.Expr("held - ?", hold.Amount))
+	if result.Error != nil {
+		return models.InternalError(result.Error)
+	}
+
+	hold.Status = status
+	hold.Captured = captured
+	hold.UpdatedAt = now
+	return nil
+}
+
+func holdNotActive(hold *models.Hold) *models.CustomErr {
+	return models.NewErrorf(models.ErrorHoldNotActiveCode, "hold [%v] is not active", hold.ID)
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestSQLiteHoldCapture(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+
+	_, cErr := db.PlaceHold(ctx, &models.HoldRequest{AccountID: 1, Amount: 20000, OrderID: "order-1"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+
+	hold, cErr := db.PlaceHold(ctx, &models.HoldRequest{AccountID: 1, Amount: 6000, OrderID: "order-1"})
+	assert.Equal(t, cErr == nil, true)
+
+	account, _ := db.GetBalance(ctx, 1)
+	assert.Equal(t, account.Balance, models.Money(10000))
+	assert.Equal(t, account.Available, models.Money(4000))
+
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -5000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+
+	_, cErr = db.CaptureHold(ctx, &models.CaptureHoldRequest{HoldID: hold.ID, Amount: 7000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorHoldAmountExceededCode)
+
+	tr, cErr := db.CaptureHold(ctx, &models.CaptureHoldRequest{HoldID: hold.ID, Amount: 2500})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, tr.Delta, models.Money(-2500))
+	assert.Equal(t, tr.Remaining, models.Money(7500))
+
+	account, _ = db.GetBalance(ctx, 1)
+	assert.Equal(t, account.Held, models.Money(0))
+	assert.Equal(t, account.Available, models.Money(7500))
+
+	_, cErr = db.ReleaseHold(ctx, hold.ID)
+	assert.Equal(t, cErr.ErrorCode, models.ErrorHoldNotActiveCode)
+	_, cErr = db.GetHold(ctx, hold.ID+1)
+	assert.Equal(t, cErr.ErrorCode, models.ErrorNotFoundCode)
+}
+
+func TestSQLiteHoldReleaseAndExpiry(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+
+	hold, _ := db.PlaceHold(ctx, &models.HoldRequest{AccountID: 1, Amount: 3000, OrderID: "order-1"})
+	hold, cErr := db.ReleaseHold(ctx, hold.ID)
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, hold.Status, models.HoldStatusReleased)
+
+	expiresAt := time.Now().UTC().Add(100 * time.Millisecond)
+	hold, _ = db.PlaceHold(ctx, &models.HoldRequest{AccountID: 1, Amount: 3000, OrderID: "order-2", ExpiresAt: &expiresAt})
+	released, _ := db.ReleaseExpiredHolds(ctx)
+	assert.Equal(t, released, 0)
+
+	time.Sleep(150 * time.Millisecond)
+	released, _ = db.ReleaseExpiredHolds(ctx)
+	assert.Equal(t, released, 1)
+
+	hold, _ = db.GetHold(ctx, hold.ID)
+	assert.Equal(t, hold.Status, models.HoldStatusExpired)
+	account, _ := db.GetBalance(ctx, 1)
+	assert.Equal(t, account.Available, models.Money(10000))
+}
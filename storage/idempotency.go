@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/dalconoid/balance-service/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+//RequestHash returns a fingerprint of request made within scope; replays must match it
+func RequestHash(scope string, request interface{}) string {
+	data, _ := json.Marshal(request)
+	sum := sha256.Sum256(append([]byte(scope+":"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+//ReplayIdempotencyKey returns the response stored under key, or a conflict error if it was stored for another request
+func ReplayIdempotencyKey(stored *models.IdempotencyKey, hash string) (*models.Transaction, *models.CustomErr) {
+	if stored.RequestHash != hash {
+		return nil, models.NewErrorf(models.ErrorIdempotencyConflictCode,
+			"idempotency key [%s] was already used with a different request", stored.Key)
+	}
+	transaction := &models.Transaction{}
+	if err := json.Unmarshal([]byte(stored.Response), transaction); err != nil {
+		return nil, models.InternalError(err)
+	}
+	return transaction, nil
+}
+
+//withIdempotency runs op in a database transaction; if key is not empty, the result of op
+//is stored under key in the same transaction, and repeated calls replay the stored result
+func (db *Database) withIdempotency(key string, hash string, op func(tx *gorm.DB) (*models.Transaction, *models.CustomErr)) (*models.Transaction, *models.CustomErr) {
+	tx := db.Db.Begin()
+	if key != "" {
+		stored, err := db.findIdempotencyKey(tx, key)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if stored != nil {
+			tx.Rollback()
+			return ReplayIdempotencyKey(stored, hash)
+		}
+	}
+
+	transaction, err := op(tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if key != "" {
+		if err = saveIdempotencyKey(tx, key, hash, transaction); err != nil {
+			tx.Rollback()
+			//a concurrent request with the same key may have been committed first
+			if stored, _ := db.findIdempotencyKey(db.Db, key); stored != nil {
+				return ReplayIdempotencyKey(stored, hash)
+			}
+			return nil, err
+		}
+	}
+
+	if result := tx.Commit(); result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	return transaction, nil
+}
+
+//DeleteExpiredIdempotencyKeys deletes idempotency keys kept longer than IdempotencyRetention and returns their number
+func (db *Database) DeleteExpiredIdempotencyKeys(ctx context.Context) (_ int, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "DeleteExpiredIdempotencyKeys")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	if db.IdempotencyRetention <= 0 {
+		return 0, nil
+	}
+	result := db.Db.Where("created_at < ?", time.Now().Add(-db.IdempotencyRetention)).Delete(&models.IdempotencyKey{})
+	if result.Error != nil {
+		return 0, models.InternalError(result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
+
+//findIdempotencyKey returns unexpired stored key or nil. An expired key not yet deleted by
+//DeleteExpiredIdempotencyKeys is deleted, so that the key can be stored again
+func (db *Database) findIdempotencyKey(tx *gorm.DB, key string) (*models.IdempotencyKey, *models.CustomErr) {
+	stored := &models.IdempotencyKey{}
+	result := tx.Where("idempotency_key = ?", key).Limit(1).Find(stored)
+	if result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	if db.IdempotencyRetention > 0 && stored.CreatedAt.Before(time.Now().Add(-db.IdempotencyRetention)) {
+		if result = tx.Where("idempotency_key = ?", key).Delete(&models.IdempotencyKey{}); result.Error != nil {
+			return nil, models.InternalError(result.Error)
+		}
+		return nil, nil
+	}
+	return stored, nil
+}
+
+func saveIdempotencyKey(tx *gorm.DB, key string, hash string, transaction *models.Transaction) *models.CustomErr {
+	response, err := json.Marshal(transaction)
+	if err != nil {
+		return models.InternalError(err)
+	}
+	result := tx.Create(&models.IdempotencyKey{
+		Key:         key,
+		RequestHash: hash,
+		Response:    string(response),
+		CreatedAt:   time.Now(),
+	})
+	if result.Error != nil {
+		return models.InternalError(result.Error)
+	}
+	return nil
+}
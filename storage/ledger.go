@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/dalconoid/balance-service/models"
+)
+
+//EntryLeg is a transaction of a journal entry before the entry is posted
+type EntryLeg struct {
+	AccountID int
+	Delta     models.Money
+	Kind      string
+	//Prefix is the beginning of the transaction message
+	Prefix     string
+	ReversalOf *int
+	//IgnoreStatus lets the leg change balance of an account whose status rejects it; used to sweep closing accounts
+	IgnoreStatus bool
+}
+
+//Transaction returns transaction of the leg posted in entry; remaining is the account balance after the leg
+func (leg *EntryLeg) Transaction(entry *models.JournalEntry, remaining models.Money) models.Transaction {
+	return models.Transaction{
+		AccountID:  leg.AccountID,
+		EntryID:    entry.ID,
+		Kind:       leg.Kind,
+		CreatedAt:  entry.CreatedAt,
+		Delta:      leg.Delta,
+		Remaining:  remaining,
+		Message:    fmt.Sprintf("%s: balance changed by [%v], [%v] remaining", leg.Prefix, leg.Delta, remaining),
+		ReversalOf: leg.ReversalOf,
+	}
+}
+
+//ChangeBalanceLegs returns entry kind and legs of request; deposits come from cash-in and withdrawals go to cash-out
+func ChangeBalanceLegs(request *models.ChangeBalanceRequest) (string, []EntryLeg) {
+	prefix := fmt.Sprintf("Account [%v]", request.ID)
+	if request.Delta > 0 {
+		return models.EntryKindDeposit, []EntryLeg{
+			{AccountID: request.ID, Delta: request.Delta, Kind: models.TransactionKindDeposit, Prefix: prefix},
+			{AccountID: models.SystemAccountCashIn, Delta: -request.Delta, Kind: models.TransactionKindDeposit,
+				Prefix: fmt.Sprintf("Deposit to account [%v]", request.ID)},
+		}
+	}
+	return models.EntryKindWithdrawal, []EntryLeg{
+		{AccountID: request.ID, Delta: request.Delta, Kind: models.TransactionKindWithdrawal, Prefix: prefix},
+		{AccountID: models.SystemAccountCashOut, Delta: -request.Delta, Kind: models.TransactionKindWithdrawal,
+			Prefix: fmt.Sprintf("Withdrawal from account [%v]", request.ID)},
+	}
+}
+
+//TransferLegs returns legs of transfer request; a non-zero fee is charged from ID1 to the fees account
+func TransferLegs(request *models.TransferRequest) []EntryLeg {
+	prefix := fmt.Sprintf("Transfer from account [%v] to account [%v]", request.ID1, request.ID2)
+	legs := []EntryLeg{
+		{AccountID: request.ID1, Delta: -request.Delta, Kind: models.TransactionKindTransferOut, Prefix: prefix},
+		{AccountID: request.ID2, Delta: request.Delta, Kind: models.TransactionKindTransferIn, Prefix: prefix},
+	}
+	if request.Fee > 0 {
+		prefix = fmt.Sprintf("Fee for transfer from account [%v] to account [%v]", request.ID1, request.ID2)
+		legs = append(legs,
+			EntryLeg{AccountID: request.ID1, Delta: -request.Fee, Kind: models.TransactionKindFee, Prefix: prefix},
+			EntryLeg{AccountID: models.SystemAccountFees, Delta: request.Fee, Kind: models.TransactionKindFee, Prefix: prefix},
+		)
+	}
+	return legs
+}
+
+//CaptureLegs returns legs charging amount of hold to cash-out
+func CaptureLegs(hold *models.Hold, amount models.Money) []EntryLeg {
+	prefix := fmt.Sprintf("Account [%v]: hold [%v] for order [%s] captured", hold.AccountID, hold.ID, hold.OrderID)
+	return []EntryLeg{
+		{AccountID: hold.AccountID, Delta: -amount, Kind: models.TransactionKindCapture, Prefix: prefix},
+		{AccountID: models.SystemAccountCashOut, Delta: amount, Kind: models.TransactionKindCapture, Prefix: prefix},
+	}
+}
+
+//SweepLegs returns legs moving the whole balance of a closing account to account with id=to
+func SweepLegs(account *models.Account, to int) []EntryLeg {
+	prefix := fmt.Sprintf("Account [%v] closed, balance swept to account [%v]", account.ID, to)
+	return []EntryLeg{
+		{AccountID: account.ID, Delta: -account.Balance, Kind: models.TransactionKindSweep, Prefix: prefix, IgnoreStatus: true},
+		{AccountID: to, Delta: account.Balance, Kind: models.TransactionKindSweep, Prefix: prefix},
+	}
+}
+
+//ReversalLegs returns legs compensating amount of every leg of an entry except fees, which are not refunded
+func ReversalLegs(entry []models.Transaction, amount models.Money) []EntryLeg {
+	legs := make([]EntryLeg, 0, len(entry))
+	for i := range entry {
+		original := &entry[i]
+		if original.Kind == models.TransactionKindFee {
+			continue
+		}
+		delta := amount
+		if original.Delta > 0 {
+			delta = -amount
+		}
+		legs = append(legs, EntryLeg{
+			AccountID:  original.AccountID,
+			Delta:      delta,
+			Kind:       models.TransactionKindReversal,
+			Prefix:     fmt.Sprintf("Reversal of transaction [%v]", original.ID),
+			ReversalOf: &original.ID,
+		})
+	}
+	return legs
+}
+
+//CheckBalanced returns an error unless deltas of legs sum to zero
+func CheckBalanced(legs []EntryLeg) *models.CustomErr {
+	var sum models.Money
+	for _, leg := range legs {
+		sum += leg.Delta
+	}
+	if sum != 0 {
+		return models.InternalError(fmt.Errorf("journal entry is not balanced: legs sum to [%v]", sum))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestSQLiteLedgerConservesBalance(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -2000})
+	transfer, cErr := db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 3000, Fee: 150})
+	assert.Equal(t, cErr == nil, true)
+
+	_, cErr = db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 4000, Fee: 900})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+
+	var entry []models.Transaction
+	db.Db.Where("entry_id = ?", transfer.EntryID).Order("transaction_id").Find(&entry)
+	assert.Equal(t, len(entry), 4)
+	assert.Equal(t, entry[2].Kind, models.TransactionKindFee)
+	assert.Equal(t, entry[3].AccountID, models.SystemAccountFees)
+
+	_, cErr = db.ReverseTransaction(ctx, &models.ReverseRequest{TransactionID: entry[2].ID})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorReversalNotAllowedCode)
+	reversals, cErr := db.ReverseTransaction(ctx, &models.ReverseRequest{TransactionID: transfer.ID})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, len(reversals), 2)
+
+	systemAccounts, _ := db.GetSystemAccounts(ctx)
+	assert.Equal(t, systemAccounts, []models.SystemAccount{
+		{ID: models.SystemAccountCashIn, Name: "cash-in", Balance: -10000},
+		{ID: models.SystemAccountCashOut, Name: "cash-out", Balance: 2000},
+		{ID: models.SystemAccountFees, Name: "fees", Balance: 150},
+	})
+	acc1, _ := db.GetBalance(ctx, 1)
+	assert.Equal(t, acc1.Balance, models.Money(7850))
+
+	var sum models.Money
+	db.Db.Raw("SELECT SUM(balance) FROM accounts").Scan(&sum)
+	assert.Equal(t, sum, models.Money(0))
+}
+
+func TestSQLiteCaptureGoesToCashOut(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+	hold, _ := db.PlaceHold(ctx, &models.HoldRequest{AccountID: 1, Amount: 6000, OrderID: "order-1"})
+
+	tr, cErr := db.CaptureHold(ctx, &models.CaptureHoldRequest{HoldID: hold.ID, Amount: 2500})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, tr.Kind, models.TransactionKindCapture)
+	assert.Equal(t, tr.Remaining, models.Money(7500))
+
+	systemAccounts, _ := db.GetSystemAccounts(ctx)
+	assert.Equal(t, systemAccounts[1].Balance, models.Money(2500))
+}
+
+func TestSQLiteBalanceAsOf(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	before := time.Now()
+	deposit, _ := db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+	//transfer and fee legs of account 1 share the timestamp
+	transfer, _ := db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 3000, Fee: 100})
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -500})
+
+	balance, cErr := db.GetBalanceAsOf(ctx, 1, before)
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, balance.Balance, models.Money(0))
+	assert.Equal(t, balance.TransactionID == nil, true)
+
+	balance, _ = db.GetBalanceAsOf(ctx, 1, deposit.CreatedAt)
+	assert.Equal(t, balance.Balance, models.Money(10000))
+
+	balance, _ = db.GetBalanceAsOf(ctx, 1, transfer.CreatedAt)
+	assert.Equal(t, balance.Balance, models.Money(6900))
+	assert.Equal(t, *balance.TransactionID, transfer.ID+2)
+
+	balance, _ = db.GetBalanceAsOf(ctx, 1, time.Now())
+	assert.Equal(t, balance.Balance, models.Money(6400))
+}
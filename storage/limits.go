@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/dalconoid/balance-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+//GetSpendingLimits returns spending limits of account with id=id; an account without limits has all of them nil
+func (db *Database) GetSpendingLimits(ctx context.Context, id int) (_ *models.SpendingLimits, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "GetSpendingLimits")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	return findSpendingLimits(db.Db, id)
+}
+
+//SetSpendingLimits replaces spending limits of account; nil limits are removed
+func (db *Database) SetSpendingLimits(ctx context.Context, limits *models.SpendingLimits) (_ *models.SpendingLimits, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "SetSpendingLimits")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	tx := db.Db.Begin()
+
+	//limits reference the account, so it must exist
+	if _, err := lockAccount(tx, limits.AccountID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if result := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(limits); result.Error != nil {
+		tx.Rollback()
+		return nil, models.InternalError(result.Error)
+	}
+
+	tx.Commit()
+	return limits, nil
+}
+
+//checkSpendingLimits checks spending limits of account with id=id after an operation moving transfer out of it
+//(zero if it is not a transfer) was posted at now. The balance update of the operation locks the account row,
+//so concurrent operations of the account are checked one after another
+func checkSpendingLimits(tx *gorm.DB, id int, transfer models.Money, now time.Time) *models.CustomErr {
+	limits, err := findSpendingLimits(tx, id)
+	if err != nil {
+		return err
+	}
+	if limits.DailyDebit == nil && limits.HourlyTransfers == nil && limits.SingleTransfer == nil {
+		return nil
+	}
+
+	usage := &models.SpendingUsage{}
+	if limits.DailyDebit != nil {
+		//refunded amounts do not count
+		row := tx.Model(&models.Transaction{}).Select("COALESCE(SUM(ABS(delta) - reversed), 0)").
+			Where("account_id = ? AND delta < 0 AND kind IN ? AND created_at > ?",
+				id, models.SpendingKinds, now.Add(-models.DailyDebitWindow)).Row()
+		if err := row.Scan(&usage.DailyDebit); err != nil {
+			return models.InternalError(err)
+		}
+	}
+	if limits.HourlyTransfers != nil && transfer > 0 {
+		transfers := tx.Model(&models.Transaction{}).Where("account_id = ? AND kind = ? AND created_at > ?",
+			id, models.TransactionKindTransferOut, now.Add(-models.HourlyTransfersWindow)).Session(&gorm.Session{})
+		var count int64
+		if result := transfers.Count(&count); result.Error != nil {
+			return models.InternalError(result.Error)
+		}
+		usage.HourlyTransfers = int(count)
+
+		oldest := &models.Transaction{}
+		if result := transfers.Order("created_at").Limit(1).Find(oldest); result.Error != nil {
+			return models.InternalError(result.Error)
+		}
+		usage.OldestTransfer = oldest.CreatedAt
+	}
+	return CheckSpendingLimits(limits, usage, transfer, now)
+}
+
+func findSpendingLimits(tx *gorm.DB, id int) (*models.SpendingLimits, *models.CustomErr) {
+	limits := &models.SpendingLimits{}
+	result := tx.Where("account_id = ?", id).Limit(1).Find(limits)
+	if result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	limits.AccountID = id
+	return limits, nil
+}
+
+//CheckSpendingLimits returns an error if usage of an account, which includes the checked operation moving transfer
+//out of the account (zero if it is not a transfer), breaches one of limits
+func CheckSpendingLimits(limits *models.SpendingLimits, usage *models.SpendingUsage, transfer models.Money, now time.Time) *models.CustomErr {
+	if transfer > 0 && limits.SingleTransfer != nil && transfer > *limits.SingleTransfer {
+		return limitExceeded(&models.LimitExceededError{
+			AccountID: limits.AccountID,
+			Limit:     models.LimitSingleTransfer,
+			Value:     transfer.String(),
+			Max:       limits.SingleTransfer.String(),
+		})
+	}
+	if transfer > 0 && limits.HourlyTransfers != nil && usage.HourlyTransfers > *limits.HourlyTransfers {
+		//a zero limit blocks transfers, waiting does not help
+		var retryAfter time.Duration
+		if *limits.HourlyTransfers > 0 {
+			retryAfter = usage.OldestTransfer.Add(models.HourlyTransfersWindow).Sub(now)
+			if retryAfter < time.Second {
+				retryAfter = time.Second
+			}
+		}
+		return limitExceeded(&models.LimitExceededError{
+			AccountID:  limits.AccountID,
+			Limit:      models.LimitHourlyTransfers,
+			Value:      fmt.Sprint(usage.HourlyTransfers),
+			Max:        fmt.Sprint(*limits.HourlyTransfers),
+			RetryAfter: retryAfter,
+		})
+	}
+	if limits.DailyDebit != nil && usage.DailyDebit > *limits.DailyDebit {
+		return limitExceeded(&models.LimitExceededError{
+			AccountID: limits.AccountID,
+			Limit:     models.LimitDailyDebit,
+			Value:     usage.DailyDebit.String(),
+			Max:       limits.DailyDebit.String(),
+		})
+	}
+	return nil
+}
+
+//SpendingOf returns outgoing money of account with id=id in legs, see models.SpendingKinds
+func SpendingOf(legs []EntryLeg, id int) models.Money {
+	var spending models.Money
+	for _, leg := range legs {
+		if leg.AccountID != id || leg.Delta >= 0 {
+			continue
+		}
+		for _, kind := range models.SpendingKinds {
+			if leg.Kind == kind {
+				spending -= leg.Delta
+			}
+		}
+	}
+	return spending
+}
+
+func limitExceeded(err *models.LimitExceededError) *models.CustomErr {
+	return models.NewError(models.ErrorLimitExceededCode, err)
+}
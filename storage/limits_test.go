@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestSQLiteSpendingLimits(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 100000})
+
+	daily, single, hourly := models.Money(10000), models.Money(5000), 2
+	_, cErr := db.SetSpendingLimits(ctx, &models.SpendingLimits{AccountID: 1, DailyDebit: &daily, SingleTransfer: &single, HourlyTransfers: &hourly})
+	assert.Equal(t, cErr == nil, true)
+	limits, _ := db.GetSpendingLimits(ctx, 1)
+	assert.Equal(t, *limits.DailyDebit, daily)
+	assert.Equal(t, *limits.HourlyTransfers, hourly)
+
+	_, cErr = db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 5001})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorLimitExceededCode)
+	var limitErr *models.LimitExceededError
+	assert.Equal(t, errors.As(cErr.Err, &limitErr), true)
+	assert.Equal(t, limitErr.Limit, models.LimitSingleTransfer)
+
+	_, cErr = db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 3000, Fee: 100})
+	assert.Equal(t, cErr == nil, true)
+	_, cErr = db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 1000})
+	assert.Equal(t, cErr == nil, true)
+	_, cErr = db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 1000})
+	assert.Equal(t, errors.As(cErr.Err, &limitErr), true)
+	assert.Equal(t, limitErr.Limit, models.LimitHourlyTransfers)
+	assert.Equal(t, limitErr.RetryAfter > 0, true)
+
+	//the failed transfer was rolled back
+	account, _ := db.GetBalance(ctx, 1)
+	assert.Equal(t, account.Balance, models.Money(95900))
+
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -6000})
+	assert.Equal(t, errors.As(cErr.Err, &limitErr), true)
+	assert.Equal(t, limitErr.Limit, models.LimitDailyDebit)
+	assert.Equal(t, limitErr.Value, "101.00")
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -5900})
+	assert.Equal(t, cErr == nil, true)
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 1000})
+	assert.Equal(t, cErr == nil, true)
+
+	//deposits and other accounts are not limited
+	_, cErr = db.MakeBatchTransfer(ctx, &models.BatchTransferRequest{Transfers: []models.TransferRequest{
+		{ID1: 2, ID2: 3, Delta: 100},
+		{ID1: 1, ID2: 3, Delta: 100},
+	}})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorLimitExceededCode)
+	assert.Equal(t, errors.As(cErr.Err, &limitErr), true)
+
+	_, cErr = db.SetSpendingLimits(ctx, &models.SpendingLimits{AccountID: 1})
+	assert.Equal(t, cErr == nil, true)
+	_, cErr = db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 10000})
+	assert.Equal(t, cErr == nil, true)
+
+	//a zero limit blocks transfers for good
+	blocked := 0
+	db.SetSpendingLimits(ctx, &models.SpendingLimits{AccountID: 1, HourlyTransfers: &blocked})
+	_, cErr = db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 100})
+	assert.Equal(t, errors.As(cErr.Err, &limitErr), true)
+	assert.Equal(t, limitErr.Limit, models.LimitHourlyTransfers)
+	assert.Equal(t, limitErr.RetryAfter, time.Duration(0))
+
+	//limits of an account that does not exist are not set
+	_, cErr = db.SetSpendingLimits(ctx, &models.SpendingLimits{AccountID: 999999, HourlyTransfers: &hourly})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorNotFoundCode)
+	var accounts int64
+	db.Db.Model(&models.Account{}).Where("account_id = ?", 999999).Count(&accounts)
+	assert.Equal(t, accounts, int64(0))
+}
+
+func TestSQLiteSpendingLimitsCapture(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 100000})
+	daily := models.Money(10000)
+	db.SetSpendingLimits(ctx, &models.SpendingLimits{AccountID: 1, DailyDebit: &daily})
+
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -4000})
+	hold, cErr := db.PlaceHold(ctx, &models.HoldRequest{AccountID: 1, Amount: 8000, OrderID: "order-1"})
+	assert.Equal(t, cErr == nil, true)
+	_, cErr = db.CaptureHold(ctx, &models.CaptureHoldRequest{HoldID: hold.ID})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorLimitExceededCode)
+	var limitErr *models.LimitExceededError
+	assert.Equal(t, errors.As(cErr.Err, &limitErr), true)
+	assert.Equal(t, limitErr.Limit, models.LimitDailyDebit)
+	assert.Equal(t, limitErr.Value, "120.00")
+
+	//the failed capture was rolled back, the hold is still active
+	hold, _ = db.GetHold(ctx, hold.ID)
+	assert.Equal(t, hold.Status, models.HoldStatusActive)
+	account, _ := db.GetBalance(ctx, 1)
+	assert.Equal(t, account.Balance, models.Money(96000))
+	assert.Equal(t, account.Held, models.Money(8000))
+
+	_, cErr = db.CaptureHold(ctx, &models.CaptureHoldRequest{HoldID: hold.ID, Amount: 6000})
+	assert.Equal(t, cErr == nil, true)
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -1})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorLimitExceededCode)
+}
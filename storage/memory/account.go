@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage"
+)
+
+//FreezeAccount makes account reject debits or all movements; a frozen account can be frozen again with another scope
+func (s *Store) FreezeAccount(ctx context.Context, request *models.FreezeAccountRequest) (*models.Account, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scope := storage.FreezeScope(request)
+	account, ok := s.accounts[request.AccountID]
+	if !ok {
+		return nil, models.NotFoundError("account", request.AccountID)
+	}
+	if err := storage.CheckFreeze(account, scope); err != nil {
+		return nil, err
+	}
+	return s.changeStatus(account, models.AccountStatusChange{Status: models.AccountStatusFrozen, FreezeScope: scope, Reason: request.Reason}), nil
+}
+
+//UnfreezeAccount makes frozen account active again
+func (s *Store) UnfreezeAccount(ctx context.Context, request *models.UnfreezeAccountRequest) (*models.Account, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[request.AccountID]
+	if !ok {
+		return nil, models.NotFoundError("account", request.AccountID)
+	}
+	if err := storage.CheckUnfreeze(account); err != nil {
+		return nil, err
+	}
+	return s.changeStatus(account, models.AccountStatusChange{Status: models.AccountStatusActive, Reason: request.Reason}), nil
+}
+
+//CloseAccount closes account for good; the rest of the balance is swept to another account
+func (s *Store) CloseAccount(ctx context.Context, request *models.CloseAccountRequest) (*models.Account, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[request.AccountID]
+	if !ok {
+		return nil, models.NotFoundError("account", request.AccountID)
+	}
+	if err := storage.CheckClose(account, request); err != nil {
+		return nil, err
+	}
+	change := models.AccountStatusChange{Status: models.AccountStatusClosed, Reason: request.Reason}
+	if account.Balance != 0 {
+		transactions, err := s.postEntry(models.EntryKindSweep, time.Now(), storage.SweepLegs(account, request.SweepTo))
+		if err != nil {
+			return nil, err
+		}
+		change.SweepEntryID = &transactions[0].EntryID
+		//postEntry replaces changed accounts
+		account = s.accounts[request.AccountID]
+	}
+	return s.changeStatus(account, change), nil
+}
+
+//SetCreditLimit sets the credit limit of account; the limit cannot be lowered below what is already used
+func (s *Store) SetCreditLimit(ctx context.Context, request *models.CreditLimitRequest) (*models.Account, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[request.AccountID]
+	if !ok {
+		return nil, models.NotFoundError("account", request.AccountID)
+	}
+	if err := storage.CheckCreditLimit(account, request.CreditLimit); err != nil {
+		return nil, err
+	}
+	account.CreditLimit = request.CreditLimit
+
+	acc := *account
+	acc.Available = acc.Headroom()
+	return &acc, nil
+}
+
+//GetAccountStatusHistory returns status changes of account with id=id in the order they were made
+func (s *Store) GetAccountStatusHistory(ctx context.Context, id int) ([]models.AccountStatusChange, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	changes := make([]models.AccountStatusChange, 0)
+	for _, change := range s.statusChanges {
+		if change.AccountID == id {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+//changeStatus applies change to account, records it and returns a copy of the account. Must be called with s.mu held
+func (s *Store) changeStatus(account *models.Account, change models.AccountStatusChange) *models.Account {
+	change.ID = len(s.statusChanges) + 1
+	change.AccountID = account.ID
+	change.PreviousStatus = account.Status
+	change.CreatedAt = time.Now()
+	s.statusChanges = append(s.statusChanges, change)
+
+	account.Status = change.Status
+	account.FreezeScope = change.FreezeScope
+	acc := *account
+	acc.Available = acc.Headroom()
+	return &acc
+}
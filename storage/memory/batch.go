@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage"
+)
+
+//MakeBatchTransfer makes all transfers of request or none of them;
+//returns transactions of all legs of every transfer
+func (s *Store) MakeBatchTransfer(ctx context.Context, request *models.BatchTransferRequest) ([]models.Transaction, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	//balances are changed on copies of accounts until every transfer is checked
+	changed := make(map[int]*models.Account)
+	legs := make([][]storage.EntryLeg, 0, len(request.Transfers))
+	remaining := make([][]models.Money, 0, len(request.Transfers))
+	//spending limits count earlier transfers of the batch
+	pending := make([]storage.EntryLeg, 0, 2*len(request.Transfers))
+	for i := range request.Transfers {
+		transferLegs := storage.TransferLegs(&request.Transfers[i])
+		balances, err := s.applyLegs(changed, transferLegs)
+		if err != nil {
+			return nil, storage.BatchTransferFailed(i, err)
+		}
+		pending = append(pending, transferLegs...)
+		if err = s.checkSpendingLimits(request.Transfers[i].ID1, request.Transfers[i].Delta, pending, now); err != nil {
+			return nil, storage.BatchTransferFailed(i, err)
+		}
+		legs = append(legs, transferLegs)
+		remaining = append(remaining, balances)
+	}
+
+	s.saveAccounts(changed)
+	transactions := make([]models.Transaction, 0, 2*len(request.Transfers))
+	for i := range legs {
+		transactions = append(transactions, s.writeEntry(models.EntryKindTransfer, now, legs[i], remaining[i])...)
+	}
+
+	return transactions, nil
+}
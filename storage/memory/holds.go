@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage"
+)
+
+//GetHold returns hold with id=id
+func (s *Store) GetHold(ctx context.Context, id int) (*models.Hold, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hold, err := s.findHold(id)
+	if err != nil {
+		return nil, err
+	}
+	h := *hold
+	return &h, nil
+}
+
+//PlaceHold reserves funds on account; reserved funds are not available for debits until the hold is finished
+func (s *Store) PlaceHold(ctx context.Context, request *models.HoldRequest) (*models.Hold, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	account, ok := s.accounts[request.AccountID]
+	//a hold reserves funds for a debit, so it is placed only if the account accepts debits
+	if ok && !account.Accepts(-request.Amount) {
+		return nil, storage.StatusRejected(account)
+	}
+	if !ok || account.Headroom() < request.Amount {
+		return nil, storage.InsufficientFunds(request.AccountID, account, request.Amount)
+	}
+	account.Held += request.Amount
+
+	s.lastHoldID++
+	hold := &models.Hold{
+		ID:        s.lastHoldID,
+		AccountID: request.AccountID,
+		OrderID:   request.OrderID,
+		Amount:    request.Amount,
+		Status:    models.HoldStatusActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: request.ExpiresAt,
+	}
+	s.holds[hold.ID] = hold
+
+	h := *hold
+	return &h, nil
+}
+
+//CaptureHold charges the whole hold or a part of it; the rest of the hold is released
+func (s *Store) CaptureHold(ctx context.Context, request *models.CaptureHoldRequest) (*models.Transaction, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	hold, err := s.findHold(request.HoldID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Expired(now) {
+		s.finishHold(hold, models.HoldStatusExpired, 0, now)
+		return nil, holdNotActive(hold)
+	}
+	if hold.Status != models.HoldStatusActive {
+		return nil, holdNotActive(hold)
+	}
+
+	amount := request.Amount
+	if amount == 0 {
+		amount = hold.Amount
+	}
+	if amount > hold.Amount {
+		return nil, models.NewErrorf(models.ErrorHoldAmountExceededCode,
+			"capture amount [%v] exceeds hold [%v] amount [%v]", amount, hold.ID, hold.Amount)
+	}
+
+	//the captured amount leaves the account, the hold only reserved it
+	legs := storage.CaptureLegs(hold, amount)
+	if err = s.checkSpendingLimits(hold.AccountID, 0, legs, now); err != nil {
+		return nil, err
+	}
+
+	//the hold is finished first so that its amount is available for the capture entry
+	s.finishHold(hold, models.HoldStatusCaptured, amount, now)
+	transactions, err := s.postEntry(models.EntryKindCapture, now, legs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transactions[0], nil
+}
+
+//ReleaseHold cancels hold and makes reserved funds available again
+func (s *Store) ReleaseHold(ctx context.Context, id int) (*models.Hold, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	hold, err := s.findHold(id)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != models.HoldStatusActive {
+		return nil, holdNotActive(hold)
+	}
+
+	status := models.HoldStatusReleased
+	if hold.Expired(now) {
+		status = models.HoldStatusExpired
+	}
+	s.finishHold(hold, status, 0, now)
+
+	h := *hold
+	return &h, nil
+}
+
+//ReleaseExpiredHolds releases all active holds past their expiry and returns their number
+func (s *Store) ReleaseExpiredHolds(ctx context.Context) (int, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return 0, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	released := 0
+	for _, hold := range s.holds {
+		if hold.Expired(now) {
+			s.finishHold(hold, models.HoldStatusExpired, 0, now)
+			released++
+		}
+	}
+	return released, nil
+}
+
+//findHold must be called with s.mu held
+func (s *Store) findHold(id int) (*models.Hold, *models.CustomErr) {
+	hold, ok := s.holds[id]
+	if !ok {
+		return nil, models.NotFoundError("hold", id)
+	}
+	return hold, nil
+}
+
+//finishHold moves active hold to status recording captured amount and unreserves the hold amount;
+//captured amount is charged by the capture journal entry. Must be called with s.mu held
+func (s *Store) finishHold(hold *models.Hold, status string, captured models.Money, now time.Time) {
+	hold.Status = status
+	hold.Captured = captured
+	hold.UpdatedAt = now
+
+	s.accounts[hold.AccountID].Held -= hold.Amount
+}
+
+func holdNotActive(hold *models.Hold) *models.CustomErr {
+	return models.NewErrorf(models.ErrorHoldNotActiveCode, "hold [%v] is not active", hold.ID)
+}
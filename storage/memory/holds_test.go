@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestHoldCapture(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+
+	hold, cErr := s.PlaceHold(ctx, &models.HoldRequest{AccountID: 1, Amount: 6000, OrderID: "order-1"})
+	assert.Equal(t, cErr == nil, true)
+
+	_, cErr = s.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 5000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+
+	tr, cErr := s.CaptureHold(ctx, &models.CaptureHoldRequest{HoldID: hold.ID})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, tr.Remaining, models.Money(4000))
+
+	account, _ := s.GetBalance(ctx, 1)
+	assert.Equal(t, account.Available, models.Money(4000))
+}
+
+func TestHoldExpiry(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+
+	expiresAt := time.Now().Add(-time.Second)
+	hold, _ := s.PlaceHold(ctx, &models.HoldRequest{AccountID: 1, Amount: 6000, OrderID: "order-1", ExpiresAt: &expiresAt})
+
+	_, cErr := s.CaptureHold(ctx, &models.CaptureHoldRequest{HoldID: hold.ID})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorHoldNotActiveCode)
+
+	hold, _ = s.GetHold(ctx, hold.ID)
+	assert.Equal(t, hold.Status, models.HoldStatusExpired)
+	account, _ := s.GetBalance(ctx, 1)
+	assert.Equal(t, account.Available, models.Money(10000))
+}
+
+func TestHoldCaptureSpendingLimit(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 100000})
+	daily := models.Money(10000)
+	s.SetSpendingLimits(ctx, &models.SpendingLimits{AccountID: 1, DailyDebit: &daily})
+
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -4000})
+	hold, _ := s.PlaceHold(ctx, &models.HoldRequest{AccountID: 1, Amount: 8000, OrderID: "order-1"})
+	_, cErr := s.CaptureHold(ctx, &models.CaptureHoldRequest{HoldID: hold.ID})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorLimitExceededCode)
+
+	//the hold is still active
+	hold, _ = s.GetHold(ctx, hold.ID)
+	assert.Equal(t, hold.Status, models.HoldStatusActive)
+	account, _ := s.GetBalance(ctx, 1)
+	assert.Equal(t, account.Held, models.Money(8000))
+
+	_, cErr = s.CaptureHold(ctx, &models.CaptureHoldRequest{HoldID: hold.ID, Amount: 6000})
+	assert.Equal(t, cErr == nil, true)
+	_, cErr = s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -1})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorLimitExceededCode)
+}
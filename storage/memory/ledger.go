@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage"
+)
+
+//postEntry posts journal entry of kind with legs and returns its transactions; a failed entry leaves
+//the store untouched. Must be called with s.mu held
+func (s *Store) postEntry(kind string, now time.Time, legs []storage.EntryLeg) ([]models.Transaction, *models.CustomErr) {
+	changed := make(map[int]*models.Account)
+	remaining, err := s.applyLegs(changed, legs)
+	if err != nil {
+		return nil, err
+	}
+	s.saveAccounts(changed)
+	return s.writeEntry(kind, now, legs, remaining), nil
+}
+
+//postLimitedEntry is postEntry of an operation moving money out of account with id=id that is subject to its
+//spending limits; transfer is the transferred amount, zero if it is not a transfer. Must be called with s.mu held
+func (s *Store) postLimitedEntry(kind string, now time.Time, legs []storage.EntryLeg, id int, transfer models.Money) ([]models.Transaction, *models.CustomErr) {
+	changed := make(map[int]*models.Account)
+	remaining, err := s.applyLegs(changed, legs)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.checkSpendingLimits(id, transfer, legs, now); err != nil {
+		return nil, err
+	}
+	s.saveAccounts(changed)
+	return s.writeEntry(kind, now, legs, remaining), nil
+}
+
+//applyLegs changes balances of copies of accounts kept in changed and returns the balance after each leg.
+//Must be called with s.mu held
+func (s *Store) applyLegs(changed map[int]*models.Account, legs []storage.EntryLeg) ([]models.Money, *models.CustomErr) {
+	if err := storage.CheckBalanced(legs); err != nil {
+		return nil, err
+	}
+	remaining := make([]models.Money, 0, len(legs))
+	for _, leg := range legs {
+		account, err := s.applyDelta(changed, &leg)
+		if err != nil {
+			return nil, err
+		}
+		remaining = append(remaining, account.Balance)
+	}
+	return remaining, nil
+}
+
+//applyDelta changes balance of a copy of the account of leg kept in changed. Must be called with s.mu held
+func (s *Store) applyDelta(changed map[int]*models.Account, leg *storage.EntryLeg) (*models.Account, *models.CustomErr) {
+	id, delta := leg.AccountID, leg.Delta
+	account, ok := changed[id]
+	if !ok {
+		account = s.accounts[id]
+	}
+	if account != nil && !leg.IgnoreStatus && !account.Accepts(delta) {
+		return nil, storage.StatusRejected(account)
+	}
+	if !ok {
+		var err *models.CustomErr
+		if account, err = s.checkBalance(id, delta); err != nil {
+			return nil, err
+		}
+		changed[id] = account
+	} else if !models.IsSystemAccount(id) && account.Headroom()+delta < 0 {
+		return nil, storage.InsufficientFunds(id, account, -delta)
+	}
+	account.Balance += delta
+	return account, nil
+}
+
+//saveAccounts replaces accounts with their changed copies. Must be called with s.mu held
+func (s *Store) saveAccounts(changed map[int]*models.Account) {
+	for id, account := range changed {
+		s.accounts[id] = account
+	}
+}
+
+//writeEntry writes transactions of a new journal entry of kind and their outbox events. Must be called with s.mu held
+func (s *Store) writeEntry(kind string, now time.Time, legs []storage.EntryLeg, remaining []models.Money) []models.Transaction {
+	s.lastEntryID++
+	entry := &models.JournalEntry{ID: s.lastEntryID, Kind: kind, CreatedAt: now}
+	transactions := make([]models.Transaction, 0, len(legs))
+	for i := range legs {
+		transactions = append(transactions, s.writeTransaction(legs[i].Transaction(entry, remaining[i])))
+	}
+	for _, event := range storage.BalanceEvents(transactions, now) {
+		event.ID = len(s.outboxEvents) + 1
+		s.outboxEvents = append(s.outboxEvents, event)
+	}
+	return transactions
+}
@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage"
+)
+
+//GetSpendingLimits returns spending limits of account with id=id; an account without limits has all of them nil
+func (s *Store) GetSpendingLimits(ctx context.Context, id int) (*models.SpendingLimits, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	limits, ok := s.spendingLimits[id]
+	if !ok {
+		return &models.SpendingLimits{AccountID: id}, nil
+	}
+	l := *limits
+	return &l, nil
+}
+
+//SetSpendingLimits replaces spending limits of account; nil limits are removed
+func (s *Store) SetSpendingLimits(ctx context.Context, limits *models.SpendingLimits) (*models.SpendingLimits, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.accounts[limits.AccountID]; !ok {
+		return nil, models.NotFoundError("account", limits.AccountID)
+	}
+	l := *limits
+	s.spendingLimits[limits.AccountID] = &l
+	return limits, nil
+}
+
+//checkSpendingLimits checks spending limits of account with id=id for an operation moving transfer out of it
+//(zero if it is not a transfer); pending are the legs not yet written, those of the operation included.
+//Must be called with s.mu held
+func (s *Store) checkSpendingLimits(id int, transfer models.Money, pending []storage.EntryLeg, now time.Time) *models.CustomErr {
+	limits, ok := s.spendingLimits[id]
+	if !ok {
+		return nil
+	}
+
+	usage := &models.SpendingUsage{DailyDebit: storage.SpendingOf(pending, id), OldestTransfer: now}
+	for _, leg := range pending {
+		if leg.AccountID == id && leg.Kind == models.TransactionKindTransferOut {
+			usage.HourlyTransfers++
+		}
+	}
+	dailyFrom, hourlyFrom := now.Add(-models.DailyDebitWindow), now.Add(-models.HourlyTransfersWindow)
+	//transactions are stored in the order they were made
+	for i := len(s.transactions) - 1; i >= 0 && s.transactions[i].CreatedAt.After(dailyFrom); i-- {
+		t := &s.transactions[i]
+		if t.AccountID != id {
+			continue
+		}
+		leg := []storage.EntryLeg{{AccountID: id, Delta: t.Delta, Kind: t.Kind}}
+		if spending := storage.SpendingOf(leg, id); spending > 0 {
+			//refunded amounts do not count
+			usage.DailyDebit += spending - t.Reversed
+		}
+		if t.Kind == models.TransactionKindTransferOut && t.CreatedAt.After(hourlyFrom) {
+			usage.HourlyTransfers++
+			usage.OldestTransfer = t.CreatedAt
+		}
+	}
+	return storage.CheckSpendingLimits(limits, usage, transfer, now)
+}
@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage"
+)
+
+//ReverseTransaction posts a reversal entry compensating the whole or a part of the journal entry of a transaction;
+//all legs of the entry except fees are reversed together. Returns compensating transactions
+func (s *Store) ReverseTransaction(ctx context.Context, request *models.ReverseRequest) ([]models.Transaction, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	original, err := s.findTransaction(request.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := storage.ReversalAmount(original, request.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := make([]models.Transaction, 0)
+	for _, t := range s.transactions {
+		if t.EntryID == original.EntryID {
+			entry = append(entry, t)
+		}
+	}
+	legs := storage.ReversalLegs(entry, amount)
+	reversals, err := s.postEntry(models.EntryKindReversal, time.Now(), legs)
+	if err != nil {
+		return nil, err
+	}
+	for _, leg := range legs {
+		s.transactions[*leg.ReversalOf-1].Reversed += amount
+	}
+
+	return reversals, nil
+}
+
+//findTransaction returns stored transaction with id=id. Must be called with s.mu held
+func (s *Store) findTransaction(id int) (*models.Transaction, *models.CustomErr) {
+	if id < 1 || id > len(s.transactions) {
+		return nil, models.NotFoundError("transaction", id)
+	}
+	return &s.transactions[id-1], nil
+}
@@ -0,0 +1,169 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage"
+)
+
+//CreateSchedule creates a schedule of transfers; retry policy of request defaults to ScheduleRetryPolicy
+func (s *Store) CreateSchedule(ctx context.Context, request *models.ScheduleRequest) (*models.Schedule, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, err := storage.NewSchedule(request, s.ScheduleRetryPolicy, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	s.lastScheduleID++
+	schedule.ID = s.lastScheduleID
+	s.schedules[schedule.ID] = schedule
+
+	sch := *schedule
+	return &sch, nil
+}
+
+//GetSchedule returns schedule with id=id
+func (s *Store) GetSchedule(ctx context.Context, id int) (*models.Schedule, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	schedule, err := s.findSchedule(id)
+	if err != nil {
+		return nil, err
+	}
+	sch := *schedule
+	return &sch, nil
+}
+
+//GetSchedules returns schedules of transfers from account with id=id except deleted ones
+func (s *Store) GetSchedules(ctx context.Context, id int) ([]models.Schedule, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	schedules := make([]models.Schedule, 0)
+	for _, schedule := range s.schedules {
+		if schedule.FromID == id && schedule.Status != models.ScheduleStatusDeleted {
+			schedules = append(schedules, *schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+	return schedules, nil
+}
+
+//GetScheduleRuns returns runs of schedule with id=id in the order they were made
+func (s *Store) GetScheduleRuns(ctx context.Context, id int) ([]models.ScheduleRun, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := s.findSchedule(id); err != nil {
+		return nil, err
+	}
+	runs := make([]models.ScheduleRun, 0)
+	for _, run := range s.scheduleRuns {
+		if run.ScheduleID == id {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+//PauseSchedule stops runs of active schedule until it is resumed
+func (s *Store) PauseSchedule(ctx context.Context, id int) (*models.Schedule, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	return s.changeSchedule(id, storage.PauseSchedule)
+}
+
+//ResumeSchedule makes paused schedule active again
+func (s *Store) ResumeSchedule(ctx context.Context, id int) (*models.Schedule, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	return s.changeSchedule(id, storage.ResumeSchedule)
+}
+
+//DeleteSchedule stops runs of schedule for good; its runs are kept
+func (s *Store) DeleteSchedule(ctx context.Context, id int) (*models.Schedule, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	return s.changeSchedule(id, storage.DeleteSchedule)
+}
+
+//RunDueSchedules makes transfers of active schedules due by now; returns the number of runs made
+func (s *Store) RunDueSchedules(ctx context.Context) (int, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return 0, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	due := make([]*models.Schedule, 0)
+	for _, schedule := range s.schedules {
+		if schedule.Status == models.ScheduleStatusActive && !schedule.NextRunAt.After(now) {
+			due = append(due, schedule)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextRunAt.Before(due[j].NextRunAt) })
+
+	for _, schedule := range due {
+		request := storage.ScheduleTransfer(schedule)
+		var transaction *models.Transaction
+		transactions, err := s.postLimitedEntry(models.EntryKindTransfer, now, storage.TransferLegs(request), request.ID1, request.Delta)
+		if err == nil {
+			transaction = &transactions[0]
+		}
+		run := storage.FinishRun(schedule, transaction, err, now)
+		s.lastRunID++
+		run.ID = s.lastRunID
+		s.scheduleRuns = append(s.scheduleRuns, *run)
+	}
+	return len(due), nil
+}
+
+//changeSchedule lets transition check and change schedule with id=id
+func (s *Store) changeSchedule(id int, transition func(schedule *models.Schedule, now time.Time) *models.CustomErr) (*models.Schedule, *models.CustomErr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, err := s.findSchedule(id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	//the transition is checked on a copy so that a rejected one leaves the schedule untouched
+	sch := *schedule
+	if err = transition(&sch, now); err != nil {
+		return nil, err
+	}
+	sch.UpdatedAt = now
+	*schedule = sch
+	return &sch, nil
+}
+
+//findSchedule must be called with s.mu held
+func (s *Store) findSchedule(id int) (*models.Schedule, *models.CustomErr) {
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return nil, models.NotFoundError("schedule", id)
+	}
+	return schedule, nil
+}
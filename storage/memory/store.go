@@ -0,0 +1,352 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage"
+)
+
+var _ storage.Store = (*Store)(nil)
+
+//Store is a concurrency-safe in-memory implementation of storage.Store
+type Store struct {
+	PaginationNum int
+	//IdempotencyRetention is how long idempotency keys are kept; zero keeps them forever
+	IdempotencyRetention time.Duration
+	//ScheduleRetryPolicy is the retry policy of schedules created without one
+	ScheduleRetryPolicy models.RetryPolicy
+
+	mu              sync.RWMutex
+	accounts        map[int]*models.Account
+	transactions    []models.Transaction
+	lastTrID        int
+	lastEntryID     int
+	idempotencyKeys map[string]*models.IdempotencyKey
+	holds           map[int]*models.Hold
+	lastHoldID      int
+	statusChanges   []models.AccountStatusChange
+	spendingLimits  map[int]*models.SpendingLimits
+	schedules       map[int]*models.Schedule
+	lastScheduleID  int
+	scheduleRuns    []models.ScheduleRun
+	lastRunID       int
+	outboxEvents    []models.OutboxEvent
+	fannedOut       int
+	webhooks        map[int]*models.Webhook
+	lastWebhookID   int
+	deliveries      map[int]*models.WebhookDelivery
+	lastDeliveryID  int
+}
+
+//New creates an in-memory store holding only system accounts
+func New(paginationNum int) *Store {
+	s := &Store{
+		PaginationNum:   paginationNum,
+		accounts:        make(map[int]*models.Account),
+		transactions:    make([]models.Transaction, 0),
+		idempotencyKeys: make(map[string]*models.IdempotencyKey),
+		holds:           make(map[int]*models.Hold),
+		spendingLimits:  make(map[int]*models.SpendingLimits),
+		schedules:       make(map[int]*models.Schedule),
+		scheduleRuns:    make([]models.ScheduleRun, 0),
+		outboxEvents:    make([]models.OutboxEvent, 0),
+		webhooks:        make(map[int]*models.Webhook),
+		deliveries:      make(map[int]*models.WebhookDelivery),
+	}
+	for id := range models.SystemAccountNames {
+		s.accounts[id] = &models.Account{ID: id, Status: models.AccountStatusActive}
+	}
+	return s
+}
+
+//GetBalance returns account with id=id
+func (s *Store) GetBalance(ctx context.Context, id int) (*models.Account, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	account, ok := s.accounts[id]
+	if !ok {
+		return &models.Account{ID: id, Balance: 0, Status: models.AccountStatusActive}, nil
+	}
+	acc := *account
+	acc.Available = acc.Headroom()
+	return &acc, nil
+}
+
+//GetBalanceAsOf returns balance of account with id=id at time asOf, i.e. remaining of its last transaction until asOf
+func (s *Store) GetBalanceAsOf(ctx context.Context, id int, asOf time.Time) (*models.BalanceAsOf, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	balance := &models.BalanceAsOf{ID: id, AsOf: asOf}
+	//transactions are stored in the order they were made
+	for i := len(s.transactions) - 1; i >= 0; i-- {
+		t := &s.transactions[i]
+		if t.AccountID == id && !t.CreatedAt.After(asOf) {
+			transactionID := t.ID
+			balance.Balance = t.Remaining
+			balance.TransactionID = &transactionID
+			break
+		}
+	}
+	return balance, nil
+}
+
+//GetSystemAccounts returns system accounts with their balances
+func (s *Store) GetSystemAccounts(ctx context.Context) ([]models.SystemAccount, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	systemAccounts := make([]models.SystemAccount, 0, len(models.SystemAccountNames))
+	for id := -1; id >= -len(models.SystemAccountNames); id-- {
+		systemAccounts = append(systemAccounts, models.SystemAccount{
+			ID:      id,
+			Name:    models.SystemAccountNames[id],
+			Balance: s.accounts[id].Balance,
+		})
+	}
+	return systemAccounts, nil
+}
+
+//GetTransactionHistory returns transaction history sorted by time/sum asc/desc with ties broken by transaction id;
+//supports page and cursor pagination
+func (s *Store) GetTransactionHistory(ctx context.Context, request *models.HistoryRequest) (*models.HistoryPage, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := make([]models.Transaction, 0, 0)
+	for _, t := range s.transactions {
+		if t.AccountID == request.AccountID && request.Matches(&t) {
+			history = append(history, t)
+		}
+	}
+
+	//before reports whether a precedes b in the requested order
+	before := func(a, b *models.Transaction) bool {
+		if request.Sort == models.SortBySumString && a.Delta != b.Delta {
+			return (a.Delta < b.Delta) != (request.Order == models.OrderDescendingString)
+		}
+		if request.Sort != models.SortBySumString && !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt) != (request.Order == models.OrderDescendingString)
+		}
+		if a.ID == b.ID {
+			return false
+		}
+		return (a.ID < b.ID) != (request.Order == models.OrderDescendingString)
+	}
+	sort.Slice(history, func(i, j int) bool { return before(&history[i], &history[j]) })
+
+	if request.Page != 0 {
+		if request.Page > 0 {
+			from := (request.Page - 1) * s.PaginationNum
+			if from >= len(history) {
+				return &models.HistoryPage{Transactions: make([]models.Transaction, 0, 0)}, nil
+			}
+			to := from + s.PaginationNum
+			if to > len(history) {
+				to = len(history)
+			}
+			history = history[from:to]
+		}
+		return &models.HistoryPage{Transactions: history}, nil
+	}
+
+	limit := storage.HistoryLimit(request, s.PaginationNum)
+	cursor := request.Cursor
+	if cursor == nil {
+		more := len(history) > limit
+		if more {
+			history = history[:limit]
+		}
+		return storage.HistoryPage(request, history, more), nil
+	}
+
+	position := &models.Transaction{ID: cursor.ID, CreatedAt: cursor.CreatedAt, Delta: cursor.Delta}
+	if cursor.Backward {
+		//transactions preceding the cursor
+		end := sort.Search(len(history), func(i int) bool { return !before(&history[i], position) })
+		from := end - limit
+		if from < 0 {
+			from = 0
+		}
+		return storage.HistoryPage(request, history[from:end], from > 0), nil
+	}
+	from := sort.Search(len(history), func(i int) bool { return before(position, &history[i]) })
+	to := from + limit
+	if to > len(history) {
+		to = len(history)
+	}
+	return storage.HistoryPage(request, history[from:to], to < len(history)), nil
+}
+
+//StreamTransactionHistory calls fn for every transaction of filtered and sorted history; pagination of request
+//is ignored. Stops at the first error of fn
+func (s *Store) StreamTransactionHistory(ctx context.Context, request *models.HistoryRequest, fn func(*models.Transaction) error) *models.CustomErr {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return cErr
+	}
+	all := *request
+	all.Page = -1
+	//the history is a copy, so fn is called without holding s.mu
+	history, cErr := s.GetTransactionHistory(ctx, &all)
+	if cErr != nil {
+		return cErr
+	}
+	for i := range history.Transactions {
+		if cErr := storage.Canceled(ctx); cErr != nil {
+			return cErr
+		}
+		if err := fn(&history.Transactions[i]); err != nil {
+			return models.InternalError(err)
+		}
+	}
+	return nil
+}
+
+//UpdateBalance changes account balance; the change is balanced against cash-in or cash-out system account
+func (s *Store) UpdateBalance(ctx context.Context, request *models.ChangeBalanceRequest) (*models.Transaction, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := storage.RequestHash(models.IdempotencyScopeChangeBalance, request)
+	if stored := s.findIdempotencyKey(request.IdempotencyKey); stored != nil {
+		return storage.ReplayIdempotencyKey(stored, hash)
+	}
+
+	kind, legs := storage.ChangeBalanceLegs(request)
+	var transactions []models.Transaction
+	var err *models.CustomErr
+	if request.Delta < 0 {
+		transactions, err = s.postLimitedEntry(kind, time.Now(), legs, request.ID, 0)
+	} else {
+		transactions, err = s.postEntry(kind, time.Now(), legs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = s.saveIdempotencyKey(request.IdempotencyKey, hash, &transactions[0]); err != nil {
+		return nil, err
+	}
+
+	return &transactions[0], nil
+}
+
+//MakeTransfer makes transfer between accounts
+func (s *Store) MakeTransfer(ctx context.Context, request *models.TransferRequest) (*models.Transaction, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := storage.RequestHash(models.IdempotencyScopeTransfer, request)
+	if stored := s.findIdempotencyKey(request.IdempotencyKey); stored != nil {
+		return storage.ReplayIdempotencyKey(stored, hash)
+	}
+
+	transactions, err := s.postLimitedEntry(models.EntryKindTransfer, time.Now(), storage.TransferLegs(request), request.ID1, request.Delta)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.saveIdempotencyKey(request.IdempotencyKey, hash, &transactions[0]); err != nil {
+		return nil, err
+	}
+
+	return &transactions[0], nil
+}
+
+//checkBalance returns a copy of account with id=id that can be changed by delta; missing accounts are created only
+//for non-negative delta, accounts may go down to their credit limit and system accounts may go negative. Must be called with s.mu held
+func (s *Store) checkBalance(id int, delta models.Money) (*models.Account, *models.CustomErr) {
+	account, ok := s.accounts[id]
+	if !ok {
+		if delta < 0 {
+			return nil, storage.InsufficientFunds(id, nil, -delta)
+		}
+		return &models.Account{ID: id, Balance: 0, Status: models.AccountStatusActive}, nil
+	}
+	if !models.IsSystemAccount(id) && account.Headroom()+delta < 0 {
+		return nil, storage.InsufficientFunds(id, account, -delta)
+	}
+	acc := *account
+	return &acc, nil
+}
+
+//writeTransaction assigns an id to transaction and appends it to history; transaction with id=N is stored
+//at s.transactions[N-1]. Must be called with s.mu held
+func (s *Store) writeTransaction(transaction models.Transaction) models.Transaction {
+	s.lastTrID++
+	transaction.ID = s.lastTrID
+	s.transactions = append(s.transactions, transaction)
+	return transaction
+}
+
+//DeleteExpiredIdempotencyKeys deletes idempotency keys kept longer than IdempotencyRetention and returns their number
+func (s *Store) DeleteExpiredIdempotencyKeys(ctx context.Context) (int, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return 0, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for key, stored := range s.idempotencyKeys {
+		if s.idempotencyKeyExpired(stored) {
+			delete(s.idempotencyKeys, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+//findIdempotencyKey returns unexpired stored key or nil. Must be called with s.mu held
+func (s *Store) findIdempotencyKey(key string) *models.IdempotencyKey {
+	stored, ok := s.idempotencyKeys[key]
+	if key == "" || !ok || s.idempotencyKeyExpired(stored) {
+		return nil
+	}
+	return stored
+}
+
+//idempotencyKeyExpired returns true if stored is kept longer than IdempotencyRetention
+func (s *Store) idempotencyKeyExpired(stored *models.IdempotencyKey) bool {
+	return s.IdempotencyRetention > 0 && stored.CreatedAt.Before(time.Now().Add(-s.IdempotencyRetention))
+}
+
+//saveIdempotencyKey stores transaction as the response for key. Must be called with s.mu held
+func (s *Store) saveIdempotencyKey(key string, hash string, transaction *models.Transaction) *models.CustomErr {
+	if key == "" {
+		return nil
+	}
+	response, err := json.Marshal(transaction)
+	if err != nil {
+		return models.InternalError(err)
+	}
+	s.idempotencyKeys[key] = &models.IdempotencyKey{
+		Key:         key,
+		RequestHash: hash,
+		Response:    string(response),
+		CreatedAt:   time.Now(),
+	}
+	return nil
+}
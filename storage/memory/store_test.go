@@ -0,0 +1,389 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestUpdateBalanceCreatesAccount(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+
+	_, cErr := s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -1000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+
+	tr, cErr := s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10050})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, tr.Remaining, models.Money(10050))
+
+	_, cErr = s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -10051})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+
+	account, _ := s.GetBalance(ctx, 1)
+	assert.Equal(t, account.Balance, models.Money(10050))
+}
+
+func TestMakeTransfer(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 5000})
+
+	_, cErr := s.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 6000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+
+	tr, cErr := s.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 2000})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, tr.AccountID, 1)
+	assert.Equal(t, tr.Delta, models.Money(-2000))
+
+	acc1, _ := s.GetBalance(ctx, 1)
+	acc2, _ := s.GetBalance(ctx, 2)
+	assert.Equal(t, acc1.Balance, models.Money(3000))
+	assert.Equal(t, acc2.Balance, models.Money(2000))
+}
+
+func TestGetTransactionHistorySortingAndPagination(t *testing.T) {
+	ctx := context.Background()
+	s := New(2)
+	for _, d := range []models.Money{3000, 1000, 2000} {
+		s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: d})
+	}
+
+	history, _ := s.GetTransactionHistory(ctx, &models.HistoryRequest{AccountID: 1, Sort: models.SortBySumString, Order: models.OrderDescendingString, Page: -1})
+	assert.Equal(t, len(history.Transactions), 3)
+	assert.Equal(t, history.Transactions[0].Delta, models.Money(3000))
+	assert.Equal(t, history.Transactions[2].Delta, models.Money(1000))
+
+	history, _ = s.GetTransactionHistory(ctx, &models.HistoryRequest{AccountID: 1, Sort: models.SortByTimeString, Order: models.OrderAscendingString, Page: 2})
+	assert.Equal(t, len(history.Transactions), 1)
+	assert.Equal(t, history.Transactions[0].Delta, models.Money(2000))
+
+	history, _ = s.GetTransactionHistory(ctx, &models.HistoryRequest{AccountID: 1, Sort: models.SortByTimeString, Order: models.OrderAscendingString, Page: 3})
+	assert.Equal(t, len(history.Transactions), 0)
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 100})
+		}()
+	}
+	wg.Wait()
+
+	account, _ := s.GetBalance(ctx, 1)
+	assert.Equal(t, account.Balance, models.Money(10000))
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 5000})
+
+	request := &models.TransferRequest{ID1: 1, ID2: 2, Delta: 1000, IdempotencyKey: "key-1"}
+	tr1, cErr := s.MakeTransfer(ctx, request)
+	assert.Equal(t, cErr == nil, true)
+	tr2, cErr := s.MakeTransfer(ctx, request)
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, tr2.ID, tr1.ID)
+
+	account, _ := s.GetBalance(ctx, 1)
+	assert.Equal(t, account.Balance, models.Money(4000))
+
+	_, cErr = s.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 3, Delta: 1000, IdempotencyKey: "key-1"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorIdempotencyConflictCode)
+
+	//an expired key is not replayed and is deleted by the sweep
+	s.IdempotencyRetention = time.Millisecond
+	time.Sleep(5 * time.Millisecond)
+	tr3, cErr := s.MakeTransfer(ctx, request)
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, tr3.ID != tr1.ID, true)
+	time.Sleep(5 * time.Millisecond)
+	deleted, _ := s.DeleteExpiredIdempotencyKeys(ctx)
+	assert.Equal(t, deleted, 1)
+}
+
+func TestReverseTransfer(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+	transfer, _ := s.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 4000})
+
+	reversals, cErr := s.ReverseTransaction(ctx, &models.ReverseRequest{TransactionID: transfer.ID, Amount: 1000})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, len(reversals), 2)
+
+	_, cErr = s.ReverseTransaction(ctx, &models.ReverseRequest{TransactionID: transfer.ID, Amount: 3001})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorReversalNotAllowedCode)
+
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 2, Delta: -3000})
+	_, cErr = s.ReverseTransaction(ctx, &models.ReverseRequest{TransactionID: transfer.ID})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+
+	acc1, _ := s.GetBalance(ctx, 1)
+	assert.Equal(t, acc1.Balance, models.Money(7000))
+	history, _ := s.GetTransactionHistory(ctx, &models.HistoryRequest{AccountID: 1, Sort: models.SortByTimeString, Order: models.OrderAscendingString, Page: -1})
+	assert.Equal(t, history.Transactions[1].Reversed, models.Money(1000))
+}
+
+func TestMakeBatchTransfer(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+
+	_, cErr := s.MakeBatchTransfer(ctx, &models.BatchTransferRequest{Transfers: []models.TransferRequest{
+		{ID1: 1, ID2: 2, Delta: 6000},
+		{ID1: 2, ID2: 3, Delta: 6001},
+	}})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+	acc2, _ := s.GetBalance(ctx, 2)
+	assert.Equal(t, acc2.Balance, models.Money(0))
+
+	transactions, cErr := s.MakeBatchTransfer(ctx, &models.BatchTransferRequest{Transfers: []models.TransferRequest{
+		{ID1: 1, ID2: 2, Delta: 6000},
+		{ID1: 2, ID2: 3, Delta: 6000},
+	}})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, len(transactions), 4)
+	assert.Equal(t, transactions[0].EntryID, transactions[1].EntryID)
+	assert.Equal(t, transactions[1].EntryID == transactions[2].EntryID, false)
+	acc3, _ := s.GetBalance(ctx, 3)
+	assert.Equal(t, acc3.Balance, models.Money(6000))
+}
+
+func TestLedgerConservesBalance(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -2000})
+	_, cErr := s.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 3000, Fee: 150})
+	assert.Equal(t, cErr == nil, true)
+	_, cErr = s.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 4000, Fee: 900})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+
+	systemAccounts, _ := s.GetSystemAccounts(ctx)
+	assert.Equal(t, systemAccounts, []models.SystemAccount{
+		{ID: models.SystemAccountCashIn, Name: "cash-in", Balance: -10000},
+		{ID: models.SystemAccountCashOut, Name: "cash-out", Balance: 2000},
+		{ID: models.SystemAccountFees, Name: "fees", Balance: 150},
+	})
+
+	var sum models.Money
+	for _, account := range s.accounts {
+		sum += account.Balance
+	}
+	assert.Equal(t, sum, models.Money(0))
+}
+
+func TestGetTransactionHistoryCursor(t *testing.T) {
+	ctx := context.Background()
+	s := New(2)
+	for _, d := range []models.Money{3000, 1000, 1000, 2000, 1000} {
+		s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: d})
+	}
+
+	request := &models.HistoryRequest{AccountID: 1, Sort: models.SortBySumString, Order: models.OrderDescendingString}
+	page, _ := s.GetTransactionHistory(ctx, request)
+	assert.Equal(t, len(page.Transactions), 2)
+	assert.Equal(t, page.Transactions[1].Delta, models.Money(2000))
+
+	request.Cursor, _ = models.ParseHistoryCursor(page.NextCursor)
+	page, _ = s.GetTransactionHistory(ctx, request)
+	assert.Equal(t, len(page.Transactions), 2)
+	assert.Equal(t, page.Transactions[0].ID > page.Transactions[1].ID, true)
+
+	request.Cursor, _ = models.ParseHistoryCursor(page.NextCursor)
+	last, _ := s.GetTransactionHistory(ctx, request)
+	assert.Equal(t, len(last.Transactions), 1)
+	assert.Equal(t, last.NextCursor, "")
+
+	request.Cursor, _ = models.ParseHistoryCursor(last.PrevCursor)
+	prev, _ := s.GetTransactionHistory(ctx, request)
+	assert.Equal(t, prev.Transactions, page.Transactions)
+}
+
+func TestGetTransactionHistoryFilters(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 500000})
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -150000})
+	s.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 120000})
+	s.MakeTransfer(ctx, &models.TransferRequest{ID1: 2, ID2: 1, Delta: 20000})
+
+	min := models.Money(100000)
+	request := &models.HistoryRequest{AccountID: 1, Sort: models.SortByTimeString, Order: models.OrderAscendingString, Page: -1,
+		Direction: models.DirectionDebit, MinAmount: &min}
+	history, _ := s.GetTransactionHistory(ctx, request)
+	assert.Equal(t, len(history.Transactions), 2)
+
+	request = &models.HistoryRequest{AccountID: 1, Sort: models.SortByTimeString, Order: models.OrderAscendingString, Page: -1,
+		Kinds: []string{models.TransactionKindTransferIn}}
+	history, _ = s.GetTransactionHistory(ctx, request)
+	assert.Equal(t, len(history.Transactions), 1)
+	assert.Equal(t, history.Transactions[0].Delta, models.Money(20000))
+}
+
+func TestGetBalanceAsOf(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+	before := time.Now()
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+	transfer, _ := s.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 3000, Fee: 100})
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -500})
+
+	balance, _ := s.GetBalanceAsOf(ctx, 1, before)
+	assert.Equal(t, balance.Balance, models.Money(0))
+	balance, _ = s.GetBalanceAsOf(ctx, 1, transfer.CreatedAt)
+	assert.Equal(t, balance.Balance, models.Money(6900))
+	balance, _ = s.GetBalanceAsOf(ctx, 1, time.Now())
+	assert.Equal(t, balance.Balance, models.Money(6400))
+}
+
+func TestAccountLifecycle(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+
+	_, cErr := s.FreezeAccount(ctx, &models.FreezeAccountRequest{AccountID: 1, Reason: "fraud check"})
+	assert.Equal(t, cErr == nil, true)
+	_, cErr = s.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 1000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountFrozenCode)
+	_, cErr = s.PlaceHold(ctx, &models.HoldRequest{AccountID: 1, Amount: 1000, OrderID: "order-1"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountFrozenCode)
+	_, cErr = s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 1000})
+	assert.Equal(t, cErr == nil, true)
+
+	_, cErr = s.CloseAccount(ctx, &models.CloseAccountRequest{AccountID: 1, Reason: "customer request"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountStatusCode)
+	account, cErr := s.CloseAccount(ctx, &models.CloseAccountRequest{AccountID: 1, SweepTo: 2, Reason: "customer request"})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, account.Status, models.AccountStatusClosed)
+	assert.Equal(t, account.Balance, models.Money(0))
+	target, _ := s.GetBalance(ctx, 2)
+	assert.Equal(t, target.Balance, models.Money(11000))
+
+	_, cErr = s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 1000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountClosedCode)
+	_, cErr = s.UnfreezeAccount(ctx, &models.UnfreezeAccountRequest{AccountID: 1, Reason: "reopen"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorAccountClosedCode)
+
+	changes, _ := s.GetAccountStatusHistory(ctx, 1)
+	assert.Equal(t, len(changes), 2)
+	assert.Equal(t, changes[1].PreviousStatus, models.AccountStatusFrozen)
+	assert.Equal(t, changes[1].SweepEntryID != nil, true)
+
+	_, cErr = s.FreezeAccount(ctx, &models.FreezeAccountRequest{AccountID: 999999, Reason: "typo"})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorNotFoundCode)
+	_, ok := s.accounts[999999]
+	assert.Equal(t, ok, false)
+}
+
+func TestCreditLimit(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 1000})
+
+	_, cErr := s.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 5000})
+	assert.Equal(t, cErr.Err.Error(), "insuffisient funds on account [1]: [50.00] requested, headroom is [10.00]")
+
+	s.SetCreditLimit(ctx, &models.CreditLimitRequest{AccountID: 1, CreditLimit: 10000})
+	tr, cErr := s.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 5000, Fee: 100})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, tr.Remaining, models.Money(-4000))
+	_, cErr = s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -6000})
+	assert.Equal(t, cErr.Err.Error(), "insuffisient funds on account [1]: [60.00] requested, headroom is [59.00]")
+
+	_, cErr = s.SetCreditLimit(ctx, &models.CreditLimitRequest{AccountID: 1, CreditLimit: 4000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorCreditLimitCode)
+	account, cErr := s.SetCreditLimit(ctx, &models.CreditLimitRequest{AccountID: 1, CreditLimit: 4100})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, account.Available, models.Money(0))
+}
+
+func TestSpendingLimits(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 100000})
+
+	daily, hourly := models.Money(10000), 2
+	s.SetSpendingLimits(ctx, &models.SpendingLimits{AccountID: 1, DailyDebit: &daily, HourlyTransfers: &hourly})
+
+	_, cErr := s.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 6000})
+	assert.Equal(t, cErr == nil, true)
+	_, cErr = s.MakeBatchTransfer(ctx, &models.BatchTransferRequest{Transfers: []models.TransferRequest{
+		{ID1: 1, ID2: 2, Delta: 1000},
+		{ID1: 1, ID2: 3, Delta: 1000},
+	}})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorLimitExceededCode)
+	_, cErr = s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -4001})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorLimitExceededCode)
+
+	//refunded amounts do not count
+	s.ReverseTransaction(ctx, &models.ReverseRequest{TransactionID: 3, Amount: 1000})
+	_, cErr = s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -5000})
+	assert.Equal(t, cErr == nil, true)
+
+	account, _ := s.GetBalance(ctx, 1)
+	assert.Equal(t, account.Balance, models.Money(90000))
+
+	_, cErr = s.SetSpendingLimits(ctx, &models.SpendingLimits{AccountID: 999999, DailyDebit: &daily})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorNotFoundCode)
+}
+
+func TestScheduleRuns(t *testing.T) {
+	ctx := context.Background()
+	s := New(10)
+	s.ScheduleRetryPolicy = models.RetryPolicy{MaxRetries: 1, Interval: time.Hour}
+
+	runAt := time.Now().Add(-time.Minute)
+	schedule, cErr := s.CreateSchedule(ctx, &models.ScheduleRequest{FromID: 1, ToID: 2, Amount: 3000, RunAt: &runAt})
+	assert.Equal(t, cErr == nil, true)
+	runs, _ := s.RunDueSchedules(ctx)
+	assert.Equal(t, runs, 1)
+
+	schedule, _ = s.GetSchedule(ctx, schedule.ID)
+	assert.Equal(t, schedule.Attempt, 1)
+	assert.Equal(t, schedule.NextRunAt.After(time.Now()), true)
+	runs, _ = s.RunDueSchedules(ctx)
+	assert.Equal(t, runs, 0)
+
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 5000})
+	s.schedules[schedule.ID].NextRunAt = runAt
+	runs, _ = s.RunDueSchedules(ctx)
+	assert.Equal(t, runs, 1)
+
+	history, _ := s.GetScheduleRuns(ctx, schedule.ID)
+	assert.Equal(t, len(history), 2)
+	assert.Equal(t, history[0].Status, models.ScheduleRunFailed)
+	assert.Equal(t, history[1].Status, models.ScheduleRunSucceeded)
+	schedule, _ = s.GetSchedule(ctx, schedule.ID)
+	assert.Equal(t, schedule.Status, models.ScheduleStatusCompleted)
+	account, _ := s.GetBalance(ctx, 1)
+	assert.Equal(t, account.Balance, models.Money(2000))
+
+	_, cErr = s.DeleteSchedule(ctx, schedule.ID)
+	assert.Equal(t, cErr == nil, true)
+	_, cErr = s.DeleteSchedule(ctx, schedule.ID)
+	assert.Equal(t, cErr.ErrorCode, models.ErrorScheduleStatusCode)
+}
+
+func TestCanceled(t *testing.T) {
+	s := New(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, cErr := s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 5000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorCanceledCode)
+	account, _ := s.GetBalance(context.Background(), 1)
+	assert.Equal(t, account.Balance, models.Money(0))
+}
@@ -0,0 +1,215 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage"
+)
+
+//CreateWebhook registers a webhook
+func (s *Store) CreateWebhook(ctx context.Context, request *models.WebhookRequest) (*models.Webhook, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook := storage.NewWebhook(request, time.Now())
+	s.lastWebhookID++
+	webhook.ID = s.lastWebhookID
+	s.webhooks[webhook.ID] = webhook
+
+	w := *webhook
+	return &w, nil
+}
+
+//GetWebhooks returns all webhooks in the order they were registered
+func (s *Store) GetWebhooks(ctx context.Context) ([]models.Webhook, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sortedWebhooks(), nil
+}
+
+//DeleteWebhook deletes webhook with id=id together with its deliveries
+func (s *Store) DeleteWebhook(ctx context.Context, id int) (*models.Webhook, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook, err := s.findWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.webhooks, id)
+	for deliveryID, delivery := range s.deliveries {
+		if delivery.WebhookID == id {
+			delete(s.deliveries, deliveryID)
+		}
+	}
+	return webhook, nil
+}
+
+//GetWebhookDeliveries returns deliveries of webhook with id=id in the order they were made
+func (s *Store) GetWebhookDeliveries(ctx context.Context, id int) ([]models.WebhookDelivery, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := s.findWebhook(id); err != nil {
+		return nil, err
+	}
+	deliveries := make([]models.WebhookDelivery, 0)
+	for _, delivery := range s.deliveries {
+		if delivery.WebhookID == id {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries, nil
+}
+
+//RedeliverWebhookDelivery makes delivery with id=id pending again with no attempts made, whatever its status
+func (s *Store) RedeliverWebhookDelivery(ctx context.Context, id int) (*models.WebhookDelivery, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delivery, ok := s.deliveries[id]
+	if !ok {
+		return nil, models.NotFoundError("webhook delivery", id)
+	}
+	now := time.Now()
+	delivery.Status = models.DeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.Version++
+	delivery.UpdatedAt = now
+
+	d := *delivery
+	return &d, nil
+}
+
+//ClaimWebhookDeliveries fans new outbox events out to webhooks and claims up to limit pending deliveries due
+//by now for an attempt. A claimed delivery is not due again until lease passes
+func (s *Store) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.DeliveryTask, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	webhooks := s.sortedWebhooks()
+	for ; s.fannedOut < len(s.outboxEvents); s.fannedOut++ {
+		for _, delivery := range storage.NewDeliveries(webhooks, &s.outboxEvents[s.fannedOut], now) {
+			s.lastDeliveryID++
+			delivery.ID = s.lastDeliveryID
+			d := delivery
+			s.deliveries[d.ID] = &d
+		}
+	}
+
+	due := make([]*models.WebhookDelivery, 0)
+	for _, delivery := range s.deliveries {
+		if delivery.Status == models.DeliveryStatusPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	tasks := make([]models.DeliveryTask, 0, len(due))
+	for _, delivery := range due {
+		delivery.Version++
+		delivery.NextAttemptAt = now.Add(lease)
+		tasks = append(tasks, models.DeliveryTask{
+			Delivery: *delivery,
+			Webhook:  *s.webhooks[delivery.WebhookID],
+			Event:    s.outboxEvents[delivery.EventID-1],
+		})
+	}
+	return tasks, nil
+}
+
+//FinishWebhookDelivery saves the result of an attempt of claimed delivery; the result is dropped if the delivery
+//was redelivered or claimed again meanwhile
+func (s *Store) FinishWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) *models.CustomErr {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return cErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.deliveries[delivery.ID]
+	if !ok || stored.Version != delivery.Version {
+		return nil
+	}
+	*stored = *delivery
+	return nil
+}
+
+//GetOutboxEvents returns up to limit outbox events after position after in the order of ids
+func (s *Store) GetOutboxEvents(ctx context.Context, after models.OutboxPosition, limit int) ([]models.OutboxEvent, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return nil, cErr
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	//event ids are positions in the outbox starting from 1
+	from := after.EventID
+	if from < 0 {
+		from = 0
+	}
+	events := make([]models.OutboxEvent, 0)
+	for i := from; i < len(s.outboxEvents) && len(events) < limit; i++ {
+		events = append(events, s.outboxEvents[i])
+	}
+	return events, nil
+}
+
+//LastOutboxPosition returns the position of the last outbox event; zero if there are none
+func (s *Store) LastOutboxPosition(ctx context.Context) (models.OutboxPosition, *models.CustomErr) {
+	if cErr := storage.Canceled(ctx); cErr != nil {
+		return models.OutboxPosition{}, cErr
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return models.OutboxPosition{EventID: len(s.outboxEvents)}, nil
+}
+
+//sortedWebhooks must be called with s.mu held
+func (s *Store) sortedWebhooks() []models.Webhook {
+	webhooks := make([]models.Webhook, 0, len(s.webhooks))
+	for _, webhook := range s.webhooks {
+		webhooks = append(webhooks, *webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks
+}
+
+//findWebhook must be called with s.mu held
+func (s *Store) findWebhook(id int) (*models.Webhook, *models.CustomErr) {
+	webhook, ok := s.webhooks[id]
+	if !ok {
+		return nil, models.NotFoundError("webhook", id)
+	}
+	w := *webhook
+	return &w, nil
+}
@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"embed"
+	"fmt"
+	"github.com/dalconoid/balance-service/models"
+	"gorm.io/gorm"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//migrationFiles are migrations/<driver>/<version>_<name>.up.sql and .down.sql
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+//schemaMigrationsTable keeps versions of applied migrations; %s is the timestamp type of the driver
+const schemaMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at %s NOT NULL
+)`
+
+//Migration is a versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+//MigrationStatus is a migration with the time it was applied; AppliedAt is nil for pending migrations
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+//SchemaMigration - schema_migrations row
+type SchemaMigration struct {
+	Version   int `gorm:"primaryKey"`
+	Name      string
+	AppliedAt time.Time
+}
+
+//Migrations returns embedded migrations of driver sorted by version
+func Migrations(driver string) ([]Migration, error) {
+	if driver == "" {
+		driver = models.DriverPostgres
+	}
+	dir := path.Join("migrations", driver)
+	files, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("Migrations: unsupported driver [%s]", driver)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		name := file.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("Migrations: unexpected file [%s]", name)
+		}
+		parts := strings.SplitN(strings.TrimSuffix(name, "."+direction+".sql"), "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 || version <= 0 {
+			return nil, fmt.Errorf("Migrations: file [%s] is not named <version>_<name>.%s.sql", name, direction)
+		}
+		data, err := migrationFiles.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = migration
+		}
+		if direction == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("Migrations: migration [%v] must have both up and down files", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+//MigrateUp applies all pending migrations, each in its own database transaction; returns applied migrations
+func (db *Database) MigrateUp() ([]Migration, error) {
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]Migration, 0)
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			continue
+		}
+		migration := status.Migration
+		err = db.runMigration(migration.Up, func(tx *gorm.DB) error {
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("MigrateUp: migration [%v] %s: %v", migration.Version, migration.Name, err)
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+//MigrateDown reverts the latest applied migration; returns nil if there is nothing to revert
+func (db *Database) MigrateDown() (*Migration, error) {
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if statuses[i].AppliedAt == nil {
+			continue
+		}
+		migration := statuses[i].Migration
+		err = db.runMigration(migration.Down, func(tx *gorm.DB) error {
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("MigrateDown: migration [%v] %s: %v", migration.Version, migration.Name, err)
+		}
+		return &migration, nil
+	}
+	return nil, nil
+}
+
+//runMigration executes script and record in one database transaction. SQLite cannot drop columns, so its
+//migrations rebuild tables; foreign keys are off meanwhile as dropping a parent table would cascade to children,
+//and are checked before commit
+func (db *Database) runMigration(script string, record func(tx *gorm.DB) error) error {
+	sqlite := db.Driver == models.DriverSQLite
+	if sqlite {
+		//the pragma is a no-op inside a transaction; the single connection keeps it for the migration
+		if err := db.Db.Exec("PRAGMA foreign_keys = OFF").Error; err != nil {
+			return err
+		}
+		defer db.Db.Exec("PRAGMA foreign_keys = ON")
+	}
+	return db.Db.Transaction(func(tx *gorm.DB) error {
+		if hasStatements(script) {
+			if err := tx.Exec(script).Error; err != nil {
+				return err
+			}
+		}
+		if sqlite {
+			rows, err := tx.Raw("PRAGMA foreign_key_check").Rows()
+			if err != nil {
+				return err
+			}
+			violated := rows.Next()
+			rows.Close()
+			if violated {
+				return fmt.Errorf("foreign key constraints are violated")
+			}
+		}
+		return record(tx)
+	})
+}
+
+//hasStatements reports whether script has anything but comments; a script of comments explains a no-op migration
+func hasStatements(script string) bool {
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+	return false
+}
+
+//MigrationStatus returns all migrations of the driver with their application time
+func (db *Database) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := Migrations(db.Driver)
+	if err != nil {
+		return nil, err
+	}
+	//SQLite driver parses only columns declared as DATETIME, DATE or TIMESTAMP into time.Time
+	timestampType := "TIMESTAMP WITH TIME ZONE"
+	if db.Driver == models.DriverSQLite {
+		timestampType = "DATETIME"
+	}
+	if err = db.Db.Exec(fmt.Sprintf(schemaMigrationsTable, timestampType)).Error; err != nil {
+		return nil, err
+	}
+	applied := make([]SchemaMigration, 0)
+	if err = db.Db.Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[int]time.Time)
+	for _, migration := range applied {
+		appliedAt[migration.Version] = migration.AppliedAt
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Migration: migration}
+		if t, ok := appliedAt[migration.Version]; ok {
+			status.AppliedAt = &t
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+//CheckSchema returns an error unless all migrations are applied
+func (db *Database) CheckSchema() error {
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			return fmt.Errorf("CheckSchema: migration [%v] %s is not applied, run [migrate up]",
+				status.Version, status.Name)
+		}
+	}
+	return nil
+}
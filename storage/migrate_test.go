@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestMigrations(t *testing.T) {
+	for _, driver := range []string{models.DriverPostgres, models.DriverSQLite} {
+		migrations, err := Migrations(driver)
+		assert.Equal(t, err, nil)
+		for i, migration := range migrations {
+			assert.Equal(t, migration.Version, i+1)
+		}
+	}
+	_, err := Migrations(models.DriverMemory)
+	assert.Equal(t, err != nil, true)
+}
+
+func TestSQLiteMigrateUpDown(t *testing.T) {
+	ctx := context.Background()
+	db := &Database{Driver: models.DriverSQLite, ConnString: filepath.Join(t.TempDir(), "balance.db")}
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	migrations, _ := Migrations(db.Driver)
+	assert.Equal(t, db.CheckSchema() != nil, true)
+
+	applied, err := db.MigrateUp()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(applied), len(migrations))
+	assert.Equal(t, db.CheckSchema(), nil)
+	applied, _ = db.MigrateUp()
+	assert.Equal(t, len(applied), 0)
+
+	reverted, err := db.MigrateDown()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, reverted.Version, migrations[len(migrations)-1].Version)
+	assert.Equal(t, db.CheckSchema() != nil, true)
+	statuses, _ := db.MigrationStatus()
+	assert.Equal(t, statuses[len(statuses)-1].AppliedAt == nil, true)
+
+	for reverted != nil {
+		reverted, err = db.MigrateDown()
+		assert.Equal(t, err, nil)
+	}
+	applied, err = db.MigrateUp()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(applied), len(migrations))
+	_, cErr := db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 100})
+	assert.Equal(t, cErr == nil, true)
+}
+
+//sqliteBaselineSchema is the schema the SQLite backend created before migrations
+const sqliteBaselineSchema = `
+CREATE TABLE accounts (
+    account_id INTEGER PRIMARY KEY,
+    balance NUMERIC(18, 2) NOT NULL CONSTRAINT non_negative_balance CHECK (ROUND(balance, 2) >= 0)
+);
+
+CREATE TABLE transactions (
+    transaction_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts ON DELETE CASCADE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    delta NUMERIC(18, 2) NOT NULL,
+    remaining NUMERIC(18, 2) NOT NULL,
+    message TEXT NOT NULL
+);`
+
+//writeBaselineRows writes accounts and transactions the way the service did before migrations: two deposits,
+//a transfer and a withdrawal
+func writeBaselineRows(t *testing.T, db *Database) {
+	if err := db.Db.Exec("INSERT INTO accounts (account_id, balance) VALUES (1, 70.5), (2, 40)").Error; err != nil {
+		t.Fatal(err)
+	}
+	transferredAt := time.Now().Add(-time.Hour)
+	transactions := []struct {
+		accountID        int
+		createdAt        time.Time
+		delta, remaining float64
+		message          string
+	}{
+		{1, time.Now().Add(-3 * time.Hour), 100.5, 100.5, "Account [1]: balance changed by [100.50], [100.50] remaining"},
+		{2, time.Now().Add(-2 * time.Hour), 20, 20, "Account [2]: balance changed by [20.00], [20.00] remaining"},
+		{1, transferredAt, -30, 70.5,
+			"Transfer from account [1] to account [2]: balance changed by [-30.00], [70.50] remaining"},
+		{2, transferredAt, 30, 50, "Transfer from account [1] to account [2]: balance changed by [30.00], [50.00] remaining"},
+		{2, time.Now(), -10, 40, "Account [2]: balance changed by [-10.00], [40.00] remaining"},
+	}
+	for _, tr := range transactions {
+		err := db.Db.Exec("INSERT INTO transactions (account_id, created_at, delta, remaining, message) VALUES (?, ?, ?, ?, ?)",
+			tr.accountID, tr.createdAt, tr.delta, tr.remaining, tr.message).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+//checkBaselineUpgrade migrates a database with writeBaselineRows up, checks the journal built for them and
+//migrates it down again
+func checkBaselineUpgrade(t *testing.T, db *Database) {
+	ctx := context.Background()
+	migrations, _ := Migrations(db.Driver)
+	applied, err := db.MigrateUp()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(applied), len(migrations))
+	assert.Equal(t, db.CheckSchema(), nil)
+
+	var entries []models.JournalEntry
+	db.Db.Order("entry_id").Find(&entries)
+	kinds := make([]string, 0)
+	for _, entry := range entries {
+		kinds = append(kinds, entry.Kind)
+	}
+	assert.Equal(t, kinds, []string{models.EntryKindDeposit, models.EntryKindDeposit, models.EntryKindTransfer,
+		models.EntryKindWithdrawal})
+	var unbalanced int64
+	db.Db.Raw("SELECT COUNT(*) FROM (SELECT entry_id FROM transactions GROUP BY entry_id " +
+		"HAVING ROUND(SUM(delta), 2) <> 0) unbalanced").Scan(&unbalanced)
+	assert.Equal(t, unbalanced, int64(0))
+
+	var legs []models.Transaction
+	db.Db.Where("account_id = ?", 2).Order("transaction_id").Find(&legs)
+	assert.Equal(t, len(legs), 3)
+	assert.Equal(t, legs[1].Kind, models.TransactionKindTransferIn)
+	assert.Equal(t, legs[1].EntryID, entries[2].ID)
+	legs = nil
+	db.Db.Where("account_id = ?", models.SystemAccountCashIn).Order("transaction_id").Find(&legs)
+	assert.Equal(t, len(legs), 2)
+	assert.Equal(t, legs[0].Message, "Deposit to account [1]: balance changed by [-100.50], [-100.50] remaining")
+	assert.Equal(t, legs[1].Remaining, models.Money(-12050))
+
+	systemAccounts, _ := db.GetSystemAccounts(ctx)
+	assert.Equal(t, systemAccounts, []models.SystemAccount{
+		{ID: models.SystemAccountCashIn, Name: "cash-in", Balance: -12050},
+		{ID: models.SystemAccountCashOut, Name: "cash-out", Balance: 1000},
+		{ID: models.SystemAccountFees, Name: "fees", Balance: 0},
+	})
+
+	transfer, cErr := db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 1000})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, transfer.EntryID > entries[len(entries)-1].ID, true)
+	assert.Equal(t, transfer.Remaining, models.Money(6050))
+	_, cErr = db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 3, Delta: 500})
+	assert.Equal(t, cErr == nil, true)
+	var sum models.Money
+	db.Db.Raw("SELECT SUM(balance) FROM accounts").Scan(&sum)
+	assert.Equal(t, sum, models.Money(0))
+
+	reverted, err := db.MigrateDown()
+	for reverted != nil {
+		reverted, err = db.MigrateDown()
+	}
+	assert.Equal(t, err, nil)
+}
+
+func TestSQLiteMigrateBaseline(t *testing.T) {
+	db := &Database{Driver: models.DriverSQLite, ConnString: filepath.Join(t.TempDir(), "balance.db")}
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Db.Exec(sqliteBaselineSchema).Error; err != nil {
+		t.Fatal(err)
+	}
+	writeBaselineRows(t, db)
+	checkBaselineUpgrade(t, db)
+}
+
+//TestPostgresMigrateBaseline needs an empty database in TEST_POSTGRES_URL; it is dropped back to empty afterwards
+func TestPostgresMigrateBaseline(t *testing.T) {
+	connString := os.Getenv("TEST_POSTGRES_URL")
+	if connString == "" {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+	db := &Database{Driver: models.DriverPostgres, ConnString: connString}
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	schema, err := ioutil.ReadFile(filepath.Join("testdata", "balance_tables.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Db.Exec(string(schema)).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Db.Exec("DROP TABLE IF EXISTS schema_migrations, transactions, accounts")
+
+	writeBaselineRows(t, db)
+	checkBaselineUpgrade(t, db)
+}
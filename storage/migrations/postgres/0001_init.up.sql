@@ -0,0 +1,14 @@
+-- the schema of balance_tables.sql the service started with; databases created from it are adopted as they are
+CREATE TABLE IF NOT EXISTS accounts (
+    account_id INT PRIMARY KEY,
+    balance NUMERIC(18, 2) CONSTRAINT non_negative_balance CHECK (balance >= 0) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    transaction_id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    account_id INT REFERENCES accounts ON DELETE CASCADE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    delta NUMERIC(18, 2) NOT NULL,
+    remaining NUMERIC(18, 2) NOT NULL,
+    message TEXT NOT NULL
+);
@@ -0,0 +1 @@
+-- amounts stay NUMERIC(18, 2): float amounts are rounded the same way by the columns of 0001_init
@@ -0,0 +1,3 @@
+-- amounts are exact minor units (cents); columns already of this type are not rewritten
+ALTER TABLE accounts ALTER COLUMN balance TYPE NUMERIC(18, 2);
+ALTER TABLE transactions ALTER COLUMN delta TYPE NUMERIC(18, 2), ALTER COLUMN remaining TYPE NUMERIC(18, 2);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS holds;
+
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS non_negative_available;
+ALTER TABLE accounts DROP COLUMN IF EXISTS held;
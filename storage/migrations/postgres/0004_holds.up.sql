@@ -0,0 +1,19 @@
+-- held money is reserved by active holds and cannot be spent
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS held NUMERIC(18, 2) DEFAULT 0 NOT NULL
+    CONSTRAINT non_negative_held CHECK (held >= 0);
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS non_negative_available;
+ALTER TABLE accounts ADD CONSTRAINT non_negative_available CHECK (balance - held >= 0);
+
+CREATE TABLE IF NOT EXISTS holds (
+    hold_id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    account_id INT REFERENCES accounts ON DELETE CASCADE NOT NULL,
+    order_id VARCHAR(255) NOT NULL,
+    amount NUMERIC(18, 2) CONSTRAINT positive_hold_amount CHECK (amount > 0) NOT NULL,
+    captured NUMERIC(18, 2) DEFAULT 0 NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS holds_active_expires_at_idx ON holds (expires_at) WHERE status = 'active';
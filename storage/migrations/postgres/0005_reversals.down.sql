@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS transactions_reversal_of_idx;
+
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS reversed_within_delta;
+ALTER TABLE transactions DROP COLUMN IF EXISTS reversed;
+ALTER TABLE transactions DROP COLUMN IF EXISTS reversal_of;
@@ -0,0 +1,7 @@
+-- reversed is the part of a transaction compensated by reversals so far
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reversal_of INT REFERENCES transactions;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reversed NUMERIC(18, 2) DEFAULT 0 NOT NULL;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS reversed_within_delta;
+ALTER TABLE transactions ADD CONSTRAINT reversed_within_delta CHECK (reversed <= ABS(delta));
+
+CREATE INDEX IF NOT EXISTS transactions_reversal_of_idx ON transactions (reversal_of);
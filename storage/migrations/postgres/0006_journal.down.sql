@@ -0,0 +1,12 @@
+-- the legs of system accounts are deleted with them
+DELETE FROM accounts WHERE account_id < 0;
+
+DROP INDEX IF EXISTS transactions_entry_id_idx;
+ALTER TABLE transactions DROP COLUMN IF EXISTS kind;
+ALTER TABLE transactions DROP COLUMN IF EXISTS entry_id;
+DROP TABLE IF EXISTS journal_entries;
+
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS non_negative_available;
+ALTER TABLE accounts ADD CONSTRAINT non_negative_available CHECK (balance - held >= 0);
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS non_negative_balance;
+ALTER TABLE accounts ADD CONSTRAINT non_negative_balance CHECK (balance >= 0);
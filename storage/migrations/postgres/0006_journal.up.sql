@@ -0,0 +1,73 @@
+-- system accounts have negative ids and may have negative balance
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS non_negative_balance;
+ALTER TABLE accounts ADD CONSTRAINT non_negative_balance CHECK (balance >= 0 OR account_id < 0);
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS non_negative_available;
+ALTER TABLE accounts ADD CONSTRAINT non_negative_available CHECK (balance - held >= 0 OR account_id < 0);
+
+-- cash-in, cash-out, fees
+INSERT INTO accounts (account_id, balance) VALUES (-1, 0), (-2, 0), (-3, 0) ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    entry_id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    kind VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS entry_id INT REFERENCES journal_entries;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS kind VARCHAR(16);
+
+-- transactions written before the journal become entries. Both legs of a transfer were written at the same time
+-- with the same message prefix and opposite deltas; identical transfers of the same moment pair up once
+CREATE TEMPORARY TABLE transfer_legs ON COMMIT DROP AS
+SELECT o.transaction_id AS out_id, MIN(i.transaction_id) AS in_id
+FROM transactions o
+JOIN transactions i ON i.created_at = o.created_at AND i.delta = -o.delta
+    AND split_part(i.message, ':', 1) = split_part(o.message, ':', 1)
+WHERE o.entry_id IS NULL AND i.entry_id IS NULL AND o.delta < 0 AND o.message LIKE 'Transfer from account [%'
+GROUP BY o.transaction_id;
+
+DELETE FROM transfer_legs l WHERE EXISTS (SELECT 1 FROM transfer_legs f WHERE f.in_id = l.in_id AND f.out_id < l.out_id);
+
+-- the other transactions are deposits and withdrawals; an entry takes the id of its first transaction
+CREATE TEMPORARY TABLE entry_legs ON COMMIT DROP AS
+SELECT transaction_id, transaction_id AS entry_id, CASE WHEN delta < 0 THEN 'withdrawal' ELSE 'deposit' END AS kind
+FROM transactions
+WHERE entry_id IS NULL;
+
+UPDATE entry_legs e SET kind = 'transfer-out' FROM transfer_legs l WHERE e.transaction_id = l.out_id;
+UPDATE entry_legs e SET entry_id = l.out_id, kind = 'transfer-in' FROM transfer_legs l WHERE e.transaction_id = l.in_id;
+
+INSERT INTO journal_entries (entry_id, kind, created_at) OVERRIDING SYSTEM VALUE
+SELECT e.entry_id, CASE e.kind WHEN 'transfer-out' THEN 'transfer' ELSE e.kind END,
+    COALESCE(t.created_at, CURRENT_TIMESTAMP)
+FROM entry_legs e
+JOIN transactions t ON t.transaction_id = e.transaction_id
+WHERE e.entry_id = e.transaction_id;
+
+SELECT setval(pg_get_serial_sequence('journal_entries', 'entry_id'), COALESCE(MAX(entry_id), 0) + 1, false)
+FROM journal_entries;
+
+UPDATE transactions t SET entry_id = e.entry_id, kind = e.kind FROM entry_legs e WHERE t.transaction_id = e.transaction_id;
+
+-- deposits come from cash-in and withdrawals go to cash-out
+INSERT INTO transactions (account_id, entry_id, kind, created_at, delta, remaining, message)
+SELECT system_id, entry_id, kind, created_at, delta, remaining,
+    format('%s account [%s]: balance changed by [%s], [%s] remaining',
+        CASE kind WHEN 'deposit' THEN 'Deposit to' ELSE 'Withdrawal from' END, account_id, delta, remaining)
+FROM (
+    SELECT t.transaction_id, CASE t.kind WHEN 'deposit' THEN -1 ELSE -2 END AS system_id, t.account_id,
+        t.entry_id, t.kind, t.created_at, -t.delta AS delta,
+        SUM(-t.delta) OVER (PARTITION BY t.kind ORDER BY t.transaction_id) AS remaining
+    FROM transactions t
+    JOIN entry_legs e ON e.transaction_id = t.transaction_id
+    WHERE t.kind IN ('deposit', 'withdrawal')
+) legs
+ORDER BY transaction_id;
+
+UPDATE accounts a SET balance = s.balance
+FROM (SELECT account_id, SUM(delta) AS balance FROM transactions WHERE account_id < 0 GROUP BY account_id) s
+WHERE a.account_id = s.account_id;
+
+ALTER TABLE transactions ALTER COLUMN entry_id SET NOT NULL, ALTER COLUMN kind SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS transactions_entry_id_idx ON transactions (entry_id);
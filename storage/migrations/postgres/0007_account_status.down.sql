@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS account_status_changes;
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS freeze_scope;
+ALTER TABLE accounts DROP COLUMN IF EXISTS status;
@@ -0,0 +1,17 @@
+-- frozen accounts reject debits or all movements depending on freeze_scope; closed accounts reject all movements
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status VARCHAR(16) DEFAULT 'active' NOT NULL
+    CONSTRAINT valid_account_status CHECK (status IN ('active', 'frozen', 'closed'));
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS freeze_scope VARCHAR(16) DEFAULT '' NOT NULL;
+
+CREATE TABLE IF NOT EXISTS account_status_changes (
+    status_change_id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    account_id INT REFERENCES accounts ON DELETE CASCADE NOT NULL,
+    previous_status VARCHAR(16) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    freeze_scope VARCHAR(16) DEFAULT '' NOT NULL,
+    reason TEXT NOT NULL,
+    sweep_entry_id INT REFERENCES journal_entries,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS account_status_changes_account_id_idx ON account_status_changes (account_id);
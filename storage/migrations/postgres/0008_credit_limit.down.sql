@@ -0,0 +1,7 @@
+-- fails while any account uses its credit line
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS non_negative_available;
+ALTER TABLE accounts ADD CONSTRAINT non_negative_available CHECK (balance - held >= 0 OR account_id < 0);
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS non_negative_balance;
+ALTER TABLE accounts ADD CONSTRAINT non_negative_balance CHECK (balance >= 0 OR account_id < 0);
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS credit_limit;
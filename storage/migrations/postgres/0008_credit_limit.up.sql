@@ -0,0 +1,8 @@
+-- an account may go down to -credit_limit; accounts without a limit stay non-negative
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS credit_limit NUMERIC(18, 2) DEFAULT 0 NOT NULL
+    CONSTRAINT non_negative_credit_limit CHECK (credit_limit >= 0);
+
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS non_negative_balance;
+ALTER TABLE accounts ADD CONSTRAINT non_negative_balance CHECK (balance + credit_limit >= 0 OR account_id < 0);
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS non_negative_available;
+ALTER TABLE accounts ADD CONSTRAINT non_negative_available CHECK (balance - held + credit_limit >= 0 OR account_id < 0);
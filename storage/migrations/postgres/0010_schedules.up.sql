@@ -0,0 +1,37 @@
+-- accounts of a schedule are not referenced: like transfers, a schedule may name accounts that do not exist yet
+CREATE TABLE IF NOT EXISTS schedules (
+    schedule_id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    from_id INT NOT NULL,
+    to_id INT NOT NULL,
+    amount NUMERIC(18, 2) NOT NULL CONSTRAINT positive_amount CHECK (amount > 0),
+    fee NUMERIC(18, 2) DEFAULT 0 NOT NULL CONSTRAINT non_negative_fee CHECK (fee >= 0),
+    cron VARCHAR(255) DEFAULT '' NOT NULL,
+    status VARCHAR(16) NOT NULL
+        CONSTRAINT valid_schedule_status CHECK (status IN ('active', 'paused', 'completed', 'deleted')),
+    max_retries INT DEFAULT 0 NOT NULL,
+    retry_interval INT DEFAULT 0 NOT NULL,
+    attempt INT DEFAULT 0 NOT NULL,
+    occurrence_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    next_run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    version INT DEFAULT 0 NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS schedules_status_next_run_at_idx ON schedules (status, next_run_at);
+CREATE INDEX IF NOT EXISTS schedules_from_id_idx ON schedules (from_id);
+
+-- an attempt of an occurrence is recorded once
+CREATE TABLE IF NOT EXISTS schedule_runs (
+    run_id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    schedule_id INT REFERENCES schedules ON DELETE CASCADE NOT NULL,
+    occurrence_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    attempt INT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    transaction_id INT REFERENCES transactions,
+    error_code INT,
+    error TEXT DEFAULT '' NOT NULL,
+    retry_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT unique_schedule_run UNIQUE (schedule_id, occurrence_at, attempt)
+);
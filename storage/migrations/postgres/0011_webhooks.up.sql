@@ -0,0 +1,43 @@
+-- events are written in the database transaction of the balance change and fanned out to webhooks afterwards.
+-- Transactions commit out of the order of event ids, so events are tailed in the order of txid, see GetOutboxEvents
+CREATE TABLE IF NOT EXISTS outbox_events (
+    event_id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    txid BIGINT DEFAULT txid_current() NOT NULL,
+    type VARCHAR(64) NOT NULL,
+    account_id INT NOT NULL,
+    transaction_id INT REFERENCES transactions NOT NULL,
+    payload TEXT NOT NULL,
+    dispatched BOOLEAN DEFAULT FALSE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS outbox_events_undispatched_idx ON outbox_events (event_id) WHERE NOT dispatched;
+CREATE INDEX IF NOT EXISTS outbox_events_txid_event_id_idx ON outbox_events (txid, event_id);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    webhook_id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    account_id INT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- an event is delivered to a webhook once, however many dispatchers fan it out
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    delivery_id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    webhook_id INT REFERENCES webhooks ON DELETE CASCADE NOT NULL,
+    event_id INT REFERENCES outbox_events NOT NULL,
+    status VARCHAR(16) NOT NULL
+        CONSTRAINT valid_delivery_status CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts INT DEFAULT 0 NOT NULL,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status_code INT,
+    error TEXT DEFAULT '' NOT NULL,
+    version INT DEFAULT 0 NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT unique_webhook_event UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_status_next_attempt_at_idx ON webhook_deliveries (status, next_attempt_at);
@@ -0,0 +1,15 @@
+-- the schema the SQLite backend started with; databases created by it are adopted as they are.
+-- SQLite stores NUMERIC values with a fractional part as REAL, so they are rounded in the checks
+CREATE TABLE IF NOT EXISTS accounts (
+    account_id INTEGER PRIMARY KEY,
+    balance NUMERIC(18, 2) NOT NULL CONSTRAINT non_negative_balance CHECK (ROUND(balance, 2) >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    transaction_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts ON DELETE CASCADE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    delta NUMERIC(18, 2) NOT NULL,
+    remaining NUMERIC(18, 2) NOT NULL,
+    message TEXT NOT NULL
+);
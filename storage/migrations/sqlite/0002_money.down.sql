@@ -0,0 +1 @@
+-- rounded amounts stay as they are
@@ -0,0 +1,3 @@
+-- amounts are exact minor units (cents); float amounts written before are rounded to them
+UPDATE accounts SET balance = ROUND(balance, 2);
+UPDATE transactions SET delta = ROUND(delta, 2), remaining = ROUND(remaining, 2);
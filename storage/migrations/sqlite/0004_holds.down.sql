@@ -0,0 +1,11 @@
+-- SQLite cannot drop columns, so accounts is rebuilt without held
+DROP TABLE IF EXISTS holds;
+
+CREATE TABLE accounts_without_held (
+    account_id INTEGER PRIMARY KEY,
+    balance NUMERIC(18, 2) NOT NULL CONSTRAINT non_negative_balance CHECK (ROUND(balance, 2) >= 0)
+);
+
+INSERT INTO accounts_without_held (account_id, balance) SELECT account_id, balance FROM accounts;
+DROP TABLE accounts;
+ALTER TABLE accounts_without_held RENAME TO accounts;
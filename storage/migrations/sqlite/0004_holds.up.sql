@@ -0,0 +1,26 @@
+-- held money is reserved by active holds and cannot be spent. SQLite cannot add table constraints,
+-- so accounts is rebuilt
+CREATE TABLE accounts_with_held (
+    account_id INTEGER PRIMARY KEY,
+    balance NUMERIC(18, 2) NOT NULL CONSTRAINT non_negative_balance CHECK (ROUND(balance, 2) >= 0),
+    held NUMERIC(18, 2) NOT NULL DEFAULT 0 CONSTRAINT non_negative_held CHECK (ROUND(held, 2) >= 0),
+    CONSTRAINT non_negative_available CHECK (ROUND(balance - held, 2) >= 0)
+);
+
+INSERT INTO accounts_with_held (account_id, balance) SELECT account_id, balance FROM accounts;
+DROP TABLE accounts;
+ALTER TABLE accounts_with_held RENAME TO accounts;
+
+CREATE TABLE IF NOT EXISTS holds (
+    hold_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts ON DELETE CASCADE,
+    order_id VARCHAR(255) NOT NULL,
+    amount NUMERIC(18, 2) NOT NULL CONSTRAINT positive_hold_amount CHECK (amount > 0),
+    captured NUMERIC(18, 2) NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS holds_active_expires_at_idx ON holds (expires_at) WHERE status = 'active';
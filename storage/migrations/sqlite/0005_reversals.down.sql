@@ -0,0 +1,14 @@
+-- SQLite cannot drop columns, so transactions is rebuilt without them
+CREATE TABLE transactions_without_reversals (
+    transaction_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts ON DELETE CASCADE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    delta NUMERIC(18, 2) NOT NULL,
+    remaining NUMERIC(18, 2) NOT NULL,
+    message TEXT NOT NULL
+);
+
+INSERT INTO transactions_without_reversals (transaction_id, account_id, created_at, delta, remaining, message)
+    SELECT transaction_id, account_id, created_at, delta, remaining, message FROM transactions;
+DROP TABLE transactions;
+ALTER TABLE transactions_without_reversals RENAME TO transactions;
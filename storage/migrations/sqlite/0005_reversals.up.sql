@@ -0,0 +1,6 @@
+-- reversed is the part of a transaction compensated by reversals so far
+ALTER TABLE transactions ADD COLUMN reversal_of INTEGER REFERENCES transactions;
+ALTER TABLE transactions ADD COLUMN reversed NUMERIC(18, 2) NOT NULL DEFAULT 0
+    CONSTRAINT reversed_within_delta CHECK (ROUND(reversed, 2) <= ABS(delta));
+
+CREATE INDEX IF NOT EXISTS transactions_reversal_of_idx ON transactions (reversal_of);
@@ -0,0 +1,36 @@
+-- the legs of system accounts are deleted with them. SQLite cannot drop columns and change constraints,
+-- so accounts and transactions are rebuilt
+DELETE FROM transactions WHERE account_id < 0;
+DELETE FROM accounts WHERE account_id < 0;
+
+CREATE TABLE transactions_without_entries (
+    transaction_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts ON DELETE CASCADE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    delta NUMERIC(18, 2) NOT NULL,
+    remaining NUMERIC(18, 2) NOT NULL,
+    message TEXT NOT NULL,
+    reversal_of INTEGER REFERENCES transactions,
+    reversed NUMERIC(18, 2) NOT NULL DEFAULT 0,
+    CONSTRAINT reversed_within_delta CHECK (ROUND(reversed, 2) <= ABS(delta))
+);
+
+INSERT INTO transactions_without_entries (transaction_id, account_id, created_at, delta, remaining, message,
+    reversal_of, reversed)
+SELECT transaction_id, account_id, created_at, delta, remaining, message, reversal_of, reversed FROM transactions;
+DROP TABLE transactions;
+ALTER TABLE transactions_without_entries RENAME TO transactions;
+DROP TABLE IF EXISTS journal_entries;
+
+CREATE INDEX IF NOT EXISTS transactions_reversal_of_idx ON transactions (reversal_of);
+
+CREATE TABLE accounts_without_system (
+    account_id INTEGER PRIMARY KEY,
+    balance NUMERIC(18, 2) NOT NULL CONSTRAINT non_negative_balance CHECK (ROUND(balance, 2) >= 0),
+    held NUMERIC(18, 2) NOT NULL DEFAULT 0 CONSTRAINT non_negative_held CHECK (ROUND(held, 2) >= 0),
+    CONSTRAINT non_negative_available CHECK (ROUND(balance - held, 2) >= 0)
+);
+
+INSERT INTO accounts_without_system (account_id, balance, held) SELECT account_id, balance, held FROM accounts;
+DROP TABLE accounts;
+ALTER TABLE accounts_without_system RENAME TO accounts;
@@ -0,0 +1,98 @@
+-- system accounts have negative ids and may have negative balance. SQLite cannot change constraints,
+-- so accounts and transactions are rebuilt
+CREATE TABLE accounts_with_system (
+    account_id INTEGER PRIMARY KEY,
+    balance NUMERIC(18, 2) NOT NULL CONSTRAINT non_negative_balance CHECK (ROUND(balance, 2) >= 0 OR account_id < 0),
+    held NUMERIC(18, 2) NOT NULL DEFAULT 0 CONSTRAINT non_negative_held CHECK (ROUND(held, 2) >= 0),
+    CONSTRAINT non_negative_available CHECK (ROUND(balance - held, 2) >= 0 OR account_id < 0)
+);
+
+INSERT INTO accounts_with_system (account_id, balance, held) SELECT account_id, balance, held FROM accounts;
+DROP TABLE accounts;
+ALTER TABLE accounts_with_system RENAME TO accounts;
+
+-- cash-in, cash-out, fees
+INSERT OR IGNORE INTO accounts (account_id, balance) VALUES (-1, 0), (-2, 0), (-3, 0);
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    entry_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind VARCHAR(16) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- transactions written before the journal become entries. Both legs of a transfer were written at the same time
+-- with the same message prefix and opposite deltas; identical transfers of the same moment pair up once
+CREATE TEMP TABLE transfer_legs AS
+SELECT o.transaction_id AS out_id, MIN(i.transaction_id) AS in_id
+FROM transactions o
+JOIN transactions i ON i.created_at = o.created_at AND ROUND(i.delta + o.delta, 2) = 0
+    AND substr(i.message, 1, instr(i.message, ':')) = substr(o.message, 1, instr(o.message, ':'))
+WHERE o.delta < 0 AND o.message LIKE 'Transfer from account [%'
+GROUP BY o.transaction_id;
+
+DELETE FROM transfer_legs WHERE EXISTS (
+    SELECT 1 FROM transfer_legs f WHERE f.in_id = transfer_legs.in_id AND f.out_id < transfer_legs.out_id);
+
+-- the other transactions are deposits and withdrawals; an entry takes the id of its first transaction
+CREATE TEMP TABLE entry_legs AS
+SELECT transaction_id, transaction_id AS entry_id, CASE WHEN delta < 0 THEN 'withdrawal' ELSE 'deposit' END AS kind
+FROM transactions;
+
+UPDATE entry_legs SET kind = 'transfer-out' WHERE transaction_id IN (SELECT out_id FROM transfer_legs);
+UPDATE entry_legs SET entry_id = l.out_id, kind = 'transfer-in' FROM transfer_legs l
+WHERE entry_legs.transaction_id = l.in_id;
+
+INSERT INTO journal_entries (entry_id, kind, created_at)
+SELECT e.entry_id, CASE e.kind WHEN 'transfer-out' THEN 'transfer' ELSE e.kind END,
+    COALESCE(t.created_at, CURRENT_TIMESTAMP)
+FROM entry_legs e
+JOIN transactions t ON t.transaction_id = e.transaction_id
+WHERE e.entry_id = e.transaction_id;
+
+CREATE TABLE transactions_with_entries (
+    transaction_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts ON DELETE CASCADE,
+    entry_id INTEGER NOT NULL REFERENCES journal_entries,
+    kind VARCHAR(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    delta NUMERIC(18, 2) NOT NULL,
+    remaining NUMERIC(18, 2) NOT NULL,
+    message TEXT NOT NULL,
+    reversal_of INTEGER REFERENCES transactions,
+    reversed NUMERIC(18, 2) NOT NULL DEFAULT 0,
+    CONSTRAINT reversed_within_delta CHECK (ROUND(reversed, 2) <= ABS(delta))
+);
+
+INSERT INTO transactions_with_entries (transaction_id, account_id, entry_id, kind, created_at, delta, remaining,
+    message, reversal_of, reversed)
+SELECT t.transaction_id, t.account_id, e.entry_id, e.kind, t.created_at, t.delta, t.remaining,
+    t.message, t.reversal_of, t.reversed
+FROM transactions t
+JOIN entry_legs e ON e.transaction_id = t.transaction_id;
+
+-- deposits come from cash-in and withdrawals go to cash-out
+INSERT INTO transactions_with_entries (account_id, entry_id, kind, created_at, delta, remaining, message)
+SELECT system_id, entry_id, kind, created_at, delta, remaining,
+    printf('%s account [%d]: balance changed by [%.2f], [%.2f] remaining',
+        CASE kind WHEN 'deposit' THEN 'Deposit to' ELSE 'Withdrawal from' END, account_id, delta, remaining)
+FROM (
+    SELECT t.transaction_id, CASE e.kind WHEN 'deposit' THEN -1 ELSE -2 END AS system_id, t.account_id,
+        e.entry_id, e.kind, t.created_at, -t.delta AS delta,
+        ROUND(SUM(-t.delta) OVER (PARTITION BY e.kind ORDER BY t.transaction_id), 2) AS remaining
+    FROM transactions t
+    JOIN entry_legs e ON e.transaction_id = t.transaction_id
+    WHERE e.kind IN ('deposit', 'withdrawal')
+)
+ORDER BY transaction_id;
+
+DROP TABLE transactions;
+ALTER TABLE transactions_with_entries RENAME TO transactions;
+DROP TABLE transfer_legs;
+DROP TABLE entry_legs;
+
+UPDATE accounts SET balance = COALESCE(
+    (SELECT ROUND(SUM(delta), 2) FROM transactions WHERE transactions.account_id = accounts.account_id), balance)
+WHERE account_id < 0;
+
+CREATE INDEX IF NOT EXISTS transactions_entry_id_idx ON transactions (entry_id);
+CREATE INDEX IF NOT EXISTS transactions_reversal_of_idx ON transactions (reversal_of);
@@ -0,0 +1,13 @@
+-- SQLite cannot drop columns, so accounts is rebuilt without them
+DROP TABLE IF EXISTS account_status_changes;
+
+CREATE TABLE accounts_without_status (
+    account_id INTEGER PRIMARY KEY,
+    balance NUMERIC(18, 2) NOT NULL CONSTRAINT non_negative_balance CHECK (ROUND(balance, 2) >= 0 OR account_id < 0),
+    held NUMERIC(18, 2) NOT NULL DEFAULT 0 CONSTRAINT non_negative_held CHECK (ROUND(held, 2) >= 0),
+    CONSTRAINT non_negative_available CHECK (ROUND(balance - held, 2) >= 0 OR account_id < 0)
+);
+
+INSERT INTO accounts_without_status (account_id, balance, held) SELECT account_id, balance, held FROM accounts;
+DROP TABLE accounts;
+ALTER TABLE accounts_without_status RENAME TO accounts;
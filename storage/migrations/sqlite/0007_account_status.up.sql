@@ -0,0 +1,17 @@
+-- frozen accounts reject debits or all movements depending on freeze_scope; closed accounts reject all movements
+ALTER TABLE accounts ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active'
+    CONSTRAINT valid_account_status CHECK (status IN ('active', 'frozen', 'closed'));
+ALTER TABLE accounts ADD COLUMN freeze_scope VARCHAR(16) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS account_status_changes (
+    status_change_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES accounts ON DELETE CASCADE,
+    previous_status VARCHAR(16) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    freeze_scope VARCHAR(16) NOT NULL DEFAULT '',
+    reason TEXT NOT NULL,
+    sweep_entry_id INTEGER REFERENCES journal_entries,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS account_status_changes_account_id_idx ON account_status_changes (account_id);
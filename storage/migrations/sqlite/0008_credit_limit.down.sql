@@ -0,0 +1,15 @@
+-- fails while any account uses its credit line
+CREATE TABLE accounts_without_credit_limit (
+    account_id INTEGER PRIMARY KEY,
+    balance NUMERIC(18, 2) NOT NULL CONSTRAINT non_negative_balance CHECK (ROUND(balance, 2) >= 0 OR account_id < 0),
+    held NUMERIC(18, 2) NOT NULL DEFAULT 0 CONSTRAINT non_negative_held CHECK (ROUND(held, 2) >= 0),
+    status VARCHAR(16) NOT NULL DEFAULT 'active'
+        CONSTRAINT valid_account_status CHECK (status IN ('active', 'frozen', 'closed')),
+    freeze_scope VARCHAR(16) NOT NULL DEFAULT '',
+    CONSTRAINT non_negative_available CHECK (ROUND(balance - held, 2) >= 0 OR account_id < 0)
+);
+
+INSERT INTO accounts_without_credit_limit (account_id, balance, held, status, freeze_scope)
+    SELECT account_id, balance, held, status, freeze_scope FROM accounts;
+DROP TABLE accounts;
+ALTER TABLE accounts_without_credit_limit RENAME TO accounts;
@@ -0,0 +1,18 @@
+-- an account may go down to -credit_limit; accounts without a limit stay non-negative.
+-- SQLite cannot change constraints, so accounts is rebuilt
+CREATE TABLE accounts_with_credit_limit (
+    account_id INTEGER PRIMARY KEY,
+    balance NUMERIC(18, 2) NOT NULL,
+    held NUMERIC(18, 2) NOT NULL DEFAULT 0 CONSTRAINT non_negative_held CHECK (ROUND(held, 2) >= 0),
+    status VARCHAR(16) NOT NULL DEFAULT 'active'
+        CONSTRAINT valid_account_status CHECK (status IN ('active', 'frozen', 'closed')),
+    freeze_scope VARCHAR(16) NOT NULL DEFAULT '',
+    credit_limit NUMERIC(18, 2) NOT NULL DEFAULT 0 CONSTRAINT non_negative_credit_limit CHECK (credit_limit >= 0),
+    CONSTRAINT non_negative_balance CHECK (ROUND(balance + credit_limit, 2) >= 0 OR account_id < 0),
+    CONSTRAINT non_negative_available CHECK (ROUND(balance - held + credit_limit, 2) >= 0 OR account_id < 0)
+);
+
+INSERT INTO accounts_with_credit_limit (account_id, balance, held, status, freeze_scope)
+    SELECT account_id, balance, held, status, freeze_scope FROM accounts;
+DROP TABLE accounts;
+ALTER TABLE accounts_with_credit_limit RENAME TO accounts;
@@ -0,0 +1,10 @@
+-- NULL limits are not checked
+CREATE TABLE IF NOT EXISTS spending_limits (
+    account_id INTEGER PRIMARY KEY REFERENCES accounts ON DELETE CASCADE,
+    daily_debit NUMERIC(18, 2) CONSTRAINT non_negative_daily_debit CHECK (daily_debit >= 0),
+    hourly_transfers INT CONSTRAINT non_negative_hourly_transfers CHECK (hourly_transfers >= 0),
+    single_transfer NUMERIC(18, 2) CONSTRAINT non_negative_single_transfer CHECK (single_transfer >= 0)
+);
+
+-- rolling sums of outgoing money of an account
+CREATE INDEX IF NOT EXISTS transactions_account_id_created_at_idx ON transactions (account_id, created_at);
@@ -0,0 +1,37 @@
+-- accounts of a schedule are not referenced: like transfers, a schedule may name accounts that do not exist yet
+CREATE TABLE IF NOT EXISTS schedules (
+    schedule_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_id INTEGER NOT NULL,
+    to_id INTEGER NOT NULL,
+    amount NUMERIC(18, 2) NOT NULL CONSTRAINT positive_amount CHECK (amount > 0),
+    fee NUMERIC(18, 2) NOT NULL DEFAULT 0 CONSTRAINT non_negative_fee CHECK (fee >= 0),
+    cron VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL
+        CONSTRAINT valid_schedule_status CHECK (status IN ('active', 'paused', 'completed', 'deleted')),
+    max_retries INTEGER NOT NULL DEFAULT 0,
+    retry_interval INTEGER NOT NULL DEFAULT 0,
+    attempt INTEGER NOT NULL DEFAULT 0,
+    occurrence_at DATETIME NOT NULL,
+    next_run_at DATETIME NOT NULL,
+    version INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS schedules_status_next_run_at_idx ON schedules (status, next_run_at);
+CREATE INDEX IF NOT EXISTS schedules_from_id_idx ON schedules (from_id);
+
+-- an attempt of an occurrence is recorded once
+CREATE TABLE IF NOT EXISTS schedule_runs (
+    run_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    schedule_id INTEGER NOT NULL REFERENCES schedules ON DELETE CASCADE,
+    occurrence_at DATETIME NOT NULL,
+    attempt INTEGER NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    transaction_id INTEGER REFERENCES transactions,
+    error_code INTEGER,
+    error TEXT NOT NULL DEFAULT '',
+    retry_at DATETIME,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_schedule_run UNIQUE (schedule_id, occurrence_at, attempt)
+);
@@ -1,13 +1,15 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: balance_microservice/storage (interfaces: Store)
+// Source: github.com/dalconoid/balance-service/storage (interfaces: Store)
 
 // Package mockdb is a generated GoMock package.
 package mockdb
 
 import (
-	models "github.com/dalconoid/balance-service/models"
+	context "context"
 	reflect "reflect"
+	time "time"
 
+	models "github.com/dalconoid/balance-service/models"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -34,62 +36,585 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// CaptureHold mocks base method.
+func (m *MockStore) CaptureHold(arg0 context.Context, arg1 *models.CaptureHoldRequest) (*models.Transaction, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CaptureHold", arg0, arg1)
+	ret0, _ := ret[0].(*models.Transaction)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// CaptureHold indicates an expected call of CaptureHold.
+func (mr *MockStoreMockRecorder) CaptureHold(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CaptureHold", reflect.TypeOf((*MockStore)(nil).CaptureHold), arg0, arg1)
+}
+
+// ClaimWebhookDeliveries mocks base method.
+func (m *MockStore) ClaimWebhookDeliveries(arg0 context.Context, arg1 int, arg2 time.Duration) ([]models.DeliveryTask, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimWebhookDeliveries", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.DeliveryTask)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// ClaimWebhookDeliveries indicates an expected call of ClaimWebhookDeliveries.
+func (mr *MockStoreMockRecorder) ClaimWebhookDeliveries(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimWebhookDeliveries", reflect.TypeOf((*MockStore)(nil).ClaimWebhookDeliveries), arg0, arg1, arg2)
+}
+
+// CloseAccount mocks base method.
+func (m *MockStore) CloseAccount(arg0 context.Context, arg1 *models.CloseAccountRequest) (*models.Account, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseAccount", arg0, arg1)
+	ret0, _ := ret[0].(*models.Account)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// CloseAccount indicates an expected call of CloseAccount.
+func (mr *MockStoreMockRecorder) CloseAccount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseAccount", reflect.TypeOf((*MockStore)(nil).CloseAccount), arg0, arg1)
+}
+
+// CreateSchedule mocks base method.
+func (m *MockStore) CreateSchedule(arg0 context.Context, arg1 *models.ScheduleRequest) (*models.Schedule, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSchedule", arg0, arg1)
+	ret0, _ := ret[0].(*models.Schedule)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// CreateSchedule indicates an expected call of CreateSchedule.
+func (mr *MockStoreMockRecorder) CreateSchedule(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSchedule", reflect.TypeOf((*MockStore)(nil).CreateSchedule), arg0, arg1)
+}
+
+// CreateWebhook mocks base method.
+func (m *MockStore) CreateWebhook(arg0 context.Context, arg1 *models.WebhookRequest) (*models.Webhook, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", arg0, arg1)
+	ret0, _ := ret[0].(*models.Webhook)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockStoreMockRecorder) CreateWebhook(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockStore)(nil).CreateWebhook), arg0, arg1)
+}
+
+// DeleteExpiredIdempotencyKeys mocks base method.
+func (m *MockStore) DeleteExpiredIdempotencyKeys(arg0 context.Context) (int, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredIdempotencyKeys", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// DeleteExpiredIdempotencyKeys indicates an expected call of DeleteExpiredIdempotencyKeys.
+func (mr *MockStoreMockRecorder) DeleteExpiredIdempotencyKeys(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredIdempotencyKeys", reflect.TypeOf((*MockStore)(nil).DeleteExpiredIdempotencyKeys), arg0)
+}
+
+// DeleteSchedule mocks base method.
+func (m *MockStore) DeleteSchedule(arg0 context.Context, arg1 int) (*models.Schedule, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSchedule", arg0, arg1)
+	ret0, _ := ret[0].(*models.Schedule)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// DeleteSchedule indicates an expected call of DeleteSchedule.
+func (mr *MockStoreMockRecorder) DeleteSchedule(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSchedule", reflect.TypeOf((*MockStore)(nil).DeleteSchedule), arg0, arg1)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockStore) DeleteWebhook(arg0 context.Context, arg1 int) (*models.Webhook, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", arg0, arg1)
+	ret0, _ := ret[0].(*models.Webhook)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockStoreMockRecorder) DeleteWebhook(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockStore)(nil).DeleteWebhook), arg0, arg1)
+}
+
+// FinishWebhookDelivery mocks base method.
+func (m *MockStore) FinishWebhookDelivery(arg0 context.Context, arg1 *models.WebhookDelivery) *models.CustomErr {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishWebhookDelivery", arg0, arg1)
+	ret0, _ := ret[0].(*models.CustomErr)
+	return ret0
+}
+
+// FinishWebhookDelivery indicates an expected call of FinishWebhookDelivery.
+func (mr *MockStoreMockRecorder) FinishWebhookDelivery(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishWebhookDelivery", reflect.TypeOf((*MockStore)(nil).FinishWebhookDelivery), arg0, arg1)
+}
+
+// FreezeAccount mocks base method.
+func (m *MockStore) FreezeAccount(arg0 context.Context, arg1 *models.FreezeAccountRequest) (*models.Account, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FreezeAccount", arg0, arg1)
+	ret0, _ := ret[0].(*models.Account)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// FreezeAccount indicates an expected call of FreezeAccount.
+func (mr *MockStoreMockRecorder) FreezeAccount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FreezeAccount", reflect.TypeOf((*MockStore)(nil).FreezeAccount), arg0, arg1)
+}
+
+// GetAccountStatusHistory mocks base method.
+func (m *MockStore) GetAccountStatusHistory(arg0 context.Context, arg1 int) ([]models.AccountStatusChange, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccountStatusHistory", arg0, arg1)
+	ret0, _ := ret[0].([]models.AccountStatusChange)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// GetAccountStatusHistory indicates an expected call of GetAccountStatusHistory.
+func (mr *MockStoreMockRecorder) GetAccountStatusHistory(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountStatusHistory", reflect.TypeOf((*MockStore)(nil).GetAccountStatusHistory), arg0, arg1)
+}
+
 // GetBalance mocks base method.
-func (m *MockStore) GetBalance(arg0 int) (*models.Account, *models.CustomErr) {
+func (m *MockStore) GetBalance(arg0 context.Context, arg1 int) (*models.Account, *models.CustomErr) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBalance", arg0)
+	ret := m.ctrl.Call(m, "GetBalance", arg0, arg1)
 	ret0, _ := ret[0].(*models.Account)
 	ret1, _ := ret[1].(*models.CustomErr)
 	return ret0, ret1
 }
 
 // GetBalance indicates an expected call of GetBalance.
-func (mr *MockStoreMockRecorder) GetBalance(arg0 interface{}) *gomock.Call {
+func (mr *MockStoreMockRecorder) GetBalance(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockStore)(nil).GetBalance), arg0, arg1)
+}
+
+// GetBalanceAsOf mocks base method.
+func (m *MockStore) GetBalanceAsOf(arg0 context.Context, arg1 int, arg2 time.Time) (*models.BalanceAsOf, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalanceAsOf", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.BalanceAsOf)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// GetBalanceAsOf indicates an expected call of GetBalanceAsOf.
+func (mr *MockStoreMockRecorder) GetBalanceAsOf(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceAsOf", reflect.TypeOf((*MockStore)(nil).GetBalanceAsOf), arg0, arg1, arg2)
+}
+
+// GetHold mocks base method.
+func (m *MockStore) GetHold(arg0 context.Context, arg1 int) (*models.Hold, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHold", arg0, arg1)
+	ret0, _ := ret[0].(*models.Hold)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// GetHold indicates an expected call of GetHold.
+func (mr *MockStoreMockRecorder) GetHold(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHold", reflect.TypeOf((*MockStore)(nil).GetHold), arg0, arg1)
+}
+
+// GetOutboxEvents mocks base method.
+func (m *MockStore) GetOutboxEvents(arg0 context.Context, arg1 models.OutboxPosition, arg2 int) ([]models.OutboxEvent, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOutboxEvents", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.OutboxEvent)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// GetOutboxEvents indicates an expected call of GetOutboxEvents.
+func (mr *MockStoreMockRecorder) GetOutboxEvents(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOutboxEvents", reflect.TypeOf((*MockStore)(nil).GetOutboxEvents), arg0, arg1, arg2)
+}
+
+// GetSchedule mocks base method.
+func (m *MockStore) GetSchedule(arg0 context.Context, arg1 int) (*models.Schedule, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSchedule", arg0, arg1)
+	ret0, _ := ret[0].(*models.Schedule)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// GetSchedule indicates an expected call of GetSchedule.
+func (mr *MockStoreMockRecorder) GetSchedule(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSchedule", reflect.TypeOf((*MockStore)(nil).GetSchedule), arg0, arg1)
+}
+
+// GetScheduleRuns mocks base method.
+func (m *MockStore) GetScheduleRuns(arg0 context.Context, arg1 int) ([]models.ScheduleRun, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetScheduleRuns", arg0, arg1)
+	ret0, _ := ret[0].([]models.ScheduleRun)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// GetScheduleRuns indicates an expected call of GetScheduleRuns.
+func (mr *MockStoreMockRecorder) GetScheduleRuns(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetScheduleRuns", reflect.TypeOf((*MockStore)(nil).GetScheduleRuns), arg0, arg1)
+}
+
+// GetSchedules mocks base method.
+func (m *MockStore) GetSchedules(arg0 context.Context, arg1 int) ([]models.Schedule, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSchedules", arg0, arg1)
+	ret0, _ := ret[0].([]models.Schedule)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// GetSchedules indicates an expected call of GetSchedules.
+func (mr *MockStoreMockRecorder) GetSchedules(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSchedules", reflect.TypeOf((*MockStore)(nil).GetSchedules), arg0, arg1)
+}
+
+// GetSpendingLimits mocks base method.
+func (m *MockStore) GetSpendingLimits(arg0 context.Context, arg1 int) (*models.SpendingLimits, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSpendingLimits", arg0, arg1)
+	ret0, _ := ret[0].(*models.SpendingLimits)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// GetSpendingLimits indicates an expected call of GetSpendingLimits.
+func (mr *MockStoreMockRecorder) GetSpendingLimits(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSpendingLimits", reflect.TypeOf((*MockStore)(nil).GetSpendingLimits), arg0, arg1)
+}
+
+// GetSystemAccounts mocks base method.
+func (m *MockStore) GetSystemAccounts(arg0 context.Context) ([]models.SystemAccount, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSystemAccounts", arg0)
+	ret0, _ := ret[0].([]models.SystemAccount)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// GetSystemAccounts indicates an expected call of GetSystemAccounts.
+func (mr *MockStoreMockRecorder) GetSystemAccounts(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockStore)(nil).GetBalance), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSystemAccounts", reflect.TypeOf((*MockStore)(nil).GetSystemAccounts), arg0)
 }
 
 // GetTransactionHistory mocks base method.
-func (m *MockStore) GetTransactionHistory(arg0 int, arg1, arg2 string, arg3 int) ([]models.Transaction, *models.CustomErr) {
+func (m *MockStore) GetTransactionHistory(arg0 context.Context, arg1 *models.HistoryRequest) (*models.HistoryPage, *models.CustomErr) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTransactionHistory", arg0, arg1, arg2, arg3)
-	ret0, _ := ret[0].([]models.Transaction)
+	ret := m.ctrl.Call(m, "GetTransactionHistory", arg0, arg1)
+	ret0, _ := ret[0].(*models.HistoryPage)
 	ret1, _ := ret[1].(*models.CustomErr)
 	return ret0, ret1
 }
 
 // GetTransactionHistory indicates an expected call of GetTransactionHistory.
-func (mr *MockStoreMockRecorder) GetTransactionHistory(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+func (mr *MockStoreMockRecorder) GetTransactionHistory(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionHistory", reflect.TypeOf((*MockStore)(nil).GetTransactionHistory), arg0, arg1)
+}
+
+// GetWebhookDeliveries mocks base method.
+func (m *MockStore) GetWebhookDeliveries(arg0 context.Context, arg1 int) ([]models.WebhookDelivery, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveries", arg0, arg1)
+	ret0, _ := ret[0].([]models.WebhookDelivery)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveries indicates an expected call of GetWebhookDeliveries.
+func (mr *MockStoreMockRecorder) GetWebhookDeliveries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveries", reflect.TypeOf((*MockStore)(nil).GetWebhookDeliveries), arg0, arg1)
+}
+
+// GetWebhooks mocks base method.
+func (m *MockStore) GetWebhooks(arg0 context.Context) ([]models.Webhook, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhooks", arg0)
+	ret0, _ := ret[0].([]models.Webhook)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// GetWebhooks indicates an expected call of GetWebhooks.
+func (mr *MockStoreMockRecorder) GetWebhooks(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhooks", reflect.TypeOf((*MockStore)(nil).GetWebhooks), arg0)
+}
+
+// LastOutboxPosition mocks base method.
+func (m *MockStore) LastOutboxPosition(arg0 context.Context) (models.OutboxPosition, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastOutboxPosition", arg0)
+	ret0, _ := ret[0].(models.OutboxPosition)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// LastOutboxPosition indicates an expected call of LastOutboxPosition.
+func (mr *MockStoreMockRecorder) LastOutboxPosition(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastOutboxPosition", reflect.TypeOf((*MockStore)(nil).LastOutboxPosition), arg0)
+}
+
+// MakeBatchTransfer mocks base method.
+func (m *MockStore) MakeBatchTransfer(arg0 context.Context, arg1 *models.BatchTransferRequest) ([]models.Transaction, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MakeBatchTransfer", arg0, arg1)
+	ret0, _ := ret[0].([]models.Transaction)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// MakeBatchTransfer indicates an expected call of MakeBatchTransfer.
+func (mr *MockStoreMockRecorder) MakeBatchTransfer(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionHistory", reflect.TypeOf((*MockStore)(nil).GetTransactionHistory), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeBatchTransfer", reflect.TypeOf((*MockStore)(nil).MakeBatchTransfer), arg0, arg1)
 }
 
 // MakeTransfer mocks base method.
-func (m *MockStore) MakeTransfer(arg0 *models.TransferRequest) (*models.Transaction, *models.CustomErr) {
+func (m *MockStore) MakeTransfer(arg0 context.Context, arg1 *models.TransferRequest) (*models.Transaction, *models.CustomErr) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MakeTransfer", arg0)
+	ret := m.ctrl.Call(m, "MakeTransfer", arg0, arg1)
 	ret0, _ := ret[0].(*models.Transaction)
 	ret1, _ := ret[1].(*models.CustomErr)
 	return ret0, ret1
 }
 
 // MakeTransfer indicates an expected call of MakeTransfer.
-func (mr *MockStoreMockRecorder) MakeTransfer(arg0 interface{}) *gomock.Call {
+func (mr *MockStoreMockRecorder) MakeTransfer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeTransfer", reflect.TypeOf((*MockStore)(nil).MakeTransfer), arg0, arg1)
+}
+
+// PauseSchedule mocks base method.
+func (m *MockStore) PauseSchedule(arg0 context.Context, arg1 int) (*models.Schedule, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PauseSchedule", arg0, arg1)
+	ret0, _ := ret[0].(*models.Schedule)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// PauseSchedule indicates an expected call of PauseSchedule.
+func (mr *MockStoreMockRecorder) PauseSchedule(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PauseSchedule", reflect.TypeOf((*MockStore)(nil).PauseSchedule), arg0, arg1)
+}
+
+// PlaceHold mocks base method.
+func (m *MockStore) PlaceHold(arg0 context.Context, arg1 *models.HoldRequest) (*models.Hold, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PlaceHold", arg0, arg1)
+	ret0, _ := ret[0].(*models.Hold)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// PlaceHold indicates an expected call of PlaceHold.
+func (mr *MockStoreMockRecorder) PlaceHold(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PlaceHold", reflect.TypeOf((*MockStore)(nil).PlaceHold), arg0, arg1)
+}
+
+// RedeliverWebhookDelivery mocks base method.
+func (m *MockStore) RedeliverWebhookDelivery(arg0 context.Context, arg1 int) (*models.WebhookDelivery, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedeliverWebhookDelivery", arg0, arg1)
+	ret0, _ := ret[0].(*models.WebhookDelivery)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// RedeliverWebhookDelivery indicates an expected call of RedeliverWebhookDelivery.
+func (mr *MockStoreMockRecorder) RedeliverWebhookDelivery(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeliverWebhookDelivery", reflect.TypeOf((*MockStore)(nil).RedeliverWebhookDelivery), arg0, arg1)
+}
+
+// ReleaseExpiredHolds mocks base method.
+func (m *MockStore) ReleaseExpiredHolds(arg0 context.Context) (int, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseExpiredHolds", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// ReleaseExpiredHolds indicates an expected call of ReleaseExpiredHolds.
+func (mr *MockStoreMockRecorder) ReleaseExpiredHolds(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseExpiredHolds", reflect.TypeOf((*MockStore)(nil).ReleaseExpiredHolds), arg0)
+}
+
+// ReleaseHold mocks base method.
+func (m *MockStore) ReleaseHold(arg0 context.Context, arg1 int) (*models.Hold, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseHold", arg0, arg1)
+	ret0, _ := ret[0].(*models.Hold)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// ReleaseHold indicates an expected call of ReleaseHold.
+func (mr *MockStoreMockRecorder) ReleaseHold(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseHold", reflect.TypeOf((*MockStore)(nil).ReleaseHold), arg0, arg1)
+}
+
+// ResumeSchedule mocks base method.
+func (m *MockStore) ResumeSchedule(arg0 context.Context, arg1 int) (*models.Schedule, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeSchedule", arg0, arg1)
+	ret0, _ := ret[0].(*models.Schedule)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// ResumeSchedule indicates an expected call of ResumeSchedule.
+func (mr *MockStoreMockRecorder) ResumeSchedule(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeSchedule", reflect.TypeOf((*MockStore)(nil).ResumeSchedule), arg0, arg1)
+}
+
+// ReverseTransaction mocks base method.
+func (m *MockStore) ReverseTransaction(arg0 context.Context, arg1 *models.ReverseRequest) ([]models.Transaction, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReverseTransaction", arg0, arg1)
+	ret0, _ := ret[0].([]models.Transaction)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// ReverseTransaction indicates an expected call of ReverseTransaction.
+func (mr *MockStoreMockRecorder) ReverseTransaction(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReverseTransaction", reflect.TypeOf((*MockStore)(nil).ReverseTransaction), arg0, arg1)
+}
+
+// RunDueSchedules mocks base method.
+func (m *MockStore) RunDueSchedules(arg0 context.Context) (int, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RunDueSchedules", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// RunDueSchedules indicates an expected call of RunDueSchedules.
+func (mr *MockStoreMockRecorder) RunDueSchedules(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunDueSchedules", reflect.TypeOf((*MockStore)(nil).RunDueSchedules), arg0)
+}
+
+// SetCreditLimit mocks base method.
+func (m *MockStore) SetCreditLimit(arg0 context.Context, arg1 *models.CreditLimitRequest) (*models.Account, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetCreditLimit", arg0, arg1)
+	ret0, _ := ret[0].(*models.Account)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// SetCreditLimit indicates an expected call of SetCreditLimit.
+func (mr *MockStoreMockRecorder) SetCreditLimit(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCreditLimit", reflect.TypeOf((*MockStore)(nil).SetCreditLimit), arg0, arg1)
+}
+
+// SetSpendingLimits mocks base method.
+func (m *MockStore) SetSpendingLimits(arg0 context.Context, arg1 *models.SpendingLimits) (*models.SpendingLimits, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSpendingLimits", arg0, arg1)
+	ret0, _ := ret[0].(*models.SpendingLimits)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// SetSpendingLimits indicates an expected call of SetSpendingLimits.
+func (mr *MockStoreMockRecorder) SetSpendingLimits(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSpendingLimits", reflect.TypeOf((*MockStore)(nil).SetSpendingLimits), arg0, arg1)
+}
+
+// StreamTransactionHistory mocks base method.
+func (m *MockStore) StreamTransactionHistory(arg0 context.Context, arg1 *models.HistoryRequest, arg2 func(*models.Transaction) error) *models.CustomErr {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamTransactionHistory", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.CustomErr)
+	return ret0
+}
+
+// StreamTransactionHistory indicates an expected call of StreamTransactionHistory.
+func (mr *MockStoreMockRecorder) StreamTransactionHistory(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamTransactionHistory", reflect.TypeOf((*MockStore)(nil).StreamTransactionHistory), arg0, arg1, arg2)
+}
+
+// UnfreezeAccount mocks base method.
+func (m *MockStore) UnfreezeAccount(arg0 context.Context, arg1 *models.UnfreezeAccountRequest) (*models.Account, *models.CustomErr) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnfreezeAccount", arg0, arg1)
+	ret0, _ := ret[0].(*models.Account)
+	ret1, _ := ret[1].(*models.CustomErr)
+	return ret0, ret1
+}
+
+// UnfreezeAccount indicates an expected call of UnfreezeAccount.
+func (mr *MockStoreMockRecorder) UnfreezeAccount(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeTransfer", reflect.TypeOf((*MockStore)(nil).MakeTransfer), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnfreezeAccount", reflect.TypeOf((*MockStore)(nil).UnfreezeAccount), arg0, arg1)
 }
 
 // UpdateBalance mocks base method.
-func (m *MockStore) UpdateBalance(arg0 *models.ChangeBalanceRequest) (*models.Transaction, *models.CustomErr) {
+func (m *MockStore) UpdateBalance(arg0 context.Context, arg1 *models.ChangeBalanceRequest) (*models.Transaction, *models.CustomErr) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateBalance", arg0)
+	ret := m.ctrl.Call(m, "UpdateBalance", arg0, arg1)
 	ret0, _ := ret[0].(*models.Transaction)
 	ret1, _ := ret[1].(*models.CustomErr)
 	return ret0, ret1
 }
 
 // UpdateBalance indicates an expected call of UpdateBalance.
-func (mr *MockStoreMockRecorder) UpdateBalance(arg0 interface{}) *gomock.Call {
+func (mr *MockStoreMockRecorder) UpdateBalance(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBalance", reflect.TypeOf((*MockStore)(nil).UpdateBalance), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBalance", reflect.TypeOf((*MockStore)(nil).UpdateBalance), arg0, arg1)
 }
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"github.com/dalconoid/balance-service/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+//ReverseTransaction posts a reversal entry compensating the whole or a part of the journal entry of a transaction;
+//all legs of the entry except fees are reversed together. Returns compensating transactions
+func (db *Database) ReverseTransaction(ctx context.Context, request *models.ReverseRequest) (_ []models.Transaction, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "ReverseTransaction")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	tx := db.Db.Begin()
+	now := time.Now()
+
+	original, err := findTransaction(tx, request.TransactionID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	amount, err := ReversalAmount(original, request.Amount)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	entry := make([]models.Transaction, 0)
+	if result := tx.Where("entry_id = ?", original.EntryID).Order("transaction_id").Find(&entry); result.Error != nil {
+		tx.Rollback()
+		return nil, models.InternalError(result.Error)
+	}
+	legs := ReversalLegs(entry, amount)
+	for _, leg := range legs {
+		//reversed amount is checked in the update itself so that concurrent reversals cannot exceed the original
+		result := tx.Model(&models.Transaction{}).
+			Where("transaction_id = ? AND ROUND(reversed + ?, 2) <= ABS(delta)", *leg.ReversalOf, amount).
+			UpdateColumn("reversed", gorm.Expr("reversed + ?", amount))
+		if result.Error != nil {
+			tx.Rollback()
+			return nil, models.InternalError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			return nil, reversalExceeded(original, amount)
+		}
+	}
+
+	reversals, err := postEntry(tx, models.EntryKindReversal, now, legs)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	tx.Commit()
+	return reversals, nil
+}
+
+//ReversalAmount returns amount to reverse from original; zero requested amount means the rest of the original
+func ReversalAmount(original *models.Transaction, requested models.Money) (models.Money, *models.CustomErr) {
+	if original.ReversalOf != nil {
+		return 0, models.NewErrorf(models.ErrorReversalNotAllowedCode,
+			"transaction [%v] is a reversal and cannot be reversed", original.ID)
+	}
+	if original.Kind == models.TransactionKindFee {
+		return 0, models.NewErrorf(models.ErrorReversalNotAllowedCode,
+			"transaction [%v] is a fee and cannot be reversed", original.ID)
+	}
+	rest := original.Delta.Abs() - original.Reversed
+	amount := requested
+	if amount == 0 {
+		amount = rest
+	}
+	if amount == 0 || amount > rest {
+		return 0, reversalExceeded(original, amount)
+	}
+	return amount, nil
+}
+
+func reversalExceeded(original *models.Transaction, amount models.Money) *models.CustomErr {
+	return models.NewErrorf(models.ErrorReversalNotAllowedCode,
+		"cannot reverse [%v] of transaction [%v]: [%v] of [%v] already reversed",
+		amount, original.ID, original.Reversed, original.Delta.Abs())
+}
+
+func findTransaction(tx *gorm.DB, id int) (*models.Transaction, *models.CustomErr) {
+	transaction := &models.Transaction{}
+	result := tx.Limit(1).Find(transaction, id)
+	if result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, models.NotFoundError("transaction", id)
+	}
+	return transaction, nil
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestSQLiteReverseTransfer(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+	transfer, _ := db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 4000})
+	assert.Equal(t, transfer.Kind, models.TransactionKindTransferOut)
+
+	reversals, cErr := db.ReverseTransaction(ctx, &models.ReverseRequest{TransactionID: transfer.ID, Amount: 1500})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, len(reversals), 2)
+	assert.Equal(t, reversals[0].Delta, models.Money(1500))
+	assert.Equal(t, *reversals[0].ReversalOf, transfer.ID)
+	assert.Equal(t, reversals[1].Delta, models.Money(-1500))
+	assert.Equal(t, reversals[0].EntryID, reversals[1].EntryID)
+
+	_, cErr = db.ReverseTransaction(ctx, &models.ReverseRequest{TransactionID: transfer.ID, Amount: 3000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorReversalNotAllowedCode)
+
+	reversals, cErr = db.ReverseTransaction(ctx, &models.ReverseRequest{TransactionID: transfer.ID + 1})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, reversals[1].Delta, models.Money(-2500))
+
+	acc1, _ := db.GetBalance(ctx, 1)
+	acc2, _ := db.GetBalance(ctx, 2)
+	assert.Equal(t, acc1.Balance, models.Money(10000))
+	assert.Equal(t, acc2.Balance, models.Money(0))
+
+	_, cErr = db.ReverseTransaction(ctx, &models.ReverseRequest{TransactionID: transfer.ID})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorReversalNotAllowedCode)
+	_, cErr = db.ReverseTransaction(ctx, &models.ReverseRequest{TransactionID: reversals[0].ID})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorReversalNotAllowedCode)
+}
+
+func TestSQLiteReverseSpentDeposit(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	deposit, _ := db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: -9000})
+
+	_, cErr := db.ReverseTransaction(ctx, &models.ReverseRequest{TransactionID: deposit.ID})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+
+	reversals, cErr := db.ReverseTransaction(ctx, &models.ReverseRequest{TransactionID: deposit.ID, Amount: 1000})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, reversals[0].Remaining, models.Money(0))
+}
@@ -0,0 +1,380 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/dalconoid/balance-service/cron"
+	"github.com/dalconoid/balance-service/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+//CreateSchedule creates a schedule of transfers; retry policy of request defaults to ScheduleRetryPolicy
+func (db *Database) CreateSchedule(ctx context.Context, request *models.ScheduleRequest) (_ *models.Schedule, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "CreateSchedule")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	schedule, err := NewSchedule(request, db.ScheduleRetryPolicy, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if result := db.Db.Create(schedule); result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	return schedule, nil
+}
+
+//GetSchedule returns schedule with id=id
+func (db *Database) GetSchedule(ctx context.Context, id int) (_ *models.Schedule, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "GetSchedule")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	return findSchedule(db.Db, id)
+}
+
+//GetSchedules returns schedules of transfers from account with id=id except deleted ones
+func (db *Database) GetSchedules(ctx context.Context, id int) (_ []models.Schedule, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "GetSchedules")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	schedules := make([]models.Schedule, 0)
+	result := db.Db.Where("from_id = ? AND status <> ?", id, models.ScheduleStatusDeleted).Order("schedule_id").Find(&schedules)
+	if result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	return schedules, nil
+}
+
+//GetScheduleRuns returns runs of schedule with id=id in the order they were made
+func (db *Database) GetScheduleRuns(ctx context.Context, id int) (_ []models.ScheduleRun, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "GetScheduleRuns")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	if _, err := findSchedule(db.Db, id); err != nil {
+		return nil, err
+	}
+	runs := make([]models.ScheduleRun, 0)
+	result := db.Db.Where("schedule_id = ?", id).Order("run_id").Find(&runs)
+	if result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	return runs, nil
+}
+
+//PauseSchedule stops runs of active schedule until it is resumed
+func (db *Database) PauseSchedule(ctx context.Context, id int) (_ *models.Schedule, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "PauseSchedule")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	return db.changeSchedule(id, PauseSchedule)
+}
+
+//ResumeSchedule makes paused schedule active again
+func (db *Database) ResumeSchedule(ctx context.Context, id int) (_ *models.Schedule, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "ResumeSchedule")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	return db.changeSchedule(id, ResumeSchedule)
+}
+
+//DeleteSchedule stops runs of schedule for good; its runs are kept
+func (db *Database) DeleteSchedule(ctx context.Context, id int) (_ *models.Schedule, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "DeleteSchedule")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	return db.changeSchedule(id, DeleteSchedule)
+}
+
+//RunDueSchedules makes transfers of active schedules due by now; returns the number of runs made
+func (db *Database) RunDueSchedules(ctx context.Context) (_ int, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "RunDueSchedules")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	now := time.Now()
+	due := make([]models.Schedule, 0)
+	result := db.Db.Where("status = ? AND next_run_at <= ?", models.ScheduleStatusActive, now).Order("next_run_at").Find(&due)
+	if result.Error != nil {
+		return 0, models.InternalError(result.Error)
+	}
+
+	runs := 0
+	for i := range due {
+		run, err := db.runSchedule(&due[i], now)
+		if err != nil {
+			return runs, err
+		}
+		if run != nil {
+			runs++
+		}
+	}
+	return runs, nil
+}
+
+//runSchedule makes the transfer of schedule, records the run and moves the schedule to its next run in one
+//database transaction; a run that was not committed is made again, a committed one is never repeated.
+//Returns nil run if the schedule was changed since it was read, e.g. by another worker
+func (db *Database) runSchedule(schedule *models.Schedule, now time.Time) (*models.ScheduleRun, *models.CustomErr) {
+	tx := db.Db.Begin()
+
+	//claiming changes the version, so the run can be claimed once
+	result := tx.Model(&models.Schedule{}).Where("schedule_id = ? AND version = ?", schedule.ID, schedule.Version).
+		UpdateColumn("version", gorm.Expr("version + 1"))
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, models.InternalError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, nil
+	}
+	schedule.Version++
+
+	//a failed transfer is undone while its run is still recorded
+	if result = tx.SavePoint("transfer"); result.Error != nil {
+		tx.Rollback()
+		return nil, models.InternalError(result.Error)
+	}
+	transaction, err := makeTransfer(tx, ScheduleTransfer(schedule), now)
+	if err != nil {
+		if result = tx.RollbackTo("transfer"); result.Error != nil {
+			tx.Rollback()
+			return nil, models.InternalError(result.Error)
+		}
+	}
+
+	run := FinishRun(schedule, transaction, err, now)
+	if result = tx.Create(run); result.Error != nil {
+		tx.Rollback()
+		return nil, models.InternalError(result.Error)
+	}
+	if err = saveSchedule(tx, schedule); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if result = tx.Commit(); result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	return run, nil
+}
+
+//changeSchedule locks schedule with id=id, lets transition check and change it, then saves it
+func (db *Database) changeSchedule(id int, transition func(schedule *models.Schedule, now time.Time) *models.CustomErr) (*models.Schedule, *models.CustomErr) {
+	tx := db.Db.Begin()
+	now := time.Now()
+
+	//changing the version locks the schedule and makes a concurrent run of it claim nothing
+	result := tx.Model(&models.Schedule{}).Where("schedule_id = ?", id).UpdateColumn("version", gorm.Expr("version + 1"))
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, models.InternalError(result.Error)
+	}
+	schedule, err := findSchedule(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = transition(schedule, now); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	schedule.UpdatedAt = now
+	if err = saveSchedule(tx, schedule); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	tx.Commit()
+	return schedule, nil
+}
+
+//saveSchedule saves the state of schedule
+func saveSchedule(tx *gorm.DB, schedule *models.Schedule) *models.CustomErr {
+	//stored in the same time zone as other timestamps so that SQLite can compare them as text
+	location := time.Now().Location()
+	result := tx.Model(&models.Schedule{ID: schedule.ID}).Updates(map[string]interface{}{
+		"status":        schedule.Status,
+		"attempt":       schedule.Attempt,
+		"occurrence_at": schedule.OccurrenceAt.In(location),
+		"next_run_at":   schedule.NextRunAt.In(location),
+		"updated_at":    schedule.UpdatedAt.In(location),
+	})
+	if result.Error != nil {
+		return models.InternalError(result.Error)
+	}
+	return nil
+}
+
+func findSchedule(tx *gorm.DB, id int) (*models.Schedule, *models.CustomErr) {
+	schedule := &models.Schedule{}
+	result := tx.Limit(1).Find(schedule, id)
+	if result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, models.NotFoundError("schedule", id)
+	}
+	return schedule, nil
+}
+
+//NewSchedule returns schedule of request created at now; the first run of a one-off schedule is at RunAt,
+//of a recurring one at the first time after now matching Cron (in UTC)
+func NewSchedule(request *models.ScheduleRequest, policy models.RetryPolicy, now time.Time) (*models.Schedule, *models.CustomErr) {
+	schedule := &models.Schedule{
+		FromID:        request.FromID,
+		ToID:          request.ToID,
+		Amount:        request.Amount,
+		Fee:           request.Fee,
+		Cron:          request.Cron,
+		Status:        models.ScheduleStatusActive,
+		MaxRetries:    policy.MaxRetries,
+		RetryInterval: int(policy.Interval / time.Second),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if request.MaxRetries != nil {
+		schedule.MaxRetries = *request.MaxRetries
+	}
+	if request.RetryInterval != nil {
+		schedule.RetryInterval = *request.RetryInterval
+	}
+
+	switch {
+	case request.RunAt != nil && request.Cron != "":
+		return nil, invalidSchedule("run_at and cron cannot be both set")
+	case request.RunAt != nil:
+		schedule.OccurrenceAt = request.RunAt.In(now.Location())
+	case request.Cron != "":
+		if _, err := cron.Parse(request.Cron); err != nil {
+			return nil, invalidSchedule(err.Error())
+		}
+		schedule.OccurrenceAt = nextOccurrence(request.Cron, now)
+		if schedule.OccurrenceAt.IsZero() {
+			return nil, invalidSchedule(fmt.Sprintf("cron [%s] never matches", request.Cron))
+		}
+	default:
+		return nil, invalidSchedule("run_at or cron must be set")
+	}
+	schedule.NextRunAt = schedule.OccurrenceAt
+	return schedule, nil
+}
+
+//ScheduleTransfer returns the transfer made by a run of schedule
+func ScheduleTransfer(schedule *models.Schedule) *models.TransferRequest {
+	return &models.TransferRequest{ID1: schedule.FromID, ID2: schedule.ToID, Delta: schedule.Amount, Fee: schedule.Fee}
+}
+
+//FinishRun returns the run of schedule made at now that ended with transaction or err and moves schedule to
+//its next run: a failed run is retried after RetryInterval up to MaxRetries times, then the occurrence is skipped.
+//A one-off schedule is completed after its last run; a recurring one skips occurrences missed while the service
+//was down, so that only the overdue one is made
+func FinishRun(schedule *models.Schedule, transaction *models.Transaction, err *models.CustomErr, now time.Time) *models.ScheduleRun {
+	run := &models.ScheduleRun{
+		ScheduleID:   schedule.ID,
+		OccurrenceAt: schedule.OccurrenceAt.In(now.Location()),
+		Attempt:      schedule.Attempt + 1,
+		Status:       models.ScheduleRunSucceeded,
+		CreatedAt:    now,
+	}
+	schedule.UpdatedAt = now
+	if err == nil {
+		transactionID := transaction.ID
+		run.TransactionID = &transactionID
+		nextOccurrenceOf(schedule, now)
+		return run
+	}
+
+	errorCode := err.ErrorCode
+	run.Status = models.ScheduleRunFailed
+	run.ErrorCode = &errorCode
+	run.Error = err.Message()
+	if schedule.Attempt < schedule.MaxRetries {
+		schedule.Attempt++
+		schedule.NextRunAt = now.Add(time.Duration(schedule.RetryInterval) * time.Second)
+		retryAt := schedule.NextRunAt
+		run.RetryAt = &retryAt
+		return run
+	}
+	nextOccurrenceOf(schedule, now)
+	return run
+}
+
+//PauseSchedule pauses active schedule
+func PauseSchedule(schedule *models.Schedule, now time.Time) *models.CustomErr {
+	if schedule.Status != models.ScheduleStatusActive {
+		return scheduleStatus(schedule, "is not active")
+	}
+	schedule.Status = models.ScheduleStatusPaused
+	return nil
+}
+
+//ResumeSchedule makes paused schedule active; a recurring schedule skips occurrences missed while it was paused
+func ResumeSchedule(schedule *models.Schedule, now time.Time) *models.CustomErr {
+	if schedule.Status != models.ScheduleStatusPaused {
+		return scheduleStatus(schedule, "is not paused")
+	}
+	schedule.Status = models.ScheduleStatusActive
+	if schedule.Cron != "" && schedule.OccurrenceAt.Before(now) {
+		schedule.Attempt = 0
+		schedule.OccurrenceAt = nextOccurrence(schedule.Cron, now)
+		schedule.NextRunAt = schedule.OccurrenceAt
+	}
+	return nil
+}
+
+//DeleteSchedule deletes schedule unless it is already deleted
+func DeleteSchedule(schedule *models.Schedule, now time.Time) *models.CustomErr {
+	if schedule.Status == models.ScheduleStatusDeleted {
+		return scheduleStatus(schedule, "is already deleted")
+	}
+	schedule.Status = models.ScheduleStatusDeleted
+	return nil
+}
+
+//nextOccurrenceOf moves schedule to its next occurrence or completes it if there is none
+func nextOccurrenceOf(schedule *models.Schedule, now time.Time) {
+	schedule.Attempt = 0
+	next := time.Time{}
+	if schedule.Cron != "" {
+		next = nextOccurrence(schedule.Cron, schedule.OccurrenceAt)
+		if next.Before(now) {
+			next = nextOccurrence(schedule.Cron, now)
+		}
+	}
+	if next.IsZero() {
+		schedule.Status = models.ScheduleStatusCompleted
+		return
+	}
+	schedule.OccurrenceAt = next
+	schedule.NextRunAt = next
+}
+
+//nextOccurrence returns the first time after t matching cron expression spec in UTC; zero time if there is none
+func nextOccurrence(spec string, t time.Time) time.Time {
+	expression, err := cron.Parse(spec)
+	if err != nil {
+		return time.Time{}
+	}
+	next := expression.Next(t.UTC())
+	if next.IsZero() {
+		return next
+	}
+	return next.In(time.Now().Location())
+}
+
+func invalidSchedule(message string) *models.CustomErr {
+	return models.NewErrorf(models.ErrorScheduleInvalidCode, "schedule not valid: %s", message)
+}
+
+func scheduleStatus(schedule *models.Schedule, message string) *models.CustomErr {
+	return models.NewErrorf(models.ErrorScheduleStatusCode,
+		"schedule [%v] %s: it is %s", schedule.ID, message, schedule.Status)
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestSQLiteScheduleRuns(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 10000})
+
+	runAt := time.Now().Add(-time.Minute)
+	schedule, cErr := db.CreateSchedule(ctx, &models.ScheduleRequest{FromID: 1, ToID: 2, Amount: 3000, RunAt: &runAt})
+	assert.Equal(t, cErr == nil, true)
+	runs, cErr := db.RunDueSchedules(ctx)
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, runs, 1)
+
+	//a finished run is not made again
+	runs, _ = db.RunDueSchedules(ctx)
+	assert.Equal(t, runs, 0)
+	account, _ := db.GetBalance(ctx, 1)
+	assert.Equal(t, account.Balance, models.Money(7000))
+
+	schedule, _ = db.GetSchedule(ctx, schedule.ID)
+	assert.Equal(t, schedule.Status, models.ScheduleStatusCompleted)
+	history, _ := db.GetScheduleRuns(ctx, schedule.ID)
+	assert.Equal(t, len(history), 1)
+	assert.Equal(t, history[0].Status, models.ScheduleRunSucceeded)
+	assert.Equal(t, history[0].TransactionID != nil, true)
+
+	_, cErr = db.PauseSchedule(ctx, schedule.ID)
+	assert.Equal(t, cErr.ErrorCode, models.ErrorScheduleStatusCode)
+	_, cErr = db.GetSchedule(ctx, 100)
+	assert.Equal(t, cErr.ErrorCode, models.ErrorNotFoundCode)
+}
+
+func TestSQLiteScheduleRetry(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	maxRetries, retryInterval := 1, 60
+
+	runAt := time.Now().Add(-time.Minute)
+	schedule, _ := db.CreateSchedule(ctx, &models.ScheduleRequest{FromID: 1, ToID: 2, Amount: 3000, RunAt: &runAt,
+		MaxRetries: &maxRetries, RetryInterval: &retryInterval})
+	runs, _ := db.RunDueSchedules(ctx)
+	assert.Equal(t, runs, 1)
+
+	history, _ := db.GetScheduleRuns(ctx, schedule.ID)
+	assert.Equal(t, history[0].Status, models.ScheduleRunFailed)
+	assert.Equal(t, *history[0].ErrorCode, models.ErrorInsufficientFundsCode)
+	assert.Equal(t, history[0].RetryAt != nil, true)
+	schedule, _ = db.GetSchedule(ctx, schedule.ID)
+	assert.Equal(t, schedule.Status, models.ScheduleStatusActive)
+	assert.Equal(t, schedule.Attempt, 1)
+
+	//the retry is not due yet
+	runs, _ = db.RunDueSchedules(ctx)
+	assert.Equal(t, runs, 0)
+
+	//a worker holding the schedule as it was before the run claims nothing
+	stale := *schedule
+	stale.Version--
+	run, cErr := db.runSchedule(&stale, time.Now())
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, run == nil, true)
+
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 5000})
+	db.Db.Model(&models.Schedule{ID: schedule.ID}).Update("next_run_at", runAt)
+	runs, _ = db.RunDueSchedules(ctx)
+	assert.Equal(t, runs, 1)
+
+	history, _ = db.GetScheduleRuns(ctx, schedule.ID)
+	assert.Equal(t, len(history), 2)
+	assert.Equal(t, history[1].Status, models.ScheduleRunSucceeded)
+	assert.Equal(t, history[1].Attempt, 2)
+	account, _ := db.GetBalance(ctx, 1)
+	assert.Equal(t, account.Balance, models.Money(2000))
+}
+
+func TestSQLiteScheduleStatus(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+
+	schedule, cErr := db.CreateSchedule(ctx, &models.ScheduleRequest{FromID: 1, ToID: 2, Amount: 3000, Cron: "0 9 1 * *"})
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, schedule.OccurrenceAt.After(time.Now()), true)
+
+	_, cErr = db.ResumeSchedule(ctx, schedule.ID)
+	assert.Equal(t, cErr.ErrorCode, models.ErrorScheduleStatusCode)
+	schedule, _ = db.PauseSchedule(ctx, schedule.ID)
+	assert.Equal(t, schedule.Status, models.ScheduleStatusPaused)
+	schedule, _ = db.ResumeSchedule(ctx, schedule.ID)
+	assert.Equal(t, schedule.Status, models.ScheduleStatusActive)
+
+	schedules, _ := db.GetSchedules(ctx, 1)
+	assert.Equal(t, len(schedules), 1)
+	_, cErr = db.DeleteSchedule(ctx, schedule.ID)
+	assert.Equal(t, cErr == nil, true)
+	schedules, _ = db.GetSchedules(ctx, 1)
+	assert.Equal(t, len(schedules), 0)
+}
+
+func TestNewSchedule(t *testing.T) {
+	now := time.Now()
+	policy := models.RetryPolicy{MaxRetries: 2, Interval: time.Minute}
+
+	_, cErr := NewSchedule(&models.ScheduleRequest{FromID: 1, ToID: 2, Amount: 100, Cron: "0 0 30 2 *"}, policy, now)
+	assert.Equal(t, cErr.ErrorCode, models.ErrorScheduleInvalidCode)
+	_, cErr = NewSchedule(&models.ScheduleRequest{FromID: 1, ToID: 2, Amount: 100, Cron: "@daily", RunAt: &now}, policy, now)
+	assert.Equal(t, cErr.ErrorCode, models.ErrorScheduleInvalidCode)
+
+	schedule, cErr := NewSchedule(&models.ScheduleRequest{FromID: 1, ToID: 2, Amount: 100, Cron: "@daily"}, policy, now)
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, schedule.MaxRetries, 2)
+	assert.Equal(t, schedule.RetryInterval, 60)
+
+	//occurrences missed while the service was down are skipped
+	schedule.OccurrenceAt = now.AddDate(0, 0, -10)
+	FinishRun(schedule, &models.Transaction{ID: 1}, nil, now)
+	assert.Equal(t, schedule.OccurrenceAt.After(now), true)
+	assert.Equal(t, schedule.OccurrenceAt.Sub(now) <= 24*time.Hour, true)
+	assert.Equal(t, schedule.Status, models.ScheduleStatusActive)
+}
@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+//openSQLite opens SQLite database file ConnString
+func (db *Database) openSQLite() error {
+	var err error
+	db.Db, err = gorm.Open(sqlite.Open(db.ConnString), &gorm.Config{})
+	if err != nil {
+		return err
+	}
+
+	sqlDb, err := db.Db.DB()
+	if err != nil {
+		return err
+	}
+	//SQLite allows a single writer; one connection serializes transactions instead of failing with SQLITE_BUSY
+	sqlDb.SetMaxOpenConns(1)
+
+	return db.Db.Exec("PRAGMA foreign_keys = ON").Error
+}
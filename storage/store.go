@@ -1,11 +1,52 @@
 package storage
 
-import "github.com/dalconoid/balance-service/models"
+import (
+	"context"
+	"github.com/dalconoid/balance-service/models"
+	"time"
+)
 
-//Store is a service data storage interface
+//go:generate mockgen -destination mock/store.go -package mockdb github.com/dalconoid/balance-service/storage Store
+
+//Store is a service data storage interface; operations stop when ctx is done and return ErrorCanceledCode
 type Store interface {
-	GetBalance(id int) (*models.Account, *models.CustomErr)
-	GetTransactionHistory(accId int, sorting string, order string, page int) ([]models.Transaction, *models.CustomErr)
-	UpdateBalance(request *models.ChangeBalanceRequest) (*models.Transaction, *models.CustomErr)
-	MakeTransfer(request *models.TransferRequest) (*models.Transaction, *models.CustomErr)
-}
\ No newline at end of file
+	GetBalance(ctx context.Context, id int) (*models.Account, *models.CustomErr)
+	GetBalanceAsOf(ctx context.Context, id int, asOf time.Time) (*models.BalanceAsOf, *models.CustomErr)
+	GetAccountStatusHistory(ctx context.Context, id int) ([]models.AccountStatusChange, *models.CustomErr)
+	GetSystemAccounts(ctx context.Context) ([]models.SystemAccount, *models.CustomErr)
+	GetTransactionHistory(ctx context.Context, request *models.HistoryRequest) (*models.HistoryPage, *models.CustomErr)
+	StreamTransactionHistory(ctx context.Context, request *models.HistoryRequest, fn func(*models.Transaction) error) *models.CustomErr
+	UpdateBalance(ctx context.Context, request *models.ChangeBalanceRequest) (*models.Transaction, *models.CustomErr)
+	MakeTransfer(ctx context.Context, request *models.TransferRequest) (*models.Transaction, *models.CustomErr)
+	MakeBatchTransfer(ctx context.Context, request *models.BatchTransferRequest) ([]models.Transaction, *models.CustomErr)
+	ReverseTransaction(ctx context.Context, request *models.ReverseRequest) ([]models.Transaction, *models.CustomErr)
+	FreezeAccount(ctx context.Context, request *models.FreezeAccountRequest) (*models.Account, *models.CustomErr)
+	UnfreezeAccount(ctx context.Context, request *models.UnfreezeAccountRequest) (*models.Account, *models.CustomErr)
+	CloseAccount(ctx context.Context, request *models.CloseAccountRequest) (*models.Account, *models.CustomErr)
+	SetCreditLimit(ctx context.Context, request *models.CreditLimitRequest) (*models.Account, *models.CustomErr)
+	GetSpendingLimits(ctx context.Context, id int) (*models.SpendingLimits, *models.CustomErr)
+	SetSpendingLimits(ctx context.Context, limits *models.SpendingLimits) (*models.SpendingLimits, *models.CustomErr)
+	GetHold(ctx context.Context, id int) (*models.Hold, *models.CustomErr)
+	PlaceHold(ctx context.Context, request *models.HoldRequest) (*models.Hold, *models.CustomErr)
+	CaptureHold(ctx context.Context, request *models.CaptureHoldRequest) (*models.Transaction, *models.CustomErr)
+	ReleaseHold(ctx context.Context, id int) (*models.Hold, *models.CustomErr)
+	ReleaseExpiredHolds(ctx context.Context) (int, *models.CustomErr)
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int, *models.CustomErr)
+	CreateSchedule(ctx context.Context, request *models.ScheduleRequest) (*models.Schedule, *models.CustomErr)
+	GetSchedule(ctx context.Context, id int) (*models.Schedule, *models.CustomErr)
+	GetSchedules(ctx context.Context, id int) ([]models.Schedule, *models.CustomErr)
+	GetScheduleRuns(ctx context.Context, id int) ([]models.ScheduleRun, *models.CustomErr)
+	PauseSchedule(ctx context.Context, id int) (*models.Schedule, *models.CustomErr)
+	ResumeSchedule(ctx context.Context, id int) (*models.Schedule, *models.CustomErr)
+	DeleteSchedule(ctx context.Context, id int) (*models.Schedule, *models.CustomErr)
+	RunDueSchedules(ctx context.Context) (int, *models.CustomErr)
+	CreateWebhook(ctx context.Context, request *models.WebhookRequest) (*models.Webhook, *models.CustomErr)
+	GetWebhooks(ctx context.Context) ([]models.Webhook, *models.CustomErr)
+	DeleteWebhook(ctx context.Context, id int) (*models.Webhook, *models.CustomErr)
+	GetWebhookDeliveries(ctx context.Context, id int) ([]models.WebhookDelivery, *models.CustomErr)
+	RedeliverWebhookDelivery(ctx context.Context, id int) (*models.WebhookDelivery, *models.CustomErr)
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.DeliveryTask, *models.CustomErr)
+	FinishWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) *models.CustomErr
+	GetOutboxEvents(ctx context.Context, after models.OutboxPosition, limit int) ([]models.OutboxEvent, *models.CustomErr)
+	LastOutboxPosition(ctx context.Context) (models.OutboxPosition, *models.CustomErr)
+}
@@ -0,0 +1,323 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/dalconoid/balance-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+//fanOutBatch is the number of outbox events fanned out to webhooks at once
+const fanOutBatch = 500
+
+//CreateWebhook registers a webhook
+func (db *Database) CreateWebhook(ctx context.Context, request *models.WebhookRequest) (_ *models.Webhook, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "CreateWebhook")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	webhook := NewWebhook(request, time.Now())
+	if result := db.Db.Create(webhook); result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	return webhook, nil
+}
+
+//GetWebhooks returns all webhooks in the order they were registered
+func (db *Database) GetWebhooks(ctx context.Context) (_ []models.Webhook, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "GetWebhooks")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	webhooks := make([]models.Webhook, 0)
+	if result := db.Db.Order("webhook_id").Find(&webhooks); result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	return webhooks, nil
+}
+
+//DeleteWebhook deletes webhook with id=id together with its deliveries
+func (db *Database) DeleteWebhook(ctx context.Context, id int) (_ *models.Webhook, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "DeleteWebhook")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	webhook, err := findWebhook(db.Db, id)
+	if err != nil {
+		return nil, err
+	}
+	if result := db.Db.Delete(webhook); result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	return webhook, nil
+}
+
+//GetWebhookDeliveries returns deliveries of webhook with id=id in the order they were made
+func (db *Database) GetWebhookDeliveries(ctx context.Context, id int) (_ []models.WebhookDelivery, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "GetWebhookDeliveries")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	if _, err := findWebhook(db.Db, id); err != nil {
+		return nil, err
+	}
+	deliveries := make([]models.WebhookDelivery, 0)
+	if result := db.Db.Where("webhook_id = ?", id).Order("delivery_id").Find(&deliveries); result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	return deliveries, nil
+}
+
+//RedeliverWebhookDelivery makes delivery with id=id pending again with no attempts made, whatever its status
+func (db *Database) RedeliverWebhookDelivery(ctx context.Context, id int) (_ *models.WebhookDelivery, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "RedeliverWebhookDelivery")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	now := time.Now()
+	result := db.Db.Model(&models.WebhookDelivery{}).Where("delivery_id = ?", id).Updates(map[string]interface{}{
+		"status":          models.DeliveryStatusPending,
+		"attempts":        0,
+		"next_attempt_at": now,
+		"version":         gorm.Expr("version + 1"),
+		"updated_at":      now,
+	})
+	if result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, deliveryNotFound(id)
+	}
+	return findDelivery(db.Db, id)
+}
+
+//ClaimWebhookDeliveries fans new outbox events out to webhooks and claims up to limit pending deliveries due
+//by now for an attempt. A claimed delivery is not due again until lease passes, so that another dispatcher
+//makes the attempt if this one never finishes it
+func (db *Database) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) (_ []models.DeliveryTask, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "ClaimWebhookDeliveries")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	now := time.Now()
+	if err := db.fanOutEvents(now); err != nil {
+		return nil, err
+	}
+
+	due := make([]models.WebhookDelivery, 0)
+	result := db.Db.Where("status = ? AND next_attempt_at <= ?", models.DeliveryStatusPending, now).
+		Order("next_attempt_at").Limit(limit).Find(&due)
+	if result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+
+	tasks := make([]models.DeliveryTask, 0, len(due))
+	for i := range due {
+		//claiming changes the version, so the attempt is made by one dispatcher
+		result = db.Db.Model(&models.WebhookDelivery{}).Where("delivery_id = ? AND version = ?", due[i].ID, due[i].Version).
+			Updates(map[string]interface{}{"version": gorm.Expr("version + 1"), "next_attempt_at": now.Add(lease)})
+		if result.Error != nil {
+			return nil, models.InternalError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		due[i].Version++
+
+		task := models.DeliveryTask{Delivery: due[i]}
+		if result = db.Db.Take(&task.Webhook, due[i].WebhookID); result.Error != nil {
+			return nil, models.InternalError(result.Error)
+		}
+		if result = db.Db.Take(&task.Event, due[i].EventID); result.Error != nil {
+			return nil, models.InternalError(result.Error)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+//FinishWebhookDelivery saves the result of an attempt of claimed delivery; the result is dropped if the delivery
+//was redelivered or claimed again meanwhile
+func (db *Database) FinishWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) (cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "FinishWebhookDelivery")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	//stored in the same time zone as other timestamps so that SQLite can compare them as text
+	location := time.Now().Location()
+	updates := map[string]interface{}{
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"next_attempt_at": delivery.NextAttemptAt.In(location),
+		"status_code":     delivery.StatusCode,
+		"error":           delivery.Error,
+		"updated_at":      delivery.UpdatedAt.In(location),
+		"delivered_at":    nil,
+	}
+	if delivery.DeliveredAt != nil {
+		updates["delivered_at"] = delivery.DeliveredAt.In(location)
+	}
+	result := db.Db.Model(&models.WebhookDelivery{}).Where("delivery_id = ? AND version = ?", delivery.ID, delivery.Version).
+		Updates(updates)
+	if result.Error != nil {
+		return models.InternalError(result.Error)
+	}
+	return nil
+}
+
+//postgresSettled selects outbox events of finished database transactions: a transaction older than the oldest
+//running one is committed or rolled back, and every transaction yet to write an event is newer
+const postgresSettled = "txid < txid_snapshot_xmin(txid_current_snapshot())"
+
+//GetOutboxEvents returns up to limit outbox events after position after in the order of positions. No event can be
+//committed before the returned ones later: SQLite commits in the order of event ids, while Postgres commits
+//concurrently, so there it returns only events of database transactions older than all running ones
+func (db *Database) GetOutboxEvents(ctx context.Context, after models.OutboxPosition, limit int) (_ []models.OutboxEvent, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "GetOutboxEvents")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	query := db.Db.Where("event_id > ?", after.EventID).Order("event_id")
+	if db.Driver != models.DriverSQLite {
+		query = db.Db.Where("(txid, event_id) > (?, ?) AND "+postgresSettled, after.TxID, after.EventID).
+			Order("txid, event_id")
+	}
+	events := make([]models.OutboxEvent, 0)
+	if result := query.Limit(limit).Find(&events); result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	return events, nil
+}
+
+//LastOutboxPosition returns the position of the last outbox event GetOutboxEvents can return now; zero if there are none
+func (db *Database) LastOutboxPosition(ctx context.Context) (_ models.OutboxPosition, cErr *models.CustomErr) {
+	db, cancel := db.session(ctx, "LastOutboxPosition")
+	defer cancel()
+	defer db.canceled(&cErr)
+
+	query := db.Db.Order("event_id DESC")
+	if db.Driver != models.DriverSQLite {
+		query = db.Db.Where(postgresSettled).Order("txid DESC, event_id DESC")
+	}
+	event := &models.OutboxEvent{}
+	if result := query.Limit(1).Find(event); result.Error != nil {
+		return models.OutboxPosition{}, models.InternalError(result.Error)
+	}
+	return event.Position(), nil
+}
+
+//fanOutEvents creates deliveries of new outbox events to the webhooks that want them
+func (db *Database) fanOutEvents(now time.Time) *models.CustomErr {
+	tx := db.Db.Begin()
+
+	events := make([]models.OutboxEvent, 0)
+	if result := tx.Where("dispatched = ?", false).Order("event_id").Limit(fanOutBatch).Find(&events); result.Error != nil {
+		tx.Rollback()
+		return models.InternalError(result.Error)
+	}
+	if len(events) == 0 {
+		tx.Rollback()
+		return nil
+	}
+	webhooks := make([]models.Webhook, 0)
+	if result := tx.Find(&webhooks); result.Error != nil {
+		tx.Rollback()
+		return models.InternalError(result.Error)
+	}
+
+	ids := make([]int, 0, len(events))
+	for i := range events {
+		ids = append(ids, events[i].ID)
+		deliveries := NewDeliveries(webhooks, &events[i], now)
+		if len(deliveries) == 0 {
+			continue
+		}
+		//a concurrent dispatcher may have fanned the event out already
+		if result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries); result.Error != nil {
+			tx.Rollback()
+			return models.InternalError(result.Error)
+		}
+	}
+	if result := tx.Model(&models.OutboxEvent{}).Where("event_id IN ?", ids).Update("dispatched", true); result.Error != nil {
+		tx.Rollback()
+		return models.InternalError(result.Error)
+	}
+
+	if result := tx.Commit(); result.Error != nil {
+		return models.InternalError(result.Error)
+	}
+	return nil
+}
+
+func findWebhook(tx *gorm.DB, id int) (*models.Webhook, *models.CustomErr) {
+	webhook := &models.Webhook{}
+	result := tx.Limit(1).Find(webhook, id)
+	if result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, models.NotFoundError("webhook", id)
+	}
+	return webhook, nil
+}
+
+func findDelivery(tx *gorm.DB, id int) (*models.WebhookDelivery, *models.CustomErr) {
+	delivery := &models.WebhookDelivery{}
+	result := tx.Limit(1).Find(delivery, id)
+	if result.Error != nil {
+		return nil, models.InternalError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, deliveryNotFound(id)
+	}
+	return delivery, nil
+}
+
+//NewWebhook returns webhook of request registered at now
+func NewWebhook(request *models.WebhookRequest, now time.Time) *models.Webhook {
+	return &models.Webhook{URL: request.URL, Secret: request.Secret, AccountID: request.AccountID, CreatedAt: now}
+}
+
+//BalanceEvents returns balance.changed events of transactions posted at now; system accounts have no events
+func BalanceEvents(transactions []models.Transaction, now time.Time) []models.OutboxEvent {
+	events := make([]models.OutboxEvent, 0, len(transactions))
+	for i := range transactions {
+		if models.IsSystemAccount(transactions[i].AccountID) {
+			continue
+		}
+		payload, _ := json.Marshal(&transactions[i])
+		events = append(events, models.OutboxEvent{
+			Type:          models.EventTypeBalanceChanged,
+			AccountID:     transactions[i].AccountID,
+			TransactionID: transactions[i].ID,
+			Payload:       string(payload),
+			CreatedAt:     now,
+		})
+	}
+	return events
+}
+
+//NewDeliveries returns pending deliveries of event to those of webhooks that want it
+func NewDeliveries(webhooks []models.Webhook, event *models.OutboxEvent, now time.Time) []models.WebhookDelivery {
+	deliveries := make([]models.WebhookDelivery, 0)
+	for i := range webhooks {
+		if webhooks[i].AccountID != nil && *webhooks[i].AccountID != event.AccountID {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			WebhookID:     webhooks[i].ID,
+			EventID:       event.ID,
+			Status:        models.DeliveryStatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+	return deliveries
+}
+
+func deliveryNotFound(id int) *models.CustomErr {
+	return models.NotFoundError("webhook delivery", id)
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/magiconair/properties/assert"
+)
+
+func TestSQLiteOutboxEvents(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	db.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 5000})
+	webhook, cErr := db.CreateWebhook(ctx, &models.WebhookRequest{URL: "http://localhost/hook", Secret: "0123456789abcdef"})
+	assert.Equal(t, cErr == nil, true)
+
+	//a failed transfer writes no events; the transfer with a fee changes account 1 twice
+	_, cErr = db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 6000})
+	assert.Equal(t, cErr.ErrorCode, models.ErrorInsufficientFundsCode)
+	_, cErr = db.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 2000, Fee: 100})
+	assert.Equal(t, cErr == nil, true)
+
+	var events int64
+	db.Db.Model(&models.OutboxEvent{}).Count(&events)
+	assert.Equal(t, events, int64(4))
+	outbox, cErr := db.GetOutboxEvents(ctx, models.OutboxPosition{EventID: 2}, 10)
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, len(outbox), 2)
+	last, _ := db.LastOutboxPosition(ctx)
+	assert.Equal(t, last.EventID, outbox[1].ID)
+
+	tasks, cErr := db.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, len(tasks), 4)
+	assert.Equal(t, tasks[0].Webhook.Secret, "0123456789abcdef")
+	assert.Equal(t, tasks[1].Event.AccountID, 1)
+	assert.Equal(t, tasks[2].Event.AccountID, 2)
+
+	//claimed deliveries are not claimed again until the lease passes
+	again, _ := db.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	assert.Equal(t, len(again), 0)
+
+	delivered := tasks[0].Delivery
+	now := time.Now()
+	delivered.Status = models.DeliveryStatusDelivered
+	delivered.Attempts = 1
+	delivered.DeliveredAt = &now
+	assert.Equal(t, db.FinishWebhookDelivery(ctx, &delivered) == nil, true)
+
+	//a redelivered delivery drops the result of the attempt claimed before
+	_, cErr = db.RedeliverWebhookDelivery(ctx, tasks[1].Delivery.ID)
+	assert.Equal(t, cErr == nil, true)
+	failed := tasks[1].Delivery
+	failed.Status = models.DeliveryStatusFailed
+	db.FinishWebhookDelivery(ctx, &failed)
+
+	deliveries, _ := db.GetWebhookDeliveries(ctx, webhook.ID)
+	assert.Equal(t, deliveries[0].Status, models.DeliveryStatusDelivered)
+	assert.Equal(t, deliveries[0].DeliveredAt != nil, true)
+	assert.Equal(t, deliveries[1].Status, models.DeliveryStatusPending)
+
+	again, _ = db.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	assert.Equal(t, len(again), 1)
+	assert.Equal(t, again[0].Delivery.ID, tasks[1].Delivery.ID)
+
+	_, cErr = db.DeleteWebhook(ctx, webhook.ID)
+	assert.Equal(t, cErr == nil, true)
+	_, cErr = db.GetWebhookDeliveries(ctx, webhook.ID)
+	assert.Equal(t, cErr.ErrorCode, models.ErrorNotFoundCode)
+}
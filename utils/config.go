@@ -2,16 +2,36 @@ package utils
 
 import (
 	"fmt"
+	"github.com/dalconoid/balance-service/models"
 	"github.com/spf13/viper"
 	"path"
 	"strings"
+	"time"
 )
 
 //Config - application config
 type Config struct {
-	ServerAddress      string
-	DBConnectionString string
-	PaginationNumber   int
+	ServerAddress            string
+	GRPCAddress              string
+	DBDriver                 string
+	DBConnectionString       string
+	DBTimeouts               models.Timeouts
+	PaginationNumber         int
+	IdempotencyRetention     time.Duration
+	IdempotencySweepInterval time.Duration
+	HoldSweepInterval        time.Duration
+	ScheduleInterval         time.Duration
+	ScheduleRetryPolicy      models.RetryPolicy
+	WebhookInterval          time.Duration
+	WebhookTimeout           time.Duration
+	WebhookMaxAttempts       int
+	WebhookBackoff           time.Duration
+	WebhookMaxBackoff        time.Duration
+	EventsPollInterval       time.Duration
+	EventsBuffer             int
+	EventsHeartbeat          time.Duration
+	ShutdownDrainDelay       time.Duration
+	ShutdownGracePeriod      time.Duration
 }
 
 //LoadConfig loads config from path=p
@@ -35,6 +55,15 @@ func LoadConfig(p string) (*Config, error) {
 
 	config := Config{}
 
+	viper.SetDefault("DB.DRIVER", models.DriverPostgres)
+	config.DBDriver = strings.ToLower(viper.GetString("DB.DRIVER"))
+	switch config.DBDriver {
+	case models.DriverPostgres, models.DriverSQLite, models.DriverMemory:
+	default:
+		return nil, fmt.Errorf("config: DB.DRIVER not valid: valid options are [%s], [%s], [%s]",
+			models.DriverPostgres, models.DriverSQLite, models.DriverMemory)
+	}
+
 	viper.SetDefault("DB.HOST", "localhost")
 	viper.SetDefault("DB.USER", "postgres")
 	viper.SetDefault("DB.PASSWORD", "password")
@@ -48,13 +77,93 @@ func LoadConfig(p string) (*Config, error) {
 	dbPort := viper.Get("DB.PORT")
 	dbSSL := viper.Get("DB.SSL")
 	config.DBConnectionString = fmt.Sprintf("host=%v user=%v password=%v dbname=%v port=%v sslmode=%v", dbHost, dbUser, dbPwd, dbName, dbPort, dbSSL)
+	if config.DBDriver == models.DriverSQLite {
+		viper.SetDefault("DB.PATH", "balance.db")
+		config.DBConnectionString = viper.GetString("DB.PATH")
+	}
+
+	//operations reading or writing many rows get more time than the default
+	viper.SetDefault("DB.TIMEOUT", "10s")
+	viper.SetDefault("DB.OPERATION_TIMEOUTS", map[string]string{
+		"StreamTransactionHistory": "5m",
+		"MakeBatchTransfer":        "1m",
+		"ReleaseExpiredHolds":      "1m",
+		"RunDueSchedules":          "1m",
+		"ClaimWebhookDeliveries":   "1m",
+	})
+	config.DBTimeouts = models.Timeouts{
+		Default:    viper.GetDuration("DB.TIMEOUT"),
+		Operations: make(map[string]time.Duration),
+	}
+	for operation, value := range viper.GetStringMapString("DB.OPERATION_TIMEOUTS") {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("config: DB.OPERATION_TIMEOUTS.%s not valid: must be a non-negative duration like [30s]", operation)
+		}
+		config.DBTimeouts.Operations[operation] = timeout
+	}
+	if config.DBTimeouts.Default < 0 {
+		return nil, fmt.Errorf("config: DB.TIMEOUT must be non-negative")
+	}
 
 	viper.SetDefault("SERVER.PORT", "8080")
 	srvPort := viper.Get("SERVER.PORT")
 	config.ServerAddress = fmt.Sprintf(":%v", srvPort)
 
+	viper.SetDefault("GRPC.PORT", "9090")
+	grpcPort := viper.Get("GRPC.PORT")
+	config.GRPCAddress = fmt.Sprintf(":%v", grpcPort)
+
 	viper.SetDefault("SETTINGS.PAGINATION_NUM", 10)
 	config.PaginationNumber = viper.GetInt("SETTINGS.PAGINATION_NUM")
 
+	viper.SetDefault("SETTINGS.IDEMPOTENCY_RETENTION", "24h")
+	config.IdempotencyRetention = viper.GetDuration("SETTINGS.IDEMPOTENCY_RETENTION")
+
+	viper.SetDefault("SETTINGS.IDEMPOTENCY_SWEEP_INTERVAL", "10m")
+	config.IdempotencySweepInterval = viper.GetDuration("SETTINGS.IDEMPOTENCY_SWEEP_INTERVAL")
+
+	viper.SetDefault("SETTINGS.HOLD_SWEEP_INTERVAL", "1m")
+	config.HoldSweepInterval = viper.GetDuration("SETTINGS.HOLD_SWEEP_INTERVAL")
+
+	viper.SetDefault("SETTINGS.SCHEDULE_INTERVAL", "1m")
+	config.ScheduleInterval = viper.GetDuration("SETTINGS.SCHEDULE_INTERVAL")
+
+	viper.SetDefault("SETTINGS.SCHEDULE_MAX_RETRIES", 3)
+	viper.SetDefault("SETTINGS.SCHEDULE_RETRY_INTERVAL", "1h")
+	config.ScheduleRetryPolicy = models.RetryPolicy{
+		MaxRetries: viper.GetInt("SETTINGS.SCHEDULE_MAX_RETRIES"),
+		Interval:   viper.GetDuration("SETTINGS.SCHEDULE_RETRY_INTERVAL"),
+	}
+
+	viper.SetDefault("WEBHOOKS.INTERVAL", "5s")
+	viper.SetDefault("WEBHOOKS.TIMEOUT", "10s")
+	viper.SetDefault("WEBHOOKS.MAX_ATTEMPTS", 10)
+	viper.SetDefault("WEBHOOKS.BACKOFF", "10s")
+	viper.SetDefault("WEBHOOKS.MAX_BACKOFF", "1h")
+	config.WebhookInterval = viper.GetDuration("WEBHOOKS.INTERVAL")
+	config.WebhookTimeout = viper.GetDuration("WEBHOOKS.TIMEOUT")
+	config.WebhookMaxAttempts = viper.GetInt("WEBHOOKS.MAX_ATTEMPTS")
+	config.WebhookBackoff = viper.GetDuration("WEBHOOKS.BACKOFF")
+	config.WebhookMaxBackoff = viper.GetDuration("WEBHOOKS.MAX_BACKOFF")
+
+	viper.SetDefault("EVENTS.POLL_INTERVAL", "250ms")
+	viper.SetDefault("EVENTS.BUFFER", 64)
+	viper.SetDefault("EVENTS.HEARTBEAT", "15s")
+	config.EventsPollInterval = viper.GetDuration("EVENTS.POLL_INTERVAL")
+	config.EventsBuffer = viper.GetInt("EVENTS.BUFFER")
+	config.EventsHeartbeat = viper.GetDuration("EVENTS.HEARTBEAT")
+	if config.EventsPollInterval <= 0 || config.EventsBuffer <= 0 || config.EventsHeartbeat <= 0 {
+		return nil, fmt.Errorf("config: EVENTS.POLL_INTERVAL, EVENTS.BUFFER and EVENTS.HEARTBEAT must be positive")
+	}
+
+	viper.SetDefault("SHUTDOWN.DRAIN_DELAY", "5s")
+	viper.SetDefault("SHUTDOWN.GRACE_PERIOD", "30s")
+	config.ShutdownDrainDelay = viper.GetDuration("SHUTDOWN.DRAIN_DELAY")
+	config.ShutdownGracePeriod = viper.GetDuration("SHUTDOWN.GRACE_PERIOD")
+	if config.ShutdownDrainDelay < 0 || config.ShutdownGracePeriod < 0 {
+		return nil, fmt.Errorf("config: SHUTDOWN.DRAIN_DELAY and SHUTDOWN.GRACE_PERIOD must be non-negative")
+	}
+
 	return &config, nil
 }
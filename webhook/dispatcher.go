@@ -0,0 +1,160 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage"
+)
+
+const (
+	//SignatureHeader carries "sha256=" and hex HMAC-SHA256 of the request body keyed with the webhook secret
+	SignatureHeader = "X-Webhook-Signature"
+	//EventHeader carries the event type
+	EventHeader = "X-Webhook-Event"
+	//DeliveryHeader carries the delivery id; it is the same for every attempt of a delivery
+	DeliveryHeader = "X-Webhook-Delivery"
+
+	//maxErrorBody is how much of a failed response is kept in the delivery log
+	maxErrorBody = 512
+)
+
+//Payload is the body posted to webhooks
+type Payload struct {
+	ID        int             `json:"id"`
+	Type      string          `json:"type"`
+	AccountID int             `json:"account_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+//Dispatcher delivers outbox events to webhooks; a failed attempt is retried after Backoff doubled with every
+//attempt up to MaxBackoff (zero is no limit), and the delivery fails after MaxAttempts
+type Dispatcher struct {
+	Store       storage.Store
+	Client      *http.Client
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	//BatchSize is the number of deliveries claimed at once
+	BatchSize int
+}
+
+//Dispatch makes an attempt of every due delivery and returns the number of successful ones; a delivery
+//interrupted by ctx is left claimed and retried after its lease expires
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, *models.CustomErr) {
+	//a claim outlives the longest attempt
+	lease := 2 * d.Client.Timeout
+	if lease <= 0 {
+		lease = time.Minute
+	}
+	tasks, cErr := d.Store.ClaimWebhookDeliveries(ctx, d.BatchSize, lease)
+	if cErr != nil {
+		return 0, cErr
+	}
+
+	delivered := 0
+	for i := range tasks {
+		delivery := &tasks[i].Delivery
+		statusCode, err := d.post(ctx, &tasks[i])
+		if ctx.Err() != nil {
+			return delivered, storage.Canceled(ctx)
+		}
+		d.finish(delivery, statusCode, err, time.Now())
+		if cErr = d.Store.FinishWebhookDelivery(ctx, delivery); cErr != nil {
+			return delivered, cErr
+		}
+		if delivery.Status == models.DeliveryStatusDelivered {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+//post posts the event of task to its webhook; a response other than 2xx is an error
+func (d *Dispatcher) post(ctx context.Context, task *models.DeliveryTask) (int, error) {
+	body, err := json.Marshal(&Payload{
+		ID:        task.Event.ID,
+		Type:      task.Event.Type,
+		AccountID: task.Event.AccountID,
+		CreatedAt: task.Event.CreatedAt,
+		Data:      json.RawMessage(task.Event.Payload),
+	})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, task.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(task.Webhook.Secret, body))
+	req.Header.Set(EventHeader, task.Event.Type)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(task.Delivery.ID))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return resp.StatusCode, fmt.Errorf("webhook responded with [%v]: %s", resp.StatusCode, data)
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+//finish records the result of an attempt of delivery made at now and schedules the next one if it failed
+func (d *Dispatcher) finish(delivery *models.WebhookDelivery, statusCode int, err error, now time.Time) {
+	delivery.Attempts++
+	delivery.UpdatedAt = now
+	delivery.StatusCode = nil
+	if statusCode != 0 {
+		delivery.StatusCode = &statusCode
+	}
+	if err == nil {
+		delivery.Status = models.DeliveryStatusDelivered
+		delivery.Error = ""
+		delivery.DeliveredAt = &now
+		return
+	}
+	delivery.Error = err.Error()
+	if delivery.Attempts >= d.MaxAttempts {
+		delivery.Status = models.DeliveryStatusFailed
+		return
+	}
+	delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+}
+
+//backoff returns the delay after failed attempt number attempt
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.Backoff
+	//without MaxBackoff the delay stops growing before it overflows
+	for i := 1; i < attempt && (d.MaxBackoff == 0 || delay < d.MaxBackoff) && delay <= math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+	if d.MaxBackoff > 0 && delay > d.MaxBackoff {
+		delay = d.MaxBackoff
+	}
+	return delay
+}
+
+//Sign returns the value of SignatureHeader for body posted to a webhook with secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dalconoid/balance-service/models"
+	"github.com/dalconoid/balance-service/storage/memory"
+	"github.com/magiconair/properties/assert"
+)
+
+const secret = "0123456789abcdef"
+
+func TestDispatch(t *testing.T) {
+	ctx := context.Background()
+	received := make(chan *Payload, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign(secret, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		payload := &Payload{}
+		json.Unmarshal(body, payload)
+		received <- payload
+	}))
+	defer server.Close()
+
+	s := memory.New(10)
+	account := 2
+	s.CreateWebhook(ctx, &models.WebhookRequest{URL: server.URL, Secret: secret, AccountID: &account})
+	d := &Dispatcher{Store: s, Client: server.Client(), MaxAttempts: 3, Backoff: time.Second, MaxBackoff: time.Minute, BatchSize: 10}
+
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 5000})
+	s.MakeTransfer(ctx, &models.TransferRequest{ID1: 1, ID2: 2, Delta: 2000})
+	delivered, cErr := d.Dispatch(ctx)
+	assert.Equal(t, cErr == nil, true)
+	assert.Equal(t, delivered, 1)
+
+	payload := <-received
+	assert.Equal(t, payload.Type, models.EventTypeBalanceChanged)
+	assert.Equal(t, payload.AccountID, 2)
+	transaction := &models.Transaction{}
+	json.Unmarshal(payload.Data, transaction)
+	assert.Equal(t, transaction.Remaining, models.Money(2000))
+
+	//delivered events are not posted again
+	delivered, _ = d.Dispatch(ctx)
+	assert.Equal(t, delivered, 0)
+	assert.Equal(t, len(received), 0)
+}
+
+func TestDispatchRetries(t *testing.T) {
+	ctx := context.Background()
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	s := memory.New(10)
+	webhook, _ := s.CreateWebhook(ctx, &models.WebhookRequest{URL: server.URL, Secret: secret})
+	d := &Dispatcher{Store: s, Client: server.Client(), MaxAttempts: 2, Backoff: time.Hour, MaxBackoff: time.Hour, BatchSize: 10}
+
+	s.UpdateBalance(ctx, &models.ChangeBalanceRequest{ID: 1, Delta: 5000})
+	delivered, _ := d.Dispatch(ctx)
+	assert.Equal(t, delivered, 0)
+	deliveries, _ := s.GetWebhookDeliveries(ctx, webhook.ID)
+	assert.Equal(t, len(deliveries), 1)
+	assert.Equal(t, deliveries[0].Status, models.DeliveryStatusPending)
+	assert.Equal(t, deliveries[0].Attempts, 1)
+	assert.Equal(t, *deliveries[0].StatusCode, http.StatusInternalServerError)
+	assert.Equal(t, deliveries[0].NextAttemptAt.After(time.Now().Add(59*time.Minute)), true)
+
+	//the retry is not due yet
+	delivered, _ = d.Dispatch(ctx)
+	assert.Equal(t, delivered, 0)
+
+	status = http.StatusOK
+	_, cErr := s.RedeliverWebhookDelivery(ctx, deliveries[0].ID)
+	assert.Equal(t, cErr == nil, true)
+	delivered, _ = d.Dispatch(ctx)
+	assert.Equal(t, delivered, 1)
+	deliveries, _ = s.GetWebhookDeliveries(ctx, webhook.ID)
+	assert.Equal(t, deliveries[0].Status, models.DeliveryStatusDelivered)
+	assert.Equal(t, deliveries[0].Error, "")
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{Backoff: 10 * time.Second, MaxBackoff: time.Minute}
+	assert.Equal(t, d.backoff(1), 10*time.Second)
+	assert.Equal(t, d.backoff(2), 20*time.Second)
+	assert.Equal(t, d.backoff(3), 40*time.Second)
+	assert.Equal(t, d.backoff(4), time.Minute)
+	assert.Equal(t, d.backoff(40), time.Minute)
+
+	//zero MaxBackoff does not limit the delay
+	d.MaxBackoff = 0
+	assert.Equal(t, d.backoff(1), 10*time.Second)
+	assert.Equal(t, d.backoff(5), 160*time.Second)
+	assert.Equal(t, d.backoff(100) > 100*365*24*time.Hour, true)
+
+	delivery := &models.WebhookDelivery{Attempts: 2}
+	d.MaxAttempts = 3
+	d.finish(delivery, 0, http.ErrHandlerTimeout, time.Now())
+	assert.Equal(t, delivery.Status, models.DeliveryStatusFailed)
+	assert.Equal(t, delivery.StatusCode == nil, true)
+}